@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/hanlders"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/tracing"
+	"github.com/samber/lo"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: octoargosync <command> [arguments]
+
+Commands:
+  serve [--self-check warn|fatal|off]       Start the web server. This is the default command.
+  self-check [--output text|json]           Check the connections to Octopus and ArgoCD, and list the mappings.
+  simulate --app namespace/name [options]   Print the releases that would be created for an application.
+  mappings list [--output text|json]        List the ArgoCD applications mapped to Octopus projects.
+  mappings validate [--output text|json]    Check the environments, channels, lifecycles, and package references in the mappings.
+  replay <file>                             Create the releases for the notifications saved in a file.`
+
+// optionalCommand is a command that is only compiled into some builds, like the harness command.
+type optionalCommand struct {
+	usage string
+	run   func(ctx context.Context, args []string, out io.Writer) error
+}
+
+// optionalCommands holds the commands registered by files with build tags.
+var optionalCommands = map[string]optionalCommand{}
+
+// getUsage returns the usage, including any optional commands compiled into the build.
+func getUsage() string {
+	lines := lo.Map(lo.Keys(optionalCommands), func(item string, index int) string {
+		return optionalCommands[item].usage
+	})
+	sort.Strings(lines)
+
+	return strings.Join(append([]string{usage}, lines...), "\n")
+}
+
+// run executes the command in the arguments, defaulting to starting the web server.
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return serve(ctx, args, out)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(ctx, args[1:], out)
+	case "self-check":
+		return selfCheck(ctx, args[1:], out)
+	case "simulate":
+		return simulate(ctx, args[1:], out)
+	case "mappings":
+		return mappings(ctx, args[1:], out)
+	case "replay":
+		return replay(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprintln(out, getUsage())
+		return nil
+	default:
+		if command, found := optionalCommands[args[0]]; found {
+			return command.run(ctx, args[1:], out)
+		}
+
+		return errors.New("unknown command " + args[0] + "\n\n" + getUsage())
+	}
+}
+
+// serve starts the web server, which runs until the context is cancelled. The self-check runs before the server
+// starts, and its failures are printed as warnings, or stop the proxy from starting.
+func serve(ctx context.Context, args []string, out io.Writer) (serveErr error) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	selfCheckMode := flags.String("self-check", lo.Ternary(os.Getenv("SELF_CHECK") != "", os.Getenv("SELF_CHECK"), "warn"),
+		"Whether self-check failures are printed as warnings, stop the proxy from starting, or the self-check is skipped: warn, fatal, or off")
+	selfCheckTimeout := flags.Duration("self-check-timeout", hanlders.DefaultSelfCheckTimeout, "How long each self-check can take")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *selfCheckMode != "warn" && *selfCheckMode != "fatal" && *selfCheckMode != "off" {
+		return errors.New("the --self-check argument must be warn, fatal, or off")
+	}
+
+	shutdownTracing, err := tracing.Init(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// Flush the spans of the last notifications before exiting
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		serveErr = errors.Join(serveErr, shutdownTracing(shutdownCtx))
+	}()
+
+	createReleaseHandler, err := hanlders.NewCreateReleaseHandler()
+
+	if err != nil {
+		return err
+	}
+
+	if *selfCheckMode != "off" {
+		err := writeSelfCheck(out, createReleaseHandler.SelfCheck(ctx, *selfCheckTimeout))
+
+		if err != nil && *selfCheckMode == "fatal" {
+			return err
+		}
+
+		if err != nil {
+			fmt.Fprintln(out, "WARNING: "+err.Error()+". Starting the proxy anyway.")
+		}
+	}
+
+	return start(ctx, createReleaseHandler)
+}
+
+// selfCheck checks the connections to Octopus and ArgoCD, printing the results and the discovered mappings.
+func selfCheck(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("self-check", flag.ContinueOnError)
+	output := flags.String("output", "text", "The output format, either text or json")
+	timeout := flags.Duration("timeout", hanlders.DefaultSelfCheckTimeout, "How long each check can take")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *output != "text" && *output != "json" {
+		return errors.New("the --output argument must be text or json")
+	}
+
+	createReleaseHandler, err := hanlders.NewCreateReleaseHandler()
+
+	if err != nil {
+		return err
+	}
+
+	report := createReleaseHandler.SelfCheck(ctx, *timeout)
+
+	if *output == "json" {
+		if err := writeJson(out, report); err != nil {
+			return err
+		}
+
+		return selfCheckError(report)
+	}
+
+	return writeSelfCheck(out, report)
+}
+
+// simulate prints the plan for the releases that would be created for an application.
+func simulate(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	app := flags.String("app", "", "The application in the format namespace/name")
+	revision := flags.String("revision", "", "The target revision of the application")
+	sha := flags.String("sha", "", "The commit SHA of the application")
+	images := flags.String("images", "", "A comma separated list of images, used if the images can not be read from ArgoCD")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	namespace, name, found := strings.Cut(*app, "/")
+
+	if !found || namespace == "" || name == "" {
+		return errors.New("the --app argument must be in the format namespace/name")
+	}
+
+	createReleaseHandler, err := hanlders.NewCreateReleaseHandler()
+
+	if err != nil {
+		return err
+	}
+
+	plan, err := createReleaseHandler.Simulate(ctx, models.ApplicationUpdateMessage{
+		Application:    name,
+		Namespace:      namespace,
+		TargetRevision: *revision,
+		CommitSha:      *sha,
+		Images: lo.Filter(strings.Split(*images, ","), func(item string, index int) bool {
+			return strings.TrimSpace(item) != ""
+		}),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return writeJson(out, plan)
+}
+
+// mappings lists or validates the ArgoCD applications mapped to Octopus projects.
+func mappings(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("the mappings command requires the list or validate subcommand\n\n" + getUsage())
+	}
+
+	flags := flag.NewFlagSet("mappings "+args[0], flag.ContinueOnError)
+	output := flags.String("output", "text", "The output format, either text or json")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *output != "text" && *output != "json" {
+		return errors.New("the --output argument must be text or json")
+	}
+
+	if args[0] != "list" && args[0] != "validate" {
+		return errors.New("unknown mappings subcommand " + args[0] + "\n\n" + getUsage())
+	}
+
+	octo, err := newOctopusClient()
+
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		applicationMappings, err := octo.GetMappings(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if *output == "json" {
+			return writeJson(out, applicationMappings)
+		}
+
+		return writeMappings(out, applicationMappings)
+	}
+
+	problems, err := octo.ValidateMappings(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if *output == "json" {
+		err = writeJson(out, problems)
+	} else {
+		err = writeTable(out, []string{"PROJECT", "VARIABLE", "CODE", "MESSAGE"},
+			lo.Map(problems, func(item models.MappingProblem, index int) []string {
+				return []string{item.Project, item.Variable, item.Code, item.Message}
+			}))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("found %d problems with the mappings", len(problems))
+	}
+
+	return nil
+}
+
+// replay creates the releases for the notifications saved in a file. The file contains a JSON notification, an array
+// of notifications, or one notification per line. Each notification is queued once the releases of the previous
+// notification have finished, so a file with more releases than the queue can hold is not rejected partway through.
+func replay(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("the replay command requires the file containing the notifications\n\n" + getUsage())
+	}
+
+	file, err := os.Open(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	notifications, err := readNotifications(file)
+
+	if err != nil {
+		return err
+	}
+
+	createReleaseHandler, err := hanlders.NewCreateReleaseHandler()
+
+	if err != nil {
+		return err
+	}
+
+	return replayNotifications(ctx, createReleaseHandler, notifications)
+}
+
+// replayNotifications replays the notifications in order, returning the errors of every notification that failed.
+func replayNotifications(ctx context.Context, createReleaseHandler *hanlders.CreateReleaseHandler, notifications []models.ApplicationUpdateMessage) error {
+	var replayErrors error
+	for _, notification := range notifications {
+		if err := replayNotification(ctx, createReleaseHandler, notification); err != nil {
+			replayErrors = errors.Join(replayErrors, fmt.Errorf("failed to replay the notification for %s/%s: %w",
+				notification.Namespace, notification.Application, err))
+		}
+	}
+
+	createReleaseHandler.Wait()
+
+	return replayErrors
+}
+
+// replayNotification queues a notification and waits for its releases to finish, returning the errors of the
+// notification and any failed releases.
+func replayNotification(ctx context.Context, createReleaseHandler *hanlders.CreateReleaseHandler, notification models.ApplicationUpdateMessage) error {
+	done := make(chan error, 1)
+	err := createReleaseHandler.Enqueue(ctx, notification, func(err error) {
+		done <- err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return <-done
+}
+
+// readNotifications reads a JSON notification, an array of notifications, or a stream of notifications.
+func readNotifications(reader io.Reader) ([]models.ApplicationUpdateMessage, error) {
+	data, err := io.ReadAll(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		notifications := []models.ApplicationUpdateMessage{}
+		err = json.Unmarshal(data, &notifications)
+		return notifications, err
+	}
+
+	notifications := []models.ApplicationUpdateMessage{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	for decoder.More() {
+		notification := models.ApplicationUpdateMessage{}
+
+		if err := decoder.Decode(&notification); err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// newOctopusClient creates the Octopus client for commands that do not need ArgoCD.
+func newOctopusClient() (octopus_apis.OctopusClient, error) {
+	err := retry_config.LoadPolicies()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return octopus_apis.NewLiveOctopusClient()
+}
+
+// writeSelfCheck prints the results of the self-check and the discovered mappings, returning an error describing the
+// failed checks.
+func writeSelfCheck(out io.Writer, report models.SelfCheckReport) error {
+	err := writeTable(out, []string{"CHECK", "RESULT", "MESSAGE"},
+		lo.Map(report.Checks, func(item models.SelfCheckResult, index int) []string {
+			return []string{item.Name, lo.Ternary(item.Passed, "PASSED", "FAILED"), item.Message}
+		}))
+
+	if err != nil {
+		return err
+	}
+
+	if len(report.Applications) != 0 {
+		fmt.Fprintln(out)
+
+		err = writeMappings(out, lo.FlatMap(report.Applications, func(item models.ApplicationInventory, index int) []models.ApplicationMapping {
+			return item.Projects
+		}))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return selfCheckError(report)
+}
+
+// selfCheckError returns an error listing the failed checks, or nil if every check passed.
+func selfCheckError(report models.SelfCheckReport) error {
+	failed := report.Failed()
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("the self-check failed: %s", strings.Join(lo.Map(failed, func(item models.SelfCheckResult, index int) string {
+		return item.Name + ": " + item.Message
+	}), "; "))
+}
+
+func writeMappings(out io.Writer, applicationMappings []models.ApplicationMapping) error {
+	return writeTable(out, []string{"APPLICATION", "PROJECT", "ENVIRONMENT", "CHANNEL", "LIFECYCLE", "RELEASE VERSION IMAGE", "PACKAGE VERSIONS"},
+		lo.Map(applicationMappings, func(item models.ApplicationMapping, index int) []string {
+			return []string{
+				item.Namespace + "/" + item.Application,
+				item.Project,
+				item.Environment,
+				item.Channel,
+				item.Lifecycle,
+				item.ReleaseVersionImage,
+				strings.Join(lo.Map(item.PackageVersions, func(item models.ImagePackageVersion, index int) string {
+					return item.PackageReference + "=" + item.Image
+				}), ","),
+			}
+		}))
+}
+
+func writeJson(out io.Writer, value any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func writeTable(out io.Writer, headers []string, rows [][]string) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(headers, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	return writer.Flush()
+}
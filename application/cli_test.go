@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/hanlders"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/fakes"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
+	"strings"
+	"testing"
+)
+
+func TestReadNotifications(t *testing.T) {
+	inputs := map[string]string{
+		"object": `{"Application": "app1", "Namespace": "argocd"}`,
+		"array":  `[{"Application": "app1", "Namespace": "argocd"}, {"Application": "app2", "Namespace": "argocd"}]`,
+		"lines":  "{\"Application\": \"app1\", \"Namespace\": \"argocd\"}\n{\"Application\": \"app2\", \"Namespace\": \"argocd\"}\n",
+	}
+
+	expectedCounts := map[string]int{"object": 1, "array": 2, "lines": 2}
+
+	for name, input := range inputs {
+		notifications, err := readNotifications(strings.NewReader(input))
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(notifications) != expectedCounts[name] {
+			t.Fatalf("%s: expected %d notifications, got %d", name, expectedCounts[name], len(notifications))
+		}
+
+		if notifications[0].Application != "app1" || notifications[0].Namespace != "argocd" {
+			t.Fatalf("%s: unexpected notification %+v", name, notifications[0])
+		}
+	}
+}
+
+func TestReplayWaitsForEachNotification(t *testing.T) {
+	octopus := fakes.NewFakeOctopusServer()
+	defer octopus.Close()
+
+	development := octopus.AddEnvironment("Development")
+	lifecycle := octopus.AddLifecycle("Default", &lifecycles.Phase{Name: "Development", OptionalDeploymentTargets: []string{development}})
+	project := octopus.AddProject("Project 1", lifecycle)
+	octopus.AddVariable(project, "Metadata.ArgoCD.Application[argocd/myapp].Environment", "Development")
+
+	octopusClient, err := octopus_apis.NewLiveOctopusClientForConnection(octopus_apis.OctopusConnection{
+		Server:  octopus.URL(),
+		ApiKey:  fakes.FakeOctopusApiKey,
+		SpaceId: fakes.FakeOctopusSpaceId,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The queues only hold a single release, so queueing every notification at once would reject the later ones
+	createReleaseHandler, err := hanlders.NewCreateReleaseHandlerFromConfig(hanlders.HandlerConfig{
+		Octopus:   octopusClient,
+		PoolSize:  1,
+		QueueSize: 1,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifications := []models.ApplicationUpdateMessage{}
+	for _, revision := range []string{"1.0.0", "1.0.1", "1.0.2", "1.0.3"} {
+		notifications = append(notifications, models.ApplicationUpdateMessage{
+			Application:    "myapp",
+			Namespace:      "argocd",
+			TargetRevision: revision,
+		})
+	}
+
+	if err := replayNotifications(context.Background(), createReleaseHandler, notifications); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(octopus.Deployments()) != len(notifications) {
+		t.Fatalf("Expected a deployment for each notification, got %d", len(octopus.Deployments()))
+	}
+}
+
+func TestRunRejectsInvalidArguments(t *testing.T) {
+	invalidArgs := [][]string{
+		{"unknown"},
+		{"mappings"},
+		{"mappings", "delete"},
+		{"mappings", "list", "--output", "yaml"},
+		{"simulate", "--app", "myapp"},
+		{"replay"},
+		{"serve", "--self-check", "maybe"},
+		{"self-check", "--output", "yaml"},
+	}
+
+	for _, args := range invalidArgs {
+		if err := run(context.Background(), args, &bytes.Buffer{}); err == nil {
+			t.Fatalf("Expected %v to fail", args)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	if err := run(context.Background(), []string{"help"}, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "mappings validate") {
+		t.Fatal("Expected the usage to be printed")
+	}
+}
+
+func TestWriteSelfCheck(t *testing.T) {
+	out := &bytes.Buffer{}
+	report := models.SelfCheckReport{
+		Checks: []models.SelfCheckResult{
+			{Name: "octopus", Passed: true, Message: "connected to Octopus"},
+			{Name: "argocd", Passed: false, Message: "connection refused"},
+		},
+		Applications: []models.ApplicationInventory{{
+			Namespace:   "argocd",
+			Application: "myapp",
+			Projects:    []models.ApplicationMapping{{Namespace: "argocd", Application: "myapp", Project: "Project 1", Environment: "Development"}},
+		}},
+	}
+
+	err := writeSelfCheck(out, report)
+
+	if err == nil || err.Error() != "the self-check failed: argocd: connection refused" {
+		t.Fatalf("Expected the failed check to be returned, got %v", err)
+	}
+
+	if !strings.Contains(out.String(), "FAILED") || !strings.Contains(out.String(), "argocd/myapp") {
+		t.Fatalf("Expected the checks and mappings to be printed, got %s", out.String())
+	}
+}
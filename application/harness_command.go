@@ -0,0 +1,39 @@
+//go:build harness
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/harness"
+	"io"
+)
+
+// The harness command links the fake Octopus and ArgoCD servers into the binary, so it is only compiled into builds
+// made with the harness tag:
+//
+//	go run -tags harness ./application harness --update application/testdata/replay
+func init() {
+	optionalCommands["harness"] = optionalCommand{
+		usage: "  harness [--update] <dir>                  Replay recorded notifications against fake Octopus and ArgoCD servers and compare the Octopus API calls with golden files.",
+		run:   runHarness,
+	}
+}
+
+// runHarness replays recorded notifications against fake back ends, comparing the Octopus API calls with the golden
+// files, or rewriting the golden files with --update.
+func runHarness(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("harness", flag.ContinueOnError)
+	update := flags.Bool("update", false, "Rewrite the golden files with the Octopus API calls made by the proxy")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("the harness command requires the directory containing the recorded scenarios\n\n" + getUsage())
+	}
+
+	return harness.Run(ctx, flags.Arg(0), *update, out, newHarnessRouter)
+}
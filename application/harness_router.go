@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/hanlders"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/validation"
+	"net/http"
+)
+
+// newHarnessRouter creates the routes replayed by the harness for a handler connected to the fake servers. It is
+// shared by the harness tests and the harness command, which is only compiled with the harness build tag.
+func newHarnessRouter(ctx context.Context, createReleaseHandler *hanlders.CreateReleaseHandler) (http.Handler, error) {
+	mappingValidator, err := validation.NewDefaultMappingValidator(createReleaseHandler)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newRouter(ctx, createReleaseHandler, mappingValidator)
+}
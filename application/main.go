@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// The context is cancelled when the proxy is asked to shut down, which in turn cancels any release jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		stop()
+		os.Exit(1)
+	}
+}
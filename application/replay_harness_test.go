@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/harness"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the replay harness golden files")
+
+func TestReplayHarness(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	if err := harness.Run(context.Background(), filepath.Join("testdata", "replay"), *updateGolden, out, newHarnessRouter); err != nil {
+		t.Fatalf("%s\n%v", out.String(), err)
+	}
+}
+
+func TestReplayHarnessDetectsChanges(t *testing.T) {
+	scenario := t.TempDir()
+
+	for _, file := range []string{"backends.json", "notifications/01-sync.json", "expected.json"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "replay", "image-versioning", file))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// ArgoCD deploying a different image changes the release version and package versions
+		data = bytes.ReplaceAll(data, []byte("nginx:1.26.0"), []byte("nginx:1.26.1"))
+
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(scenario, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(scenario, file), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := harness.Run(context.Background(), scenario, false, &bytes.Buffer{}, newHarnessRouter)
+
+	if err == nil || !strings.Contains(err.Error(), "01-sync.json did not match") {
+		t.Fatalf("Expected the first notification to not match the golden file, got %v", err)
+	}
+}
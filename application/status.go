@@ -0,0 +1,73 @@
+package main
+
+import (
+	_ "embed"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/hanlders"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/validation"
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+//go:embed status.html
+var statusPage string
+
+// statusPageData is the data rendered by the status page.
+type statusPageData struct {
+	Report          models.StatusReport
+	Validation      models.MappingValidationReport
+	ValidationFound bool
+}
+
+// addStatusRoutes adds the status page, which lets on-call engineers see what happened to recent notifications
+// without reading the logs, and the API returning the same report.
+func addStatusRoutes(r *gin.Engine, createReleaseHandler *hanlders.CreateReleaseHandler, mappingValidator *validation.MappingValidator) error {
+	page, err := template.New("status").Funcs(template.FuncMap{"formatTime": formatStatusTime}).Parse(statusPage)
+
+	if err != nil {
+		return err
+	}
+
+	r.GET("/status", func(c *gin.Context) {
+		validationReport, found := mappingValidator.LatestReport()
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		err := page.Execute(c.Writer, statusPageData{
+			Report:          createReleaseHandler.GetStatus(c.Request.Context()),
+			Validation:      validationReport,
+			ValidationFound: found,
+		})
+
+		if err != nil {
+			_ = c.Error(err)
+			c.Status(http.StatusInternalServerError)
+		}
+	})
+
+	r.GET("/api/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, createReleaseHandler.GetStatus(c.Request.Context()))
+	})
+
+	return nil
+}
+
+// formatStatusTime formats the times shown on the status page, which may be nil or zero if an event has not happened.
+func formatStatusTime(value any) string {
+	var timestamp time.Time
+	switch typedValue := value.(type) {
+	case time.Time:
+		timestamp = typedValue
+	case *time.Time:
+		if typedValue != nil {
+			timestamp = *typedValue
+		}
+	}
+
+	if timestamp.IsZero() {
+		return "never"
+	}
+
+	return timestamp.UTC().Format("2006-01-02 15:04:05 MST")
+}
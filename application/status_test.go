@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/harness"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusPage(t *testing.T) {
+	scenario, err := harness.StartScenario(filepath.Join("testdata", "replay", "image-versioning"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(scenario.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(scenario.Handler.Wait)
+	t.Cleanup(cancel)
+
+	router, err := newHarnessRouter(ctx, scenario.Handler)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := os.ReadFile(filepath.Join("testdata", "replay", "image-versioning", "notifications", "01-sync.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := scenario.NewNotificationRequest(payload)
+	request.Header.Set("X-Correlation-ID", "status-test")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	scenario.Handler.Wait()
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+
+	report := models.StatusReport{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Notifications) != 1 || report.Notifications[0].CorrelationId != "status-test" ||
+		report.Notifications[0].Outcome != models.NotificationOutcomeCompleted {
+		t.Fatalf("Expected the completed notification, got %+v", report.Notifications)
+	}
+
+	projects := report.Notifications[0].Projects
+	if len(projects) != 1 || projects[0].Status != models.ReleaseStatusDeployed || projects[0].DeploymentId == "" {
+		t.Fatalf("Expected the release to be deployed, got %+v", projects)
+	}
+
+	if len(report.Inventory) != 1 || report.Inventory[0].Application != "webapp" {
+		t.Fatalf("Expected the mapping inventory, got %+v", report.Inventory)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected the status page, got %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	for _, expected := range []string{"status-test", "argocd/webapp", projects[0].Project, projects[0].DeploymentId, "No releases are waiting to be created"} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Fatalf("Expected the status page to include %s, got %s", expected, recorder.Body.String())
+		}
+	}
+}
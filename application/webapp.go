@@ -1,70 +1,419 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/hanlders"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/idempotency"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/jsonex"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/validation"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/health"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/idempotency_stores"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/metrics"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/tracing"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/workers"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
-func main() {
-	createReleaseHandler, err := hanlders.NewCreateReleaseHandler()
+func start(ctx context.Context, createReleaseHandler *hanlders.CreateReleaseHandler) error {
+	logger, err := apploggers.NewDevProdLogger()
 
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
-	err = start(createReleaseHandler)
+	// Misconfigured mappings are reported before they are used to create a release
+	mappingValidator, err := validation.NewDefaultMappingValidator(createReleaseHandler)
 
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+		return err
 	}
+
+	mappingValidator.Start(ctx)
+
+	r, err := newRouter(ctx, createReleaseHandler, mappingValidator)
+
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:    ":" + getPort(),
+		Handler: r,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			logger.GetLogger().Error("octoargosync-shutdown-error: Failed to shut down the web server: " + err.Error())
+		}
+	}()
+
+	err = server.ListenAndServe()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// The release jobs have been cancelled by the context, so wait for them to exit cleanly
+	createReleaseHandler.Wait()
+
+	return nil
 }
 
-func start(createReleaseHandler *hanlders.CreateReleaseHandler) error {
+// newRouter creates the routes served by the proxy. Notifications are processed in the background until the context
+// is cancelled.
+func newRouter(ctx context.Context, createReleaseHandler *hanlders.CreateReleaseHandler, mappingValidator *validation.MappingValidator) (*gin.Engine, error) {
 	logger, err := apploggers.NewDevProdLogger()
 
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+		return nil, err
+	}
+
+	idempotencyStore, err := idempotency_stores.NewDefaultStore()
+
+	if err != nil {
+		return nil, err
+	}
+
+	readinessChecker, err := health.NewDefaultChecker(createReleaseHandler.ReadinessChecks()...)
+
+	if err != nil {
+		return nil, err
 	}
 
 	gin.DisableConsoleColor()
 	r := gin.Default()
+	r.Use(tracing.Middleware())
+	r.Use(apploggers.Middleware())
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// The liveness probe only checks the process is serving requests, so an outage in Octopus or ArgoCD does not
+	// restart the proxy
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": health.StatusOk,
+		})
+	})
+
+	// The readiness probe removes the proxy from the service when it can not process notifications
+	r.GET("/readyz", func(c *gin.Context) {
+		report := readinessChecker.Check(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status == health.StatusFailed {
+			status = http.StatusServiceUnavailable
+		}
+
+		c.JSON(status, report)
+	})
 
 	r.POST("/api/octopusrelease", func(c *gin.Context) {
 
+		correlationId := apploggers.CorrelationIdFromContext(c.Request.Context())
+		requestLogger := logger.With(apploggers.CorrelationId(correlationId))
+
 		applicationUpdateMessage := models.ApplicationUpdateMessage{}
 		err := jsonex.DeserializeJson(c.Request.Body, &applicationUpdateMessage)
 
 		if err != nil {
-			logger.GetLogger().Error("octoargosync-init-requestbodyerror: Failed to deserialize request body: " + err.Error())
+			err = apperrors.Wrap(apperrors.CodeRequestInvalid, "failed to deserialize request body", err)
+			metrics.RecordError("request", err)
+			requestLogger.GetLogger().Error("octoargosync-init-requestbodyerror: Failed to deserialize request body: "+err.Error(), apperrors.Fields(err)...)
 
-			c.JSON(http.StatusOK, models.ErrorResponse{
-				Status:  "Error",
-				Message: err.Error(),
-			})
+			c.JSON(http.StatusOK, models.NewErrorResponse(err))
 			return
 		}
 
-		// Return a response as quickly as possible by doing the release creation in goroutine
-		go func(applicationUpdateMessage models.ApplicationUpdateMessage) {
-			err := createReleaseHandler.CreateRelease(applicationUpdateMessage)
+		requestLogger = requestLogger.With(
+			apploggers.Application(applicationUpdateMessage.Application),
+			apploggers.Namespace(applicationUpdateMessage.Namespace))
+
+		response, err := json.Marshal(gin.H{
+			"status":        "OK",
+			"correlationId": correlationId,
+		})
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err))
+			return
+		}
+
+		// ArgoCD can redeliver notifications, so duplicates are identified and return the original response. The key
+		// is remembered with the acknowledgement while the notification is processed, replaced with the final result
+		// when it succeeds, and forgotten when it fails so a redelivered notification is processed again.
+		idempotencyKey := idempotency.GetKey(applicationUpdateMessage, c.GetHeader(idempotency.KeyHeader))
+		if idempotencyKey != "" {
+			result, duplicate, err := idempotencyStore.Remember(idempotencyKey, idempotency.Result{
+				StatusCode: http.StatusAccepted,
+				Body:       response,
+			})
+
 			if err != nil {
-				logger.GetLogger().Error("octoargosync-init-octocreatereleaseerror: Failed to create a release: " + err.Error())
+				requestLogger.GetLogger().Error("octoargosync-init-idempotencyerror: Failed to check the idempotency key: " + err.Error())
+				idempotencyKey = ""
+			} else if duplicate {
+				// The original response includes the correlation ID of the notification that was processed
+				requestLogger.GetLogger().Info("Ignoring duplicate notification", zap.String("idempotencyKey", idempotencyKey))
+				c.Header(idempotency.ReplayedHeader, "true")
+				c.Data(result.StatusCode, "application/json; charset=utf-8", result.Body)
+				return
 			}
-		}(applicationUpdateMessage)
+		}
+
+		// Return a response as quickly as possible by queuing the release creation. The release jobs are traced as
+		// part of the request, and log with its correlation ID, but are only cancelled when the proxy shuts down.
+		jobCtx := apploggers.WithCorrelationId(tracing.WithSpanContext(ctx, c.Request.Context()), correlationId)
+		err = createReleaseHandler.Enqueue(jobCtx, applicationUpdateMessage, func(err error) {
+			if idempotencyKey != "" {
+				completeIdempotencyKey(idempotencyStore, idempotencyKey, correlationId, err, requestLogger)
+			}
+		})
+
+		if err != nil {
+			// The notification was not processed, so allow it to be delivered again
+			if idempotencyKey != "" {
+				if err := idempotencyStore.Forget(idempotencyKey); err != nil {
+					requestLogger.GetLogger().Error("octoargosync-init-idempotencyerror: Failed to forget the idempotency key: " + err.Error())
+				}
+			}
+
+			status := http.StatusInternalServerError
+			if errors.Is(err, workers.ErrQueueFull) {
+				status = http.StatusTooManyRequests
+				err = apperrors.Wrap(apperrors.CodeQueueFull, "failed to queue the notification", err)
+			}
+
+			err = apperrors.WithContext(err, applicationUpdateMessage.Namespace+"/"+applicationUpdateMessage.Application, "")
+			metrics.RecordError("request", err)
+			requestLogger.GetLogger().Error("octoargosync-init-queueerror: Failed to queue the notification: "+err.Error(), apperrors.Fields(err)...)
+
+			c.JSON(status, models.NewErrorResponse(err))
+			return
+		}
+
+		c.Data(http.StatusAccepted, "application/json; charset=utf-8", response)
+	})
+
+	r.POST("/api/octopusrelease/simulate", func(c *gin.Context) {
+
+		applicationUpdateMessage := models.ApplicationUpdateMessage{}
+		err := jsonex.DeserializeJson(c.Request.Body, &applicationUpdateMessage)
+
+		if err != nil {
+			err = apperrors.Wrap(apperrors.CodeRequestInvalid, "failed to deserialize request body", err)
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(err))
+			return
+		}
+
+		// The simulation runs while the client waits, so it is cancelled if the client disconnects
+		plan, err := createReleaseHandler.Simulate(c.Request.Context(), applicationUpdateMessage)
+
+		if err != nil {
+			err = apperrors.WithContext(err, applicationUpdateMessage.Namespace+"/"+applicationUpdateMessage.Application, "")
+			metrics.RecordError("simulate", err)
+			logger.GetLogger().Error("octoargosync-simulate-error: Failed to simulate the release: "+err.Error(), apperrors.Fields(err)...)
+
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, plan)
+	})
+
+	webhookSecret := os.Getenv("OCTOPUS_WEBHOOK_SECRET")
 
-		c.JSON(http.StatusAccepted, gin.H{
-			"status": "OK",
+	if webhookSecret == "" {
+		logger.GetLogger().Warn("octoargosync-init-webhookwarning: OCTOPUS_WEBHOOK_SECRET is not set, so all Octopus " +
+			"events sent to /api/octopusevents are rejected. Set OCTOPUS_WEBHOOK_SECRET to enable the webhook.")
+	}
+
+	r.POST("/api/octopusevents", func(c *gin.Context) {
+		if err := authenticateWebhook(c.GetHeader("X-Octopus-Webhook-Secret"), webhookSecret); err != nil {
+			metrics.RecordError("octopusevent", err)
+			c.JSON(http.StatusUnauthorized, models.NewErrorResponse(err))
+			return
+		}
+
+		payload := models.OctopusSubscriptionPayload{}
+		err := jsonex.DeserializeJson(c.Request.Body, &payload)
+
+		if err != nil {
+			err = apperrors.Wrap(apperrors.CodeRequestInvalid, "failed to deserialize request body", err)
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(err))
+			return
+		}
+
+		evicted := createReleaseHandler.InvalidateOctopusCache(payload.Payload.Event)
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "OK",
+			"evicted": evicted,
 		})
 	})
 
-	return r.Run()
+	r.GET("/api/mappings", func(c *gin.Context) {
+		inventory, err := createReleaseHandler.GetMappingInventory(c.Request.Context(), c.Query("namespace"), c.Query("project"))
+
+		if err != nil {
+			metrics.RecordError("mappings", err)
+			logger.GetLogger().Error("octoargosync-mappings-error: Failed to get the mappings: "+err.Error(), apperrors.Fields(err)...)
+
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, inventory)
+	})
+
+	r.GET("/api/mappings/validation", func(c *gin.Context) {
+		report, found := mappingValidator.LatestReport()
+
+		if !found || c.Query("refresh") == "true" {
+			report = mappingValidator.Validate(c.Request.Context())
+		}
+
+		c.JSON(http.StatusOK, report)
+	})
+
+	err = addStatusRoutes(r, createReleaseHandler, mappingValidator)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.GET("/api/audit", func(c *gin.Context) {
+		filter, err := getAuditFilter(c)
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(err))
+			return
+		}
+
+		events, err := createReleaseHandler.QueryAudit(c.Request.Context(), filter)
+
+		if err != nil {
+			status := http.StatusInternalServerError
+			if appError, ok := apperrors.As(err); ok && appError.Code == apperrors.CodeAuditDisabled {
+				status = http.StatusNotFound
+			} else {
+				metrics.RecordError("audit", err)
+				logger.GetLogger().Error("octoargosync-audit-error: Failed to query the audit log: "+err.Error(), apperrors.Fields(err)...)
+			}
+
+			c.JSON(status, models.NewErrorResponse(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, events)
+	})
+
+	return r, nil
+}
+
+// getAuditFilter reads the audit log filter from the query string. The from and to times are RFC 3339 timestamps.
+func getAuditFilter(c *gin.Context) (audit.Filter, error) {
+	filter := audit.Filter{
+		Application: c.Query("application"),
+		Project:     c.Query("project"),
+		Limit:       defaultAuditLimit,
+	}
+
+	for name, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if c.Query(name) == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, c.Query(name))
+
+		if err != nil {
+			return filter, apperrors.Wrap(apperrors.CodeRequestInvalid, "the "+name+" query parameter must be an RFC 3339 time like 2024-01-01T00:00:00Z", err)
+		}
+
+		*value = parsed
+	}
+
+	if c.Query("limit") != "" {
+		limit, err := strconv.Atoi(c.Query("limit"))
+
+		if err != nil || limit <= 0 {
+			return filter, apperrors.New(apperrors.CodeRequestInvalid, "the limit query parameter must be a positive integer")
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// defaultAuditLimit is the number of audit events returned when the request does not specify a limit.
+const defaultAuditLimit = 1000
+
+// completeIdempotencyKey saves the result of a processed notification, which is returned for any duplicates. The key
+// is forgotten if the notification failed, so it is processed again when it is redelivered.
+func completeIdempotencyKey(store idempotency.Store, key string, correlationId string, processErr error, logger apploggers.AppLogger) {
+	if processErr != nil {
+		if err := store.Forget(key); err != nil {
+			logger.GetLogger().Error("octoargosync-init-idempotencyerror: Failed to forget the idempotency key: " + err.Error())
+		}
+
+		return
+	}
+
+	response, err := json.Marshal(gin.H{
+		"status":        "OK",
+		"correlationId": correlationId,
+		"outcome":       models.NotificationOutcomeCompleted,
+	})
+
+	if err == nil {
+		err = store.Complete(key, idempotency.Result{StatusCode: http.StatusOK, Body: response})
+	}
+
+	if err != nil {
+		logger.GetLogger().Error("octoargosync-init-idempotencyerror: Failed to save the result for the idempotency key: " + err.Error())
+	}
+}
+
+// authenticateWebhook compares the secret sent in a custom header of the Octopus subscription to the configured
+// secret. The webhook fails closed, so every event is rejected when no secret is configured.
+func authenticateWebhook(sentSecret string, webhookSecret string) error {
+	if webhookSecret == "" {
+		return apperrors.New(apperrors.CodeWebhookUnauthorized, "the webhook is disabled as OCTOPUS_WEBHOOK_SECRET is not set")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(sentSecret), []byte(webhookSecret)) != 1 {
+		return apperrors.New(apperrors.CodeWebhookUnauthorized, "the webhook secret is missing or invalid")
+	}
+
+	return nil
+}
+
+// getPort returns the port to listen on, using the PORT environment variable like gin does by default
+func getPort() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+
+	return "8080"
 }
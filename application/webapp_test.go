@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/idempotency"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/idempotency_stores"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompleteIdempotencyKey(t *testing.T) {
+	store, err := idempotency_stores.NewBigCacheStore(time.Minute)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := apploggers.NewDevProdLogger()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acknowledged := idempotency.Result{StatusCode: http.StatusAccepted, Body: []byte(`{"status":"OK"}`)}
+
+	for _, key := range []string{"succeeded", "failed"} {
+		if _, _, err := store.Remember(key, acknowledged); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completeIdempotencyKey(store, "succeeded", "notification-1", nil, logger)
+	completeIdempotencyKey(store, "failed", "notification-2", errors.New("Octopus is down"), logger)
+
+	result, duplicate, err := store.Remember("succeeded", acknowledged)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !duplicate || result.StatusCode != http.StatusOK || !strings.Contains(string(result.Body), "notification-1") {
+		t.Fatalf("Expected the result of the processed notification, got %d %s", result.StatusCode, result.Body)
+	}
+
+	// A failed notification is processed again when it is redelivered
+	if _, duplicate, err := store.Remember("failed", acknowledged); err != nil || duplicate {
+		t.Fatalf("Expected the failed notification to be forgotten, got %v and %v", duplicate, err)
+	}
+}
+
+func TestAuthenticateWebhook(t *testing.T) {
+	if err := authenticateWebhook("secret", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sentSecret := range []string{"", "invalid"} {
+		if err := authenticateWebhook(sentSecret, "secret"); err == nil {
+			t.Fatalf("Expected the secret %q to be rejected", sentSecret)
+		}
+	}
+
+	// The webhook fails closed when no secret is configured
+	if err := authenticateWebhook("", ""); err == nil {
+		t.Fatal("Expected the webhook to be disabled")
+	}
+}
@@ -0,0 +1,130 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Category groups errors by how they should be handled.
+type Category string
+
+const (
+	// Config errors are caused by misconfigured Octopus projects, variables, or environment variables
+	Config Category = "config"
+	// Transient errors are expected to resolve themselves, so the operation can be retried
+	Transient Category = "transient"
+	// NotFound errors indicate a resource referenced by a notification does not exist
+	NotFound Category = "not-found"
+	// Validation errors indicate a request or value is malformed
+	Validation Category = "validation"
+)
+
+// Error is an error with a stable code that can be used in logs, metrics, and API responses, along with the
+// application and project it relates to and a hint to resolve it.
+type Error struct {
+	Code        Code
+	Category    Category
+	Message     string
+	Application string
+	Project     string
+	Remediation string
+	Err         error
+}
+
+// New creates an error with the category and remediation hint registered for the code.
+func New(code Code, message string) *Error {
+	definition := definitions[code]
+
+	return &Error{
+		Code:        code,
+		Category:    definition.category,
+		Message:     message,
+		Remediation: definition.remediation,
+	}
+}
+
+// Wrap creates an error with the category and remediation hint registered for the code that wraps the cause.
+func Wrap(code Code, message string, err error) *Error {
+	appError := New(code, message)
+	appError.Err = err
+	return appError
+}
+
+func (e *Error) Error() string {
+	message := string(e.Code) + ": " + e.Message
+
+	details := []string{}
+	if e.Application != "" {
+		details = append(details, "application "+e.Application)
+	}
+	if e.Project != "" {
+		details = append(details, "project "+e.Project)
+	}
+	if len(details) != 0 {
+		message += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Permanent returns true if retrying the operation will not resolve the error.
+func (e *Error) Permanent() bool {
+	return e.Category != Transient
+}
+
+// As returns the first Error in the error chain.
+func As(err error) (*Error, bool) {
+	var appError *Error
+	if errors.As(err, &appError) {
+		return appError, true
+	}
+
+	return nil, false
+}
+
+// Classify returns the Error in the error chain, or wraps an unclassified error as a transient error so every
+// error can be reported with a code.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	if appError, ok := As(err); ok {
+		return appError
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return Wrap(CodeCancelled, "the operation was cancelled", err)
+	}
+
+	return Wrap(CodeUnknown, "an unexpected error occurred", err)
+}
+
+// WithContext adds the application and project to the Error in the error chain, leaving any existing values.
+// Errors that are not an Error are returned unchanged.
+func WithContext(err error, application string, project string) error {
+	appError, ok := As(err)
+
+	if !ok {
+		return err
+	}
+
+	if application != "" && appError.Application == "" {
+		appError.Application = application
+	}
+
+	if project != "" && appError.Project == "" {
+		appError.Project = project
+	}
+
+	return err
+}
@@ -0,0 +1,85 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"testing"
+)
+
+func TestNewUsesRegisteredCategory(t *testing.T) {
+	err := New(CodeOctopusEnvironmentNotFound, "failed to find an environment called Developmnt")
+
+	if err.Category != Config {
+		t.Fatalf("Expected the config category, got %s", err.Category)
+	}
+
+	if err.Remediation == "" {
+		t.Fatal("Expected a remediation hint")
+	}
+
+	if !err.Permanent() {
+		t.Fatal("Expected config errors to be permanent")
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", New(CodeOctopusChannelNotFound, "could not find the channel called Beta"))
+	err = WithContext(err, "argocd/myapp", "My Project")
+	err = WithContext(err, "argocd/otherapp", "")
+
+	appError, ok := As(err)
+
+	if !ok {
+		t.Fatal("Expected to find the error in the chain")
+	}
+
+	if appError.Application != "argocd/myapp" || appError.Project != "My Project" {
+		t.Fatalf("Unexpected context %s %s", appError.Application, appError.Project)
+	}
+
+	expected := "octopus-channel-not-found: could not find the channel called Beta (application argocd/myapp, project My Project)"
+	if appError.Error() != expected {
+		t.Fatalf("Expected %q, got %q", expected, appError.Error())
+	}
+}
+
+func TestClassify(t *testing.T) {
+	if Classify(nil) != nil {
+		t.Fatal("Expected nil errors to remain nil")
+	}
+
+	if appError := Classify(errors.New("connection refused")); appError.Code != CodeUnknown || appError.Category != Transient {
+		t.Fatalf("Expected an unknown transient error, got %s %s", appError.Code, appError.Category)
+	}
+
+	if appError := Classify(fmt.Errorf("request: %w", context.Canceled)); appError.Code != CodeCancelled {
+		t.Fatalf("Expected a cancelled error, got %s", appError.Code)
+	}
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	retry_config.SetPolicies(map[retry_config.OperationClass]retry_config.Policy{retry_config.OctopusRead: {Attempts: 3}})
+	defer retry_config.SetPolicies(nil)
+
+	attempts := 0
+	_ = retry_config.Do(context.Background(), retry_config.OctopusRead, func() error {
+		attempts++
+		return New(CodeOctopusEnvironmentNotFound, "failed to find an environment called Developmnt")
+	})
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", attempts)
+	}
+
+	attempts = 0
+	_ = retry_config.Do(context.Background(), retry_config.OctopusRead, func() error {
+		attempts++
+		return New(CodeOctopusRequestFailed, "the request to Octopus failed")
+	})
+
+	if attempts != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", attempts)
+	}
+}
@@ -0,0 +1,177 @@
+package apperrors
+
+// Code is a stable identifier for an error. Codes must not be changed once released, as they are used by alerts and
+// dashboards.
+type Code string
+
+const (
+	CodeUnknown   Code = "unknown"
+	CodeCancelled Code = "cancelled"
+
+	CodeRequestInvalid Code = "request-invalid"
+	CodeQueueFull      Code = "queue-full"
+
+	CodeWebhookUnauthorized Code = "webhook-unauthorized"
+
+	CodeAuditDisabled Code = "audit-disabled"
+	CodeAuditFailed   Code = "audit-failed"
+
+	CodeOctopusConfigMissing           Code = "octopus-config-missing"
+	CodeOctopusClientFailed            Code = "octopus-client-failed"
+	CodeOctopusRequestFailed           Code = "octopus-request-failed"
+	CodeOctopusUnavailable             Code = "octopus-unavailable"
+	CodeOctopusUnauthorized            Code = "octopus-unauthorized"
+	CodeOctopusRequestRejected         Code = "octopus-request-rejected"
+	CodeOctopusSpaceNotFound           Code = "octopus-space-not-found"
+	CodeOctopusEnvironmentNotFound     Code = "octopus-environment-not-found"
+	CodeOctopusChannelNotFound         Code = "octopus-channel-not-found"
+	CodeOctopusDefaultChannelNotFound  Code = "octopus-default-channel-not-found"
+	CodeOctopusLifecycleNotFound       Code = "octopus-lifecycle-not-found"
+	CodeOctopusLifecycleNoPhases       Code = "octopus-lifecycle-no-phases"
+	CodeOctopusLifecycleEnvironment    Code = "octopus-lifecycle-missing-environment"
+	CodeOctopusPackageReferenceInvalid Code = "octopus-package-reference-invalid"
+	CodeOctopusStepNotFound            Code = "octopus-step-not-found"
+	CodeOctopusPackageNotFound         Code = "octopus-package-not-found"
+
+	CodeMappingApplicationInvalid Code = "mapping-application-invalid"
+	CodeMappingEnvironmentMissing Code = "mapping-environment-missing"
+
+	CodeArgoConfigMissing       Code = "argocd-config-missing"
+	CodeArgoClientFailed        Code = "argocd-client-failed"
+	CodeArgoRequestFailed       Code = "argocd-request-failed"
+	CodeArgoApplicationNotFound Code = "argocd-application-not-found"
+	CodeArgoProjectNotFound     Code = "argocd-project-not-found"
+
+	CodeVersionerFailed Code = "versioner-failed"
+)
+
+type definition struct {
+	category    Category
+	remediation string
+}
+
+var definitions = map[Code]definition{
+	CodeUnknown: {
+		category: Transient,
+	},
+	CodeCancelled: {
+		category:    Transient,
+		remediation: "The operation was superseded by a newer notification, or the proxy is shutting down.",
+	},
+	CodeRequestInvalid: {
+		category:    Validation,
+		remediation: "Check the body of the ArgoCD notification template matches the example in the README.",
+	},
+	CodeQueueFull: {
+		category:    Transient,
+		remediation: "Retry the request later, or increase the WORKER_QUEUE_SIZE environment variable.",
+	},
+	CodeWebhookUnauthorized: {
+		category:    Config,
+		remediation: "Set the OCTOPUS_WEBHOOK_SECRET environment variable, and add the X-Octopus-Webhook-Secret header with the same value to the Octopus subscription.",
+	},
+	CodeAuditDisabled: {
+		category:    Config,
+		remediation: "Set the AUDIT_LOG_DIRECTORY environment variable to enable the audit log.",
+	},
+	CodeAuditFailed: {
+		category:    Transient,
+		remediation: "Check that the directory in the AUDIT_LOG_DIRECTORY environment variable exists and is writable.",
+	},
+	CodeOctopusConfigMissing: {
+		category:    Config,
+		remediation: "Define the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID environment variables.",
+	},
+	CodeOctopusClientFailed: {
+		category:    Config,
+		remediation: "Check that the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID environment variables are valid.",
+	},
+	CodeOctopusRequestFailed: {
+		category:    Transient,
+		remediation: "Check that Octopus is available and the API key has permission to view and deploy the project.",
+	},
+	CodeOctopusUnavailable: {
+		category:    Transient,
+		remediation: "Octopus is unavailable or overloaded. Requests resume automatically once it recovers.",
+	},
+	CodeOctopusUnauthorized: {
+		category:    Config,
+		remediation: "Check that the OCTOPUS_API_KEY environment variable is a valid API key that has not expired.",
+	},
+	CodeOctopusRequestRejected: {
+		category:    Config,
+		remediation: "Octopus rejected the request. Check that the project, its deployment process, and the feeds it references are valid.",
+	},
+	CodeOctopusSpaceNotFound: {
+		category:    Config,
+		remediation: "Check that the OCTOPUS_SPACE_ID environment variable is the ID of an existing space, like Spaces-1.",
+	},
+	CodeOctopusEnvironmentNotFound: {
+		category:    Config,
+		remediation: "Set the Metadata.ArgoCD.Application[namespace/application].Environment variable to the name of an existing environment.",
+	},
+	CodeOctopusChannelNotFound: {
+		category:    Config,
+		remediation: "Set the Metadata.ArgoCD.Application[namespace/application].Channel variable to the name of an existing channel in the project.",
+	},
+	CodeOctopusDefaultChannelNotFound: {
+		category:    Config,
+		remediation: "Make sure the project has a default channel, or set the Metadata.ArgoCD.Application[namespace/application].Channel variable.",
+	},
+	CodeOctopusLifecycleNotFound: {
+		category:    Config,
+		remediation: "Make sure the lifecycle assigned to the project or channel exists.",
+	},
+	CodeOctopusLifecycleNoPhases: {
+		category:    Config,
+		remediation: "Add a phase including the environment to the lifecycle assigned to the project or channel.",
+	},
+	CodeOctopusLifecycleEnvironment: {
+		category:    Config,
+		remediation: "Add the environment to the first phase of the lifecycle assigned to the project or channel.",
+	},
+	CodeOctopusPackageReferenceInvalid: {
+		category:    Validation,
+		remediation: "Package references must be in the format step or step:package.",
+	},
+	CodeOctopusStepNotFound: {
+		category:    Config,
+		remediation: "Set the package reference in the Metadata.ArgoCD.Application[namespace/application].ImageForPackageVersion[step:package] variable to the name of a step in the deployment process.",
+	},
+	CodeOctopusPackageNotFound: {
+		category:    Config,
+		remediation: "Set the package in the Metadata.ArgoCD.Application[namespace/application].ImageForPackageVersion[step:package] variable to the name of a package referenced by the step.",
+	},
+	CodeMappingApplicationInvalid: {
+		category:    Validation,
+		remediation: "The application in metadata variables must be in the format namespace/application.",
+	},
+	CodeMappingEnvironmentMissing: {
+		category:    Config,
+		remediation: "Define the Metadata.ArgoCD.Application[namespace/application].Environment variable for the application.",
+	},
+	CodeArgoConfigMissing: {
+		category:    Config,
+		remediation: "Define the ARGOCD_SERVER and ARGOCD_TOKEN environment variables.",
+	},
+	CodeArgoClientFailed: {
+		category:    Config,
+		remediation: "Check that the ARGOCD_SERVER and ARGOCD_TOKEN environment variables are valid.",
+	},
+	CodeArgoRequestFailed: {
+		category:    Transient,
+		remediation: "Check that ArgoCD is available and the ARGOCD_TOKEN environment variable is valid.",
+	},
+	CodeArgoApplicationNotFound: {
+		category:    NotFound,
+		remediation: "Check the application and namespace sent in the notification exist in ArgoCD.",
+	},
+	CodeArgoProjectNotFound: {
+		category:    NotFound,
+		remediation: "Check the project assigned to the application exists in ArgoCD.",
+	},
+	CodeVersionerFailed: {
+		category:    Config,
+		remediation: "The release version could not be generated.",
+	},
+}
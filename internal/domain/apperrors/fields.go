@@ -0,0 +1,31 @@
+package apperrors
+
+import "go.uber.org/zap"
+
+// Fields returns the error code, category, context, and remediation hint as structured log fields.
+func Fields(err error) []zap.Field {
+	appError := Classify(err)
+
+	if appError == nil {
+		return nil
+	}
+
+	fields := []zap.Field{
+		zap.String("errorCode", string(appError.Code)),
+		zap.String("errorCategory", string(appError.Category)),
+	}
+
+	if appError.Application != "" {
+		fields = append(fields, zap.String("application", appError.Application))
+	}
+
+	if appError.Project != "" {
+		fields = append(fields, zap.String("project", appError.Project))
+	}
+
+	if appError.Remediation != "" {
+		fields = append(fields, zap.String("remediation", appError.Remediation))
+	}
+
+	return fields
+}
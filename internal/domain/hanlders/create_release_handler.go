@@ -1,31 +1,109 @@
 package hanlders
 
 import (
+	"context"
 	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/versioners"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/argocd_apis"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/coordination"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/metrics"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/tracing"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/workers"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
-	"github.com/avast/retry-go"
 	"github.com/samber/lo"
+	"go.uber.org/zap"
 	"strings"
 	"sync"
 	"time"
 )
 
 type CreateReleaseHandler struct {
-	logger          apploggers.AppLogger
-	octo            octopus_apis.OctopusClient
-	argo            *argocd_apis.ArgoCDClient
-	versioner       versioners.ReleaseVersioner
+	logger    apploggers.AppLogger
+	octo      octopus_apis.OctopusClient
+	argo      argocd_apis.ArgoClient
+	versioner versioners.ReleaseVersioner
+	locker    coordination.ProjectLocker
+	// audit records the actions taken by the handler, and is nil if the audit log is disabled
+	audit audit.AuditSink
+	// status tracks the recent notifications shown in the status report
+	status          *statusTracker
 	projectReleases sync.Map
+	// notifications processes the incoming notifications, serialised by application
+	notifications *workers.Pool
+	// releases processes the release jobs, serialised by Octopus project
+	releases *workers.Pool
+	// releasesInFlight counts the release jobs that are queued, running, or waiting to be retried
+	releasesInFlight sync.WaitGroup
 }
 
+// projectRelease tracks the latest release job for a project, allowing older jobs to be cancelled when they
+// are superseded.
+type projectRelease struct {
+	added  time.Time
+	cancel context.CancelFunc
+}
+
+// notificationCompletion calls done once every release queued for a notification has finished, with the errors of
+// the releases that failed. Releases that were superseded or dropped are not errors.
+type notificationCompletion struct {
+	mutex   sync.Mutex
+	pending int
+	err     error
+	done    func(err error)
+}
+
+func newNotificationCompletion(done func(err error)) *notificationCompletion {
+	return &notificationCompletion{done: done}
+}
+
+// add records releases that must finish before the notification is complete.
+func (n *notificationCompletion) add(releases int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.pending += releases
+}
+
+// finish records a finished release, calling done when it was the last release.
+func (n *notificationCompletion) finish(err error) {
+	n.mutex.Lock()
+	n.pending--
+	n.err = errors.Join(n.err, err)
+	pending, joinedErr := n.pending, n.err
+	n.mutex.Unlock()
+
+	if pending == 0 && n.done != nil {
+		n.done(joinedErr)
+	}
+}
+
+// HandlerConfig holds the clients and settings of a CreateReleaseHandler.
+type HandlerConfig struct {
+	Octopus octopus_apis.OctopusClient
+	// ArgoCD is used to find the images deployed by an application, and may be nil
+	ArgoCD argocd_apis.ArgoClient
+	// Locker coordinates releases between replicas, and is nil if the proxy runs as a single replica
+	Locker coordination.ProjectLocker
+	// Audit records the actions taken by the handler, and is nil if the audit log is disabled
+	Audit audit.AuditSink
+	// StatusHistorySize is the number of recent notifications included in the status report
+	StatusHistorySize int
+	// PoolSize is the number of notifications and releases processed concurrently
+	PoolSize int
+	// QueueSize is the number of notifications and releases that can be queued
+	QueueSize int
+}
+
+// NewCreateReleaseHandler creates a handler configured by environment variables.
 func NewCreateReleaseHandler() (*CreateReleaseHandler, error) {
-	logger, err := apploggers.NewDevProdLogger()
+	err := retry_config.LoadPolicies()
 
 	if err != nil {
 		return nil, err
@@ -43,111 +121,511 @@ func NewCreateReleaseHandler() (*CreateReleaseHandler, error) {
 		return nil, err
 	}
 
+	locker, err := coordination.NewProjectLocker()
+
+	if err != nil {
+		return nil, err
+	}
+
+	auditSink, err := audit.NewDefaultSink()
+
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := newDefaultStatusTracker()
+
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := workers.NewDefaultPool()
+
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := workers.NewDefaultPool()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newCreateReleaseHandler(octo, argocdClient, locker, auditSink, status, notifications, releases)
+}
+
+// NewCreateReleaseHandlerFromConfig creates a handler with the supplied clients and settings.
+func NewCreateReleaseHandlerFromConfig(config HandlerConfig) (*CreateReleaseHandler, error) {
+	if config.StatusHistorySize <= 0 {
+		config.StatusHistorySize = DefaultStatusHistorySize
+	}
+
+	if config.PoolSize <= 0 {
+		config.PoolSize = workers.DefaultPoolSize
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = workers.DefaultQueueSize
+	}
+
+	return newCreateReleaseHandler(
+		config.Octopus,
+		config.ArgoCD,
+		config.Locker,
+		config.Audit,
+		newStatusTracker(config.StatusHistorySize),
+		workers.NewPool(config.PoolSize, config.QueueSize),
+		workers.NewPool(config.PoolSize, config.QueueSize))
+}
+
+func newCreateReleaseHandler(
+	octo octopus_apis.OctopusClient,
+	argo argocd_apis.ArgoClient,
+	locker coordination.ProjectLocker,
+	auditSink audit.AuditSink,
+	status *statusTracker,
+	notifications *workers.Pool,
+	releases *workers.Pool) (*CreateReleaseHandler, error) {
+	logger, err := apploggers.NewDevProdLogger()
+
+	if err != nil {
+		return nil, err
+	}
+
 	return &CreateReleaseHandler{
 		logger:          logger,
 		octo:            octo,
-		argo:            argocdClient,
+		argo:            argo,
 		versioner:       &versioners.SimpleRedeploymentVersioner{},
+		locker:          locker,
+		audit:           auditSink,
+		status:          status,
 		projectReleases: sync.Map{},
+		notifications:   notifications,
+		releases:        releases,
 	}, nil
 }
 
-// CreateRelease will attempt to create a release for up to two hours, which takes the standard maintenance window
-// of a cloud hosted instanced into account.
-func (c CreateReleaseHandler) CreateRelease(applicationUpdateMessage models.ApplicationUpdateMessage) error {
+// Enqueue queues the notification to be processed in the background. Notifications for the same application are
+// processed in the order they were received. workers.ErrQueueFull is returned if too many notifications are queued.
+// If the notification was queued, done is called once every release for the notification has finished, with an
+// error if the notification or any of its releases failed. done may be nil.
+func (c *CreateReleaseHandler) Enqueue(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage, done func(err error)) error {
+	ctx = c.notificationContext(ctx, applicationUpdateMessage)
+
+	err := c.notifications.Submit(applicationUpdateMessage.Namespace+"/"+applicationUpdateMessage.Application, func() {
+		err := c.createRelease(ctx, applicationUpdateMessage, newNotificationCompletion(done))
+		if err != nil {
+			metrics.RecordError("notification", err)
+			apploggers.FromContext(ctx, c.logger).GetLogger().Error("octoargosync-init-octocreatereleaseerror: Failed to create a release: "+err.Error(), apperrors.Fields(err)...)
+		}
+	})
+
+	if err != nil {
+		code := lo.Ternary(errors.Is(err, workers.ErrQueueFull), apperrors.CodeQueueFull, apperrors.CodeCancelled)
+		c.notificationFailed(ctx, applicationUpdateMessage, apperrors.Wrap(code, "failed to queue the notification", err))
+	}
+
+	return err
+}
+
+// CreateRelease will attempt to create a release according to the Handler retry policy, which by default retries for
+// up to two hours to take the standard maintenance window of a cloud hosted instanced into account. The releases are
+// queued to be created in the background one project at a time, and are cancelled when the supplied context is
+// cancelled, or when a newer release for the same project supersedes them.
+func (c *CreateReleaseHandler) CreateRelease(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage) error {
+	return c.createRelease(c.notificationContext(ctx, applicationUpdateMessage), applicationUpdateMessage, newNotificationCompletion(nil))
+}
+
+// createRelease queues the releases for a notification, logging with the logger in the context. The completion is
+// finished when each release finishes, or immediately if the notification fails or no releases are queued.
+func (c *CreateReleaseHandler) createRelease(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage, completion *notificationCompletion) (err error) {
+	logger := apploggers.FromContext(ctx, c.logger)
+
+	// The notification itself is tracked as a release, so the completion finishes once the releases are queued
+	completion.add(1)
+	defer func() { completion.finish(err) }()
 
-	images, err := c.getImages(applicationUpdateMessage)
+	images, err := c.getImages(ctx, applicationUpdateMessage)
 
 	// We can gracefully fall back if the connection back to argo failed
 	if err == nil {
 		applicationUpdateMessage.Images = images
 	} else {
 		applicationUpdateMessage.Images = []string{}
-		c.logger.GetLogger().Error("octoargosync-init-argoappimages: Failed to get the list of images from Argo CD. " +
-			"Verify the ARGOCD_SERVER and ARGOCD_TOKEN environment variables are valid. " +
-			"The Octopus release version will not use any image version. " + err.Error())
+		metrics.RecordError("images", err)
+		logger.GetLogger().Error("octoargosync-init-argoappimages: Failed to get the list of images from Argo CD. "+
+			"Verify the ARGOCD_SERVER and ARGOCD_TOKEN environment variables are valid. "+
+			"The Octopus release version will not use any image version. "+err.Error(), apperrors.Fields(err)...)
 	}
 
-	c.logger.GetLogger().Info("Received message from " + applicationUpdateMessage.Application + " in namespace " +
-		applicationUpdateMessage.Namespace + " for SHA " + applicationUpdateMessage.CommitSha + " and release version " +
-		applicationUpdateMessage.TargetRevision + " which includes the images " + strings.Join(applicationUpdateMessage.Images, ","))
+	logger.GetLogger().Info("Received notification",
+		zap.String("commitSha", applicationUpdateMessage.CommitSha),
+		zap.String("targetRevision", applicationUpdateMessage.TargetRevision),
+		zap.Strings("images", applicationUpdateMessage.Images))
+	c.recordAudit(ctx, newAuditEvent(audit.EventNotificationReceived, applicationUpdateMessage, nil,
+		"Commit "+applicationUpdateMessage.CommitSha+" and target revision "+applicationUpdateMessage.TargetRevision+
+			" with the images "+strings.Join(applicationUpdateMessage.Images, ", ")))
 
-	expandedProjects, err := c.octo.GetProjects(applicationUpdateMessage)
+	expandedProjects, err := c.octo.GetProjects(ctx, applicationUpdateMessage)
 
 	if err != nil {
+		c.notificationFailed(ctx, applicationUpdateMessage, err)
 		return err
 	}
 
 	if len(expandedProjects) == 0 {
-		c.logger.GetLogger().Info("No projects found configured for " + applicationUpdateMessage.Application + " in namespace " + applicationUpdateMessage.Namespace)
-		c.logger.GetLogger().Info("To create releases for this application, add the Metadata.ArgoCD.Application[" +
+		c.recordAudit(ctx, newAuditEvent(audit.EventNoProjectMatched, applicationUpdateMessage, nil, "No Octopus projects are mapped to the application"))
+		logger.GetLogger().Info("No projects found configured for the application")
+		logger.GetLogger().Info("To create releases for this application, add the Metadata.ArgoCD.Application[" +
 			applicationUpdateMessage.Namespace + "/" + applicationUpdateMessage.Application + "].EnvironmentName variable with a value matching the application's environment name, like \"Development\"")
 	}
 
+	completion.add(len(expandedProjects))
+
 	for _, project := range expandedProjects {
+		project := project
+		c.recordAudit(ctx, newAuditEvent(audit.EventProjectMatched, applicationUpdateMessage, &project, ""))
+
 		added := time.Now()
-		c.projectReleases.Store(project.Project.ID, added)
-		go func(project models.ArgoCDProjectExpanded, projectReleases *sync.Map, added time.Time) {
-			err := retry.Do(
-				func() error {
-					// Check to see if another release was created after this one. In this case we drop the old release
-					// assuming the newer one is what should be passed to Octopus. This can happen if multiple
-					// releases were in a retry loop, a new release is added just as Octopus come back online,
-					// meaning we drop the old releases.
-					if lastAdded, exists := projectReleases.Load(project.Project.ID); exists {
-						if lastAddedTime, ok := lastAdded.(time.Time); ok {
-							if lastAddedTime.After(added) {
-								return nil
-							}
-						}
-					}
-
-					// The other edge case we want to catch is if another instance of the proxy has created a release
-					// after this release was first supposed to be created. If so, we drop this release as it is
-					// old now and should not appear to be the latest deployment.
-					lastestRelease, err := c.octo.GetLatestDeploymentRelease(project.Project, project.Environment)
-
-					if err != nil {
-						return err
-					}
-
-					if lastestRelease != nil && lastestRelease.Assembled.After(added) {
-						return nil
-					}
-
-					// It is conceivable that other race conditions can occur. Multiple proxies receiving many
-					// requests to create a release for a project on an Octopus instance that is not responding
-					// might lead to multiple releases being created in the wrong order. However, that scenario
-					// assumes many releases happening in quick succession, and in such an environment, the
-					// Octopus dashboard will soon correct itself again. So we don't try to enforce any strict
-					// synchronisation between proxies, and rely on the fact that releases will eventually be
-					// consistent.
-
-					version, err := c.versioner.GenerateReleaseVersion(project, applicationUpdateMessage)
-
-					if err != nil {
-						return err
-					}
-
-					return c.octo.CreateAndDeployRelease(project, applicationUpdateMessage, version)
-				}, retry_config.HandlerRetryOptions...)
-
-			// We really, really tried to create the release, but there is nothing left to do but print an error.
-			if err != nil {
-				c.logger.GetLogger().Error("octoargosync-release-failed: Failed to create a release: " + err.Error())
+		jobCtx, cancel := context.WithCancel(ctx)
+		release := &projectRelease{added: added, cancel: cancel}
+		projectLogger := logger.With(
+			apploggers.Project(project.Project.Name),
+			apploggers.Environment(project.Environment.Name))
+		previous, loaded := c.projectReleases.Swap(project.Project.ID, release)
+
+		job := &releaseJob{
+			handler:    c,
+			ctx:        ctx,
+			jobCtx:     jobCtx,
+			cancel:     cancel,
+			project:    project,
+			message:    applicationUpdateMessage,
+			added:      added,
+			logger:     projectLogger,
+			retrier:    retry_config.NewRetrier(retry_config.Handler),
+			completion: completion,
+		}
+
+		c.releasesInFlight.Add(1)
+		err := job.submit()
+
+		if err != nil {
+			// A full release queue is treated like a failed attempt, so the release is queued again after the delay
+			// defined by the retry policy rather than dropped
+			job.retry(apperrors.Wrap(apperrors.CodeQueueFull, "failed to queue the release", err))
+		}
+
+		// Any older release still in a retry loop is superseded by this one, so cancel it
+		if loaded {
+			if previousRelease, ok := previous.(*projectRelease); ok {
+				previousRelease.cancel()
 			}
-		}(project, &c.projectReleases, added)
+		}
 	}
 
 	return nil
 }
 
-func (c CreateReleaseHandler) getImages(applicationUpdateMessage models.ApplicationUpdateMessage) ([]string, error) {
+// Simulate runs the same pipeline as CreateRelease and returns a plan describing the releases that would be created
+// or reused for each matching project. Nothing is written to Octopus. Errors that only affect a single project are
+// reported in the plan for that project.
+func (c *CreateReleaseHandler) Simulate(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage) (models.ReleasePlan, error) {
+	plan := models.ReleasePlan{
+		Application: applicationUpdateMessage.Application,
+		Namespace:   applicationUpdateMessage.Namespace,
+		Projects:    []models.ProjectReleasePlan{},
+	}
+
+	images, err := c.getImages(ctx, applicationUpdateMessage)
+
+	if err == nil {
+		applicationUpdateMessage.Images = images
+	} else {
+		// Any images supplied in the message are used if Argo CD could not be queried
+		plan.Warnings = append(plan.Warnings, "Failed to get the list of images from Argo CD: "+err.Error())
+	}
+
+	if applicationUpdateMessage.Images == nil {
+		applicationUpdateMessage.Images = []string{}
+	}
+
+	plan.Images = applicationUpdateMessage.Images
+
+	expandedProjects, err := c.octo.GetProjects(ctx, applicationUpdateMessage)
+
+	if err != nil {
+		return plan, err
+	}
+
+	if len(expandedProjects) == 0 {
+		plan.Warnings = append(plan.Warnings, "No projects found configured for "+applicationUpdateMessage.Application+" in namespace "+
+			applicationUpdateMessage.Namespace+". Add the Metadata.ArgoCD.Application["+applicationUpdateMessage.Namespace+"/"+
+			applicationUpdateMessage.Application+"].Environment variable to a project to create releases for this application.")
+	}
+
+	for _, project := range expandedProjects {
+		projectPlan, err := c.simulateProjectRelease(ctx, project, applicationUpdateMessage)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return plan, ctx.Err()
+			}
+
+			errorResponse := models.NewErrorResponse(err)
+			projectPlan.Error = &errorResponse
+		}
+
+		plan.Projects = append(plan.Projects, projectPlan)
+	}
+
+	return plan, nil
+}
+
+// simulateProjectRelease generates the release version and plans the release for a single project.
+func (c *CreateReleaseHandler) simulateProjectRelease(ctx context.Context, project models.ArgoCDProjectExpanded, applicationUpdateMessage models.ApplicationUpdateMessage) (models.ProjectReleasePlan, error) {
+	projectPlan := models.ProjectReleasePlan{
+		ProjectID:   project.Project.ID,
+		Project:     project.Project.Name,
+		Environment: project.Environment.Name,
+		Channel:     project.Channel.Name,
+		Lifecycle:   project.Lifecycle.Name,
+	}
+
+	version, err := c.generateReleaseVersion(ctx, project, applicationUpdateMessage)
+
+	if err != nil {
+		return projectPlan, err
+	}
+
+	projectPlan.Version = string(version)
+
+	plannedRelease, err := c.octo.PlanRelease(ctx, project, applicationUpdateMessage, version)
+
+	if err != nil {
+		return projectPlan, err
+	}
+
+	return plannedRelease, nil
+}
+
+// ValidateMappings returns the problems with the metadata variables that map ArgoCD applications to Octopus projects.
+func (c *CreateReleaseHandler) ValidateMappings(ctx context.Context) ([]models.MappingProblem, error) {
+	return c.octo.ValidateMappings(ctx)
+}
+
+// GetMappingInventory returns the Octopus projects each ArgoCD application creates releases in. The namespace and
+// project filters are ignored when empty, and the project filter matches either the project name or ID.
+func (c *CreateReleaseHandler) GetMappingInventory(ctx context.Context, namespace string, project string) ([]models.ApplicationInventory, error) {
+	mappings, err := c.octo.GetMappings(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := []models.ApplicationInventory{}
+	for _, mapping := range mappings {
+		if namespace != "" && mapping.Namespace != namespace {
+			continue
+		}
+
+		if project != "" && mapping.Project != project && mapping.ProjectID != project {
+			continue
+		}
+
+		// The mappings are sorted by application, so the projects for an application are adjacent
+		if len(inventory) == 0 ||
+			inventory[len(inventory)-1].Namespace != mapping.Namespace ||
+			inventory[len(inventory)-1].Application != mapping.Application {
+			inventory = append(inventory, models.ApplicationInventory{
+				Namespace:   mapping.Namespace,
+				Application: mapping.Application,
+				Projects:    []models.ApplicationMapping{},
+			})
+		}
+
+		inventory[len(inventory)-1].Projects = append(inventory[len(inventory)-1].Projects, mapping)
+	}
+
+	return inventory, nil
+}
+
+// InvalidateOctopusCache evicts the cached resources changed by an Octopus event, returning the evicted entries.
+func (c *CreateReleaseHandler) InvalidateOctopusCache(event models.OctopusEvent) []string {
+	// Deployments and other events that do not change a resource are ignored
+	if event.Category != "Created" && event.Category != "Modified" && event.Category != "Deleted" {
+		return []string{}
+	}
+
+	evicted := c.octo.Invalidate(event.RelatedDocumentIds)
+
+	c.logger.GetLogger().Info("Octopus event " + event.Id + " evicted " + strings.Join(evicted, ", "))
+
+	return evicted
+}
+
+// Wait blocks until all the queued notifications and release jobs have completed. Cancel the context passed to
+// CreateRelease or Enqueue to have the jobs exit early.
+func (c *CreateReleaseHandler) Wait() {
+	c.notifications.Wait()
+	c.releasesInFlight.Wait()
+	c.releases.Wait()
+}
+
+// lockAndCreateProjectRelease creates the release while holding the project lock shared between proxy replicas.
+// If coordination between replicas is disabled, the release is created directly.
+func (c *CreateReleaseHandler) lockAndCreateProjectRelease(ctx context.Context, project models.ArgoCDProjectExpanded, applicationUpdateMessage models.ApplicationUpdateMessage, projectReleases *sync.Map, added time.Time) error {
+	if c.locker == nil {
+		return c.createProjectRelease(ctx, project, applicationUpdateMessage, projectReleases, added)
+	}
+
+	lock, err := c.locker.Acquire(ctx, project.Project.ID)
+
+	if err != nil {
+		return err
+	}
+
+	processed := lock.LastProcessed()
+
+	// Another replica has processed a newer update for this project, so this update is dropped to preserve ordering
+	if processed.Time.After(added) {
+		apploggers.FromContext(ctx, c.logger).GetLogger().Info("Dropping the release as a newer update has been processed by another proxy")
+		c.recordAudit(ctx, newAuditEvent(audit.EventReleaseDropped, applicationUpdateMessage, &project,
+			"A newer update for the project was processed by another proxy"))
+		return c.releaseLock(lock, processed)
+	}
+
+	// Stop creating the release if the lock could not be renewed, as another replica may now hold it
+	lockCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-lock.Lost():
+			apploggers.FromContext(ctx, c.logger).GetLogger().Warn("Cancelling the release as the project lock was lost")
+			cancel()
+		case <-lockCtx.Done():
+		}
+	}()
+
+	err = c.createProjectRelease(lockCtx, project, applicationUpdateMessage, projectReleases, added)
+
+	if err == nil {
+		processed = coordination.ProcessedUpdate{Time: added}
+	}
+
+	return errors.Join(err, c.releaseLock(lock, processed))
+}
+
+// releaseLock releases a project lock. A new context is used so the lock is released even if the job was cancelled.
+func (c *CreateReleaseHandler) releaseLock(lock coordination.ProjectLock, processed coordination.ProcessedUpdate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return lock.Release(ctx, processed)
+}
+
+// createProjectRelease creates and deploys the release for a single project.
+func (c *CreateReleaseHandler) createProjectRelease(ctx context.Context, project models.ArgoCDProjectExpanded, applicationUpdateMessage models.ApplicationUpdateMessage, projectReleases *sync.Map, added time.Time) error {
+	// Check to see if another release was created after this one. In this case we drop the old release
+	// assuming the newer one is what should be passed to Octopus. This can happen if multiple
+	// releases were in a retry loop, a new release is added just as Octopus come back online,
+	// meaning we drop the old releases.
+	if lastAdded, exists := projectReleases.Load(project.Project.ID); exists {
+		if lastAddedRelease, ok := lastAdded.(*projectRelease); ok {
+			if lastAddedRelease.added.After(added) {
+				c.recordAudit(ctx, newAuditEvent(audit.EventReleaseSuperseded, applicationUpdateMessage, &project,
+					"A newer notification for the project was received"))
+				return nil
+			}
+		}
+	}
+
+	// The other edge case we want to catch is if another instance of the proxy has created a release
+	// after this release was first supposed to be created. If so, we drop this release as it is
+	// old now and should not appear to be the latest deployment.
+	lastestRelease, err := c.octo.GetLatestDeploymentRelease(ctx, project.Project, project.Environment)
+
+	if err != nil {
+		return err
+	}
+
+	if lastestRelease != nil && lastestRelease.Assembled.After(added) {
+		event := newAuditEvent(audit.EventReleaseDropped, applicationUpdateMessage, &project,
+			"A newer release was already deployed to the environment")
+		event.ReleaseId = lastestRelease.ID
+		c.recordAudit(ctx, event)
+		return nil
+	}
+
+	// It is conceivable that other race conditions can occur. Multiple proxies receiving many
+	// requests to create a release for a project on an Octopus instance that is not responding
+	// might lead to multiple releases being created in the wrong order. However, that scenario
+	// assumes many releases happening in quick succession, and in such an environment, the
+	// Octopus dashboard will soon correct itself again. Strict synchronisation between proxies
+	// is enforced by the project locks when the COORDINATION_BACKEND environment variable is set.
+	// Otherwise, we rely on the fact that releases will eventually be consistent.
+
+	version, err := c.generateReleaseVersion(ctx, project, applicationUpdateMessage)
+
+	if err != nil {
+		return err
+	}
+
+	versionEvent := newAuditEvent(audit.EventVersionComputed, applicationUpdateMessage, &project, "")
+	versionEvent.Version = string(version)
+	c.recordAudit(ctx, versionEvent)
+
+	ctx = apploggers.WithLogger(ctx, apploggers.FromContext(ctx, c.logger).With(apploggers.Version(string(version))))
+
+	result, err := c.octo.CreateAndDeployRelease(ctx, project, applicationUpdateMessage, version)
+
+	// A release may have been created even if the deployment failed
+	c.recordReleaseAudit(ctx, project, applicationUpdateMessage, version, result)
+
+	return err
+}
+
+// notificationContext returns a copy of the context holding the notification's correlation ID, and a logger that adds
+// the correlation ID and application to every log entry. A correlation ID is generated if the context does not have one.
+func (c *CreateReleaseHandler) notificationContext(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage) context.Context {
+	correlationId := apploggers.CorrelationIdFromContext(ctx)
+	if correlationId == "" {
+		correlationId = apploggers.NewCorrelationId()
+		ctx = apploggers.WithCorrelationId(ctx, correlationId)
+	}
+
+	return apploggers.WithLogger(ctx, c.logger.With(
+		apploggers.CorrelationId(correlationId),
+		apploggers.Application(applicationUpdateMessage.Application),
+		apploggers.Namespace(applicationUpdateMessage.Namespace)))
+}
+
+// generateReleaseVersion generates the release version for a project with the configured versioner.
+func (c *CreateReleaseHandler) generateReleaseVersion(ctx context.Context, project models.ArgoCDProjectExpanded, applicationUpdateMessage models.ApplicationUpdateMessage) (_ types.OctopusReleaseVersion, err error) {
+	ctx, span := tracing.Start(ctx, "ReleaseVersioner.GenerateReleaseVersion",
+		tracing.ProjectKey.String(project.Project.Name),
+		tracing.EnvironmentKey.String(project.Environment.Name))
+	defer func() { tracing.End(span, err) }()
+
+	version, err := c.versioner.GenerateReleaseVersion(ctx, project, applicationUpdateMessage)
+	span.SetAttributes(tracing.VersionKey.String(string(version)))
+
+	return version, err
+}
+
+func (c *CreateReleaseHandler) getImages(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage) (_ []string, err error) {
+	ctx, span := tracing.Start(ctx, "CreateReleaseHandler.getImages",
+		tracing.ApplicationKey.String(applicationUpdateMessage.Application),
+		tracing.NamespaceKey.String(applicationUpdateMessage.Namespace))
+	defer func() { tracing.End(span, err) }()
+
 	if c.argo == nil {
 		return nil, errors.New("the agro client is nil")
 	}
 
-	tree, err := c.argo.GetApplicationResourceTree(applicationUpdateMessage.Application, applicationUpdateMessage.Namespace)
+	tree, err := c.argo.GetApplicationResourceTree(ctx, applicationUpdateMessage.Application, applicationUpdateMessage.Namespace)
 
 	if err != nil {
 		return nil, err
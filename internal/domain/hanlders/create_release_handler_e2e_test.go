@@ -0,0 +1,417 @@
+package hanlders
+
+import (
+	"context"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/versioners"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/argocd_apis"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/fakes"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createFakeBackends starts a fake Octopus server with a project mapped to the argocd/myapp application, and a fake
+// ArgoCD server where the application deploys nginx 1.26.0 and busybox 1.36.1. The project uses the nginx image tag
+// for the release version and the version of the Deploy step's package.
+func createFakeBackends(t *testing.T) (*fakes.FakeOctopusServer, *fakes.FakeArgoCDServer) {
+	octopus := fakes.NewFakeOctopusServer()
+	t.Cleanup(octopus.Close)
+
+	development := octopus.AddEnvironment("Development")
+	lifecycle := octopus.AddLifecycle("Default", &lifecycles.Phase{
+		Name:                      "Development",
+		OptionalDeploymentTargets: []string{development},
+	})
+	project := octopus.AddProject("Project 1", lifecycle)
+	feed := octopus.AddFeed("Docker Hub")
+	octopus.AddPackageVersions(feed, "nginx", "1.25.0", "1.24.0")
+	octopus.AddPackageVersions(feed, "busybox", "1.36.0")
+	octopus.AddDeploymentStep(project, "Deploy", feed, "nginx", "web")
+	octopus.AddDeploymentStep(project, "Migrate", feed, "busybox", "")
+	octopus.AddVariable(project, "Metadata.ArgoCD.Application[argocd/myapp].Environment", "Development")
+	octopus.AddVariable(project, "Metadata.ArgoCD.Application[argocd/myapp].ImageForReleaseVersion", "nginx")
+	octopus.AddVariable(project, "Metadata.ArgoCD.Application[argocd/myapp].ImageForPackageVersion[Deploy:web]", "nginx")
+
+	argo, err := fakes.NewFakeArgoCDServer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(argo.Close)
+
+	argo.AddProject("default")
+	argo.AddApplication("argocd", "myapp", "default", "nginx:1.26.0", "busybox:1.36.1")
+
+	return octopus, argo
+}
+
+// createLiveReleaseHandler creates a handler with the live Octopus and ArgoCD clients connected to the fake servers.
+func createLiveReleaseHandler(t *testing.T, octopus *fakes.FakeOctopusServer, argo *fakes.FakeArgoCDServer) *CreateReleaseHandler {
+	t.Setenv("OCTOPUS_SERVER", octopus.URL())
+	t.Setenv("OCTOPUS_API_KEY", fakes.FakeOctopusApiKey)
+	t.Setenv("OCTOPUS_SPACE_ID", fakes.FakeOctopusSpaceId)
+	t.Setenv("ARGOCD_SERVER", argo.Address())
+	t.Setenv("ARGOCD_TOKEN", fakes.FakeArgoCDToken)
+	t.Setenv("ARGOCD_PLAINTEXT", "true")
+
+	octo, err := octopus_apis.NewLiveOctopusClient()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	argoClient, err := argocd_apis.NewClient()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, octo)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.argo = argoClient
+
+	return handler
+}
+
+// waitForDeployments waits for the background release jobs to create the expected number of deployments.
+func waitForDeployments(t *testing.T, octopus *fakes.FakeOctopusServer, count int) {
+	deadline := time.Now().Add(10 * time.Second)
+	for len(octopus.Deployments()) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d deployments, got %d", count, len(octopus.Deployments()))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestImageVersionedReleaseCreation(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	// The target revision is not a semver version, so the release version comes from the image tag
+	err := handler.CreateRelease(context.Background(), models.ApplicationUpdateMessage{
+		Application:    "myapp",
+		Namespace:      "argocd",
+		State:          "success",
+		TargetRevision: "main",
+		CommitSha:      "abcdefghijklmnop",
+		Project:        "default",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForDeployments(t, octopus, 1)
+
+	releases := octopus.Releases()
+
+	if len(releases) != 1 || releases[0].Version != "1.26.0" {
+		t.Fatalf("Expected release 1.26.0, got %+v", releases)
+	}
+
+	versions := map[string]string{}
+	for _, selectedPackage := range releases[0].SelectedPackages {
+		versions[selectedPackage.ActionName+":"+selectedPackage.PackageReferenceName] = selectedPackage.Version
+	}
+
+	// The mapped package uses the image deployed by ArgoCD, while the unmapped package uses the latest feed version
+	if versions["Deploy:web"] != "1.26.0" || versions["Migrate:"] != "1.36.0" {
+		t.Fatalf("Unexpected package versions %+v", versions)
+	}
+
+	if deployments := octopus.Deployments(); deployments[0].ReleaseID != releases[0].ID {
+		t.Fatalf("Expected the release to be deployed, got %+v", deployments)
+	}
+}
+
+func TestSimulateUsesArgoImages(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	argo.SetImages("argocd", "myapp", "nginx:1.27.0", "nginx:1.26.0")
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	plan, err := handler.Simulate(context.Background(), models.ApplicationUpdateMessage{
+		Application:    "myapp",
+		Namespace:      "argocd",
+		TargetRevision: "main",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Images) != 2 || len(plan.Projects) != 1 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+
+	// The newest tag of the release version image is used
+	projectPlan := plan.Projects[0]
+	if projectPlan.Version != "1.27.0" || projectPlan.ReleaseAction != models.ReleaseActionCreate {
+		t.Fatalf("Expected to create release 1.27.0, got %+v", projectPlan)
+	}
+
+	if len(octopus.Releases()) != 0 {
+		t.Fatal("Simulations must not create releases")
+	}
+}
+
+func TestSimulateWithoutArgoImages(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	argo.SetImages("argocd", "myapp")
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	plan, err := handler.Simulate(context.Background(), models.ApplicationUpdateMessage{
+		Application:    "myapp",
+		Namespace:      "argocd",
+		TargetRevision: "main",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Projects) != 1 {
+		t.Fatalf("Unexpected plan %+v", plan)
+	}
+
+	// Without images the release version falls back to a date, and the packages use the latest feed versions
+	projectPlan := plan.Projects[0]
+	if !strings.HasPrefix(projectPlan.Version, time.Now().Format("2006.")) {
+		t.Fatalf("Expected a date based version, got %+v", projectPlan)
+	}
+
+	if len(projectPlan.Packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %+v", projectPlan.Packages)
+	}
+
+	for _, packagePlan := range projectPlan.Packages {
+		if packagePlan.ActionName == "Deploy" && packagePlan.Version != "1.25.0" {
+			t.Fatalf("Expected the latest nginx version, got %+v", projectPlan.Packages)
+		}
+	}
+}
+
+func TestReleaseCreationIsTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previousProvider) })
+
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	err := handler.CreateRelease(context.Background(), models.ApplicationUpdateMessage{
+		Application:    "myapp",
+		Namespace:      "argocd",
+		TargetRevision: "main",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForDeployments(t, octopus, 1)
+	handler.Wait()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	for _, name := range []string{"CreateReleaseHandler.getImages", "LiveOctopusClient.GetProjects", "LiveOctopusClient.getProjectVariables",
+		"ReleaseVersioner.GenerateReleaseVersion", "LiveOctopusClient.getRelease", "LiveOctopusClient.getDefaultPackages", "Octopus Deployments.Add"} {
+		if _, found := spans[name]; !found {
+			t.Fatalf("Expected a span called %s, got %v", name, lo.Keys(spans))
+		}
+	}
+
+	// The project variables are loaded by the first refresh of the mapping index while looking up the projects
+	if spans["LiveOctopusClient.getProjectVariables"].Parent().SpanID() != spans["mappingIndex.refresh"].SpanContext().SpanID() ||
+		spans["mappingIndex.refresh"].Parent().SpanID() != spans["LiveOctopusClient.GetProjects"].SpanContext().SpanID() {
+		t.Fatal("Expected the variables to be loaded in a child span of GetProjects")
+	}
+
+	if spans["LiveOctopusClient.getDefaultPackages"].Parent().SpanID() != spans["LiveOctopusClient.getRelease"].SpanContext().SpanID() {
+		t.Fatal("Expected the default packages to be loaded in a child span of getRelease")
+	}
+}
+
+func TestReleaseLogsIncludeCorrelationFields(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler.logger = apploggers.NewAppLogger(zap.New(core))
+
+	ctx := apploggers.WithCorrelationId(context.Background(), "notification-1")
+	err := handler.Enqueue(ctx, models.ApplicationUpdateMessage{
+		Application:    "myapp",
+		Namespace:      "argocd",
+		TargetRevision: "main",
+	}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForDeployments(t, octopus, 1)
+	handler.Wait()
+
+	created := logs.FilterMessage("Deployed the release").All()
+	if len(created) != 1 {
+		t.Fatalf("Expected the deployment to be logged, got %v", logs.All())
+	}
+
+	fields := created[0].ContextMap()
+	expected := map[string]any{
+		"correlationId": "notification-1",
+		"application":   "myapp",
+		"namespace":     "argocd",
+		"project":       "Project 1",
+		"environment":   "Development",
+		"version":       "1.26.0",
+		"attempt":       int64(1),
+	}
+
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Fatalf("Expected the field %s to be %v, got %v", key, value, fields)
+		}
+	}
+
+	// Each field is only added once, even though the Octopus client adds the project fields for other callers
+	keys := lo.Map(created[0].Context, func(item zapcore.Field, index int) string { return item.Key })
+	if len(keys) != len(lo.Uniq(keys)) {
+		t.Fatalf("Expected unique fields, got %v", keys)
+	}
+
+	received := logs.FilterMessage("Received notification").FilterField(apploggers.CorrelationId("notification-1"))
+	if received.Len() != 1 {
+		t.Fatalf("Expected the notification to be logged with the correlation ID, got %v", logs.All())
+	}
+}
+
+func TestReleaseActionsAreAudited(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	sink, err := audit.NewFileSink(t.TempDir(), 1024*1024, audit.DefaultMaxFiles)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = sink.Close() })
+	handler.audit = sink
+
+	updateMessage := models.ApplicationUpdateMessage{
+		Application:    "myapp",
+		Namespace:      "argocd",
+		TargetRevision: "main",
+	}
+
+	// The second notification has the same images, so the release is reused and deployed again
+	for i, correlationId := range []string{"notification-1", "notification-2"} {
+		err := handler.CreateRelease(apploggers.WithCorrelationId(context.Background(), correlationId), updateMessage)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		waitForDeployments(t, octopus, i+1)
+		handler.Wait()
+	}
+
+	events, err := handler.QueryAudit(context.Background(), audit.Filter{Application: "argocd/myapp", Project: "Project 1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := lo.Map(events, func(item audit.Event, index int) string { return item.CorrelationId + " " + item.Type })
+	expected := []string{
+		"notification-1 " + audit.EventProjectMatched,
+		"notification-1 " + audit.EventVersionComputed,
+		"notification-1 " + audit.EventReleaseCreated,
+		"notification-1 " + audit.EventDeploymentCreated,
+		"notification-2 " + audit.EventProjectMatched,
+		"notification-2 " + audit.EventVersionComputed,
+		"notification-2 " + audit.EventReleaseReused,
+		"notification-2 " + audit.EventDeploymentCreated,
+	}
+
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the events %v, got %v", expected, actions)
+	}
+
+	deployments := octopus.Deployments()
+	if events[3].Version != "1.26.0" || events[3].ReleaseId != deployments[0].ReleaseID || events[3].DeploymentId != deployments[0].ID {
+		t.Fatalf("Expected the deployment event to describe the deployment, got %+v", events[3])
+	}
+
+	// The notifications are not specific to a project, so they are only returned when filtering by application
+	received, err := handler.QueryAudit(context.Background(), audit.Filter{Application: "myapp"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := lo.CountBy(received, func(item audit.Event) bool { return item.Type == audit.EventNotificationReceived }); count != 2 {
+		t.Fatalf("Expected 2 received notifications, got %+v", received)
+	}
+}
+
+func TestEnqueueReportsCompletion(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+	message := models.ApplicationUpdateMessage{Application: "myapp", Namespace: "argocd", TargetRevision: "main"}
+
+	done := make(chan error, 1)
+	err := handler.Enqueue(context.Background(), message, func(err error) { done <- err })
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the notification to complete, got %v", err)
+	}
+
+	if len(octopus.Deployments()) != 1 {
+		t.Fatal("Expected the notification to complete after the release was deployed")
+	}
+
+	// A notification that can not be processed completes with an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = handler.Enqueue(ctx, message, func(err error) { done <- err })
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err == nil {
+		t.Fatal("Expected the cancelled notification to complete with an error")
+	}
+
+	handler.Wait()
+}
@@ -1,16 +1,23 @@
 package hanlders
 
 import (
-	"github.com/OctopusDeploy/go-octopusdeploy/octopusdeploy"
+	"context"
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/versioners"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/coordination"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/workers"
 	"github.com/samber/lo"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 type createAndDeployReleaseDetails struct {
@@ -23,29 +30,41 @@ type mockOctopusClient struct {
 	createdRelease                chan bool
 	foundProjects                 chan bool
 	findProject                   bool
+	// octopusDown simulates an Octopus instance that is unavailable, forcing the handler into its retry loop
+	octopusDown bool
+	// projectsErr is returned when the projects mapped to an application are requested
+	projectsErr        error
+	checkedDeployments chan bool
 }
 
-func (c *mockOctopusClient) GetProjects(updateMessage models.ApplicationUpdateMessage) ([]models.ArgoCDProjectExpanded, error) {
+func (c *mockOctopusClient) GetProjects(ctx context.Context, updateMessage models.ApplicationUpdateMessage) ([]models.ArgoCDProjectExpanded, error) {
 	defer func() {
 		go func() { c.foundProjects <- true }()
 	}()
 
+	if c.projectsErr != nil {
+		return nil, c.projectsErr
+	}
+
 	if !c.findProject {
 		return nil, nil
 	}
 
+	project := &models.Project{
+		ID:   "Projects-1",
+		Name: "Project 1",
+	}
+
 	return []models.ArgoCDProjectExpanded{
 		models.ArgoCDProjectExpanded{
-			Project: &octopusdeploy.Project{
-				Name: "Project 1",
-			},
-			Environment: &octopusdeploy.Environment{
+			Project: project,
+			Environment: &models.Environment{
 				Name: "Development",
 			},
-			Channel: &octopusdeploy.Channel{
+			Channel: &models.Channel{
 				Name: "Default",
 			},
-			Lifecycle: &octopusdeploy.Lifecycle{
+			Lifecycle: &models.Lifecycle{
 				Name: "Default",
 			},
 			ReleaseVersionImage: "",
@@ -54,7 +73,7 @@ func (c *mockOctopusClient) GetProjects(updateMessage models.ApplicationUpdateMe
 	}, nil
 }
 
-func (c *mockOctopusClient) CreateAndDeployRelease(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) error {
+func (c *mockOctopusClient) CreateAndDeployRelease(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) (models.ReleaseResult, error) {
 	if c.createAndDeployReleaseDetails == nil {
 		c.createAndDeployReleaseDetails = []createAndDeployReleaseDetails{}
 	}
@@ -68,29 +87,92 @@ func (c *mockOctopusClient) CreateAndDeployRelease(project models.ArgoCDProjectE
 		go func() { c.createdRelease <- true }()
 	}()
 
+	return models.ReleaseResult{
+		ReleaseID:        "Releases-1",
+		ReleaseAction:    models.ReleaseActionCreate,
+		DeploymentID:     "Deployments-1",
+		DeploymentAction: models.DeploymentActionCreate,
+	}, nil
+}
+
+func (c *mockOctopusClient) PlanRelease(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) (models.ProjectReleasePlan, error) {
+	return models.ProjectReleasePlan{
+		ProjectID:        project.Project.ID,
+		Project:          project.Project.Name,
+		Environment:      project.Environment.Name,
+		Channel:          project.Channel.Name,
+		Lifecycle:        project.Lifecycle.Name,
+		Version:          string(version),
+		ReleaseAction:    models.ReleaseActionCreate,
+		DeploymentAction: models.DeploymentActionCreate,
+	}, nil
+}
+
+func (c *mockOctopusClient) GetMappings(ctx context.Context) ([]models.ApplicationMapping, error) {
+	return []models.ApplicationMapping{
+		{Namespace: "argocd", Application: "app1", ProjectID: "Projects-1", Project: "Project 1", Environment: "Development"},
+		{Namespace: "argocd", Application: "app1", ProjectID: "Projects-2", Project: "Project 2", Environment: "Development"},
+		{Namespace: "argocd", Application: "app2", ProjectID: "Projects-1", Project: "Project 1", Environment: "Test"},
+		{Namespace: "other", Application: "app1", ProjectID: "Projects-3", Project: "Project 3", Environment: "Production"},
+	}, nil
+}
+
+func (c *mockOctopusClient) ValidateMappings(ctx context.Context) ([]models.MappingProblem, error) {
+	return []models.MappingProblem{}, nil
+}
+
+func (c *mockOctopusClient) Invalidate(documentIds []string) []string {
+	return documentIds
+}
+
+func (c *mockOctopusClient) CheckConnection(ctx context.Context) error {
+	if c.octopusDown {
+		return errors.New("Octopus is down")
+	}
+
 	return nil
 }
 
-func (c *mockOctopusClient) GetReleaseVersions(project *octopusdeploy.Project) ([]types.OctopusReleaseVersion, error) {
+func (c *mockOctopusClient) CheckPermissions(ctx context.Context) ([]string, error) {
+	if c.octopusDown {
+		return nil, errors.New("Octopus is down")
+	}
+
+	return []string{}, nil
+}
+
+func (c *mockOctopusClient) GetStats() models.OctopusClientStats {
+	return models.OctopusClientStats{}
+}
+
+func (c *mockOctopusClient) GetReleaseVersions(ctx context.Context, project *models.Project) ([]types.OctopusReleaseVersion, error) {
 	return []types.OctopusReleaseVersion{
 		"0.0.1",
 		"0.0.2",
 	}, nil
 }
 
-func (c *mockOctopusClient) IsDeployed(project *octopusdeploy.Project, releaseVersion types.OctopusReleaseVersion, environment *octopusdeploy.Environment) (bool, error) {
+func (c *mockOctopusClient) IsDeployed(ctx context.Context, project *models.Project, releaseVersion types.OctopusReleaseVersion, environment *models.Environment) (bool, error) {
 	return releaseVersion == "0.0.1" || releaseVersion == "0.0.2", nil
 }
 
-func (c *mockOctopusClient) GetLatestRelease(project *octopusdeploy.Project) (*octopusdeploy.Release, error) {
-	return &octopusdeploy.Release{
+func (c *mockOctopusClient) GetLatestRelease(ctx context.Context, project *models.Project) (*models.Release, error) {
+	return &models.Release{
 		Version: "0.0.2",
 	}, nil
 }
 
-func (c *mockOctopusClient) GetLatestDeploymentRelease(project *octopusdeploy.Project, environment *octopusdeploy.Environment) (*octopusdeploy.Release, error) {
+func (c *mockOctopusClient) GetLatestDeploymentRelease(ctx context.Context, project *models.Project, environment *models.Environment) (*models.Release, error) {
+	if c.octopusDown {
+		defer func() {
+			go func() { c.checkedDeployments <- true }()
+		}()
+
+		return nil, errors.New("octopus is unavailable")
+	}
+
 	if environment.Name == "Development" {
-		return &octopusdeploy.Release{
+		return &models.Release{
 			Version: "0.0.2",
 		}, nil
 	}
@@ -112,6 +194,20 @@ func createMockOctopusClient(findProjects bool) (chan bool, chan bool, octopus_a
 	return calledChannel, foundProjects, client
 }
 
+func createUnavailableMockOctopusClient() (chan bool, octopus_apis.OctopusClient) {
+	checkedDeployments := make(chan bool)
+
+	client := &mockOctopusClient{
+		createdRelease:     make(chan bool),
+		foundProjects:      make(chan bool),
+		findProject:        true,
+		octopusDown:        true,
+		checkedDeployments: checkedDeployments,
+	}
+
+	return checkedDeployments, client
+}
+
 func createReleaseHandler(versioner versioners.ReleaseVersioner, client octopus_apis.OctopusClient) (*CreateReleaseHandler, error) {
 	logger, err := apploggers.NewDevProdLogger()
 
@@ -124,7 +220,10 @@ func createReleaseHandler(versioner versioners.ReleaseVersioner, client octopus_
 		octo:            client,
 		argo:            nil,
 		versioner:       versioner,
+		status:          newStatusTracker(DefaultStatusHistorySize),
 		projectReleases: sync.Map{},
+		notifications:   workers.NewPool(workers.DefaultPoolSize, workers.DefaultQueueSize),
+		releases:        workers.NewPool(workers.DefaultPoolSize, workers.DefaultQueueSize),
 	}, nil
 }
 
@@ -148,7 +247,7 @@ func TestNoProjects(t *testing.T) {
 		Project:        "default",
 	}
 
-	err = handler.CreateRelease(message)
+	err = handler.CreateRelease(context.Background(), message)
 
 	if err != nil {
 		t.Fatal(err)
@@ -183,7 +282,7 @@ func TestExistingReleaseCreation2(t *testing.T) {
 		Project:        "default",
 	}
 
-	err = handler.CreateRelease(message)
+	err = handler.CreateRelease(context.Background(), message)
 
 	if err != nil {
 		t.Fatal(err)
@@ -228,7 +327,7 @@ func TestExistingReleaseCreation(t *testing.T) {
 		Project:        "default",
 	}
 
-	err = handler.CreateRelease(message)
+	err = handler.CreateRelease(context.Background(), message)
 
 	if err != nil {
 		t.Fatal(err)
@@ -270,7 +369,7 @@ func TestNewReleaseCreation(t *testing.T) {
 		Project:        "default",
 	}
 
-	err = handler.CreateRelease(message)
+	err = handler.CreateRelease(context.Background(), message)
 
 	if err != nil {
 		t.Fatal(err)
@@ -291,3 +390,400 @@ func TestNewReleaseCreation(t *testing.T) {
 		t.Fatal("must have had a request to create a new release")
 	}
 }
+
+func TestCancelledReleaseCreation(t *testing.T) {
+	checkedDeployments, client := createUnavailableMockOctopusClient()
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := models.ApplicationUpdateMessage{
+		Application:    "myapplication",
+		Namespace:      "development",
+		State:          "success",
+		TargetUrl:      "",
+		TargetRevision: "0.0.3",
+		CommitSha:      "abcdefghijklmnop",
+		Images:         nil,
+		Project:        "default",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err = handler.CreateRelease(ctx, message)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the first attempt to fail, at which point the handler is waiting to retry
+	<-checkedDeployments
+
+	cancel()
+
+	finished := make(chan bool)
+	go func() {
+		handler.Wait()
+		finished <- true
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the release job must exit when the context is cancelled")
+	}
+
+	if len(handler.octo.(*mockOctopusClient).createAndDeployReleaseDetails) != 0 {
+		t.Fatal("must not have created a release")
+	}
+}
+
+func TestRetryDoesNotHoldWorker(t *testing.T) {
+	checkedDeployments, client := createUnavailableMockOctopusClient()
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = handler.CreateRelease(ctx, models.ApplicationUpdateMessage{
+		Application:    "myapplication",
+		Namespace:      "development",
+		TargetRevision: "0.0.3",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the first attempt to fail, at which point the handler is waiting to retry
+	<-checkedDeployments
+
+	// The worker is returned to the pool once the attempt has finished
+	deadline := time.Now().Add(10 * time.Second)
+	for handler.releases.Pending() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the release job must not hold a worker while waiting to retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	handler.Wait()
+}
+
+func TestReleaseRetriedWhenReleaseQueueIsFull(t *testing.T) {
+	retry_config.SetPolicies(map[retry_config.OperationClass]retry_config.Policy{retry_config.Handler: {
+		Attempts: 100,
+		Backoff:  retry_config.FixedBackoff,
+		Delay:    retry_config.Duration(10 * time.Millisecond),
+	}})
+	defer retry_config.SetPolicies(nil)
+
+	createdRelease, _, client := createMockOctopusClient(true)
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.releases = workers.NewPool(1, 1)
+	unblock := make(chan bool)
+
+	err = handler.releases.Submit("Projects-2", func() { <-unblock })
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The notification is accepted, and its release waits for room in the release queue
+	done := make(chan error, 1)
+	err = handler.Enqueue(context.Background(), models.ApplicationUpdateMessage{
+		Application:    "myapplication",
+		Namespace:      "development",
+		TargetRevision: "0.0.3",
+	}, func(err error) { done <- err })
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(unblock)
+
+	select {
+	case <-createdRelease:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the release to be created once the queue had room")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the notification to complete, got %v", err)
+	}
+
+	handler.Wait()
+}
+
+func TestNotificationFailuresAreAudited(t *testing.T) {
+	client := &mockOctopusClient{
+		foundProjects: make(chan bool),
+		projectsErr:   apperrors.New(apperrors.CodeOctopusUnauthorized, "the API key is invalid"),
+	}
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := audit.NewFileSink(t.TempDir(), 1024*1024, audit.DefaultMaxFiles)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = sink.Close() })
+	handler.audit = sink
+
+	updateMessage := models.ApplicationUpdateMessage{
+		Application: "myapplication",
+		Namespace:   "development",
+	}
+
+	err = handler.CreateRelease(apploggers.WithCorrelationId(context.Background(), "notification-1"), updateMessage)
+
+	if err == nil {
+		t.Fatal("Expected the projects to not be found")
+	}
+
+	handler.notifications = workers.NewPool(1, 1)
+	unblock := make(chan bool)
+	defer close(unblock)
+
+	err = handler.notifications.Submit("development/otherapplication", func() { <-unblock })
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = handler.Enqueue(apploggers.WithCorrelationId(context.Background(), "notification-2"), updateMessage, nil)
+
+	if !errors.Is(err, workers.ErrQueueFull) {
+		t.Fatalf("Expected the notification to be rejected, got %v", err)
+	}
+
+	events, err := handler.QueryAudit(context.Background(), audit.Filter{Application: "development/myapplication"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures := lo.FilterMap(events, func(item audit.Event, index int) (string, bool) {
+		return item.CorrelationId + " " + item.ErrorCode, item.Type == audit.EventNotificationFailed
+	})
+	expected := []string{
+		"notification-1 " + string(apperrors.CodeOctopusUnauthorized),
+		"notification-2 " + string(apperrors.CodeQueueFull),
+	}
+
+	if strings.Join(failures, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected the failures %v, got %v", expected, failures)
+	}
+}
+
+func TestReleaseDroppedWhenNewerUpdateProcessed(t *testing.T) {
+	_, _, client := createMockOctopusClient(true)
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locker, err := coordination.NewFileLocker(t.TempDir(), "replica1", coordination.DefaultLeaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.locker = locker
+
+	sink, err := audit.NewFileSink(t.TempDir(), 1024*1024, audit.DefaultMaxFiles)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+	handler.audit = sink
+
+	// Simulate another replica having processed a newer update for the project
+	lock, err := locker.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lock.Release(context.Background(), coordination.ProcessedUpdate{Time: time.Now().Add(time.Hour)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := models.ApplicationUpdateMessage{
+		Application:    "myapplication",
+		Namespace:      "development",
+		State:          "success",
+		TargetUrl:      "",
+		TargetRevision: "0.0.3",
+		CommitSha:      "abcdefghijklmnop",
+		Images:         nil,
+		Project:        "default",
+	}
+
+	err = handler.CreateRelease(context.Background(), message)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.Wait()
+
+	if len(handler.octo.(*mockOctopusClient).createAndDeployReleaseDetails) != 0 {
+		t.Fatal("must not have created a release")
+	}
+
+	events, err := handler.QueryAudit(context.Background(), audit.Filter{Project: "Project 1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[1].Type != audit.EventReleaseDropped {
+		t.Fatalf("Expected the dropped release to be audited, got %+v", events)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	_, _, client := createMockOctopusClient(true)
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := models.ApplicationUpdateMessage{
+		Application:    "myapplication",
+		Namespace:      "development",
+		State:          "success",
+		TargetUrl:      "",
+		TargetRevision: "0.0.3",
+		CommitSha:      "abcdefghijklmnop",
+		Images:         nil,
+		Project:        "default",
+	}
+
+	plan, err := handler.Simulate(context.Background(), message)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Projects) != 1 {
+		t.Fatalf("must have planned a release for one project, got %d", len(plan.Projects))
+	}
+
+	if plan.Projects[0].Project != "Project 1" || plan.Projects[0].Version != "0.0.3" || plan.Projects[0].ReleaseAction != models.ReleaseActionCreate {
+		t.Fatalf("unexpected plan %+v", plan.Projects[0])
+	}
+
+	// The argo client is nil, so the plan must report that images could not be found
+	if len(plan.Warnings) == 0 {
+		t.Fatal("must have warned that the images could not be found")
+	}
+
+	handler.Wait()
+
+	if len(handler.octo.(*mockOctopusClient).createAndDeployReleaseDetails) != 0 {
+		t.Fatal("must not have created a release")
+	}
+}
+
+func TestGetMappingInventory(t *testing.T) {
+	_, _, client := createMockOctopusClient(true)
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := handler.GetMappingInventory(context.Background(), "", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inventory) != 3 || len(inventory[0].Projects) != 2 {
+		t.Fatalf("must have grouped the projects by application, got %+v", inventory)
+	}
+
+	inventory, err = handler.GetMappingInventory(context.Background(), "argocd", "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inventory) != 2 || inventory[0].Application != "app1" || inventory[1].Application != "app2" {
+		t.Fatalf("must have filtered by namespace and project, got %+v", inventory)
+	}
+
+	inventory, err = handler.GetMappingInventory(context.Background(), "", "Project 3")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inventory) != 1 || inventory[0].Namespace != "other" {
+		t.Fatalf("must have filtered by project name, got %+v", inventory)
+	}
+}
+
+func TestInvalidateOctopusCache(t *testing.T) {
+	_, _, client := createMockOctopusClient(true)
+
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evicted := handler.InvalidateOctopusCache(models.OctopusEvent{
+		Id:                 "Events-1",
+		Category:           "Modified",
+		RelatedDocumentIds: []string{"variableset-Projects-1", "Projects-1"},
+	})
+
+	if len(evicted) != 2 {
+		t.Fatalf("must have evicted the project, got %v", evicted)
+	}
+
+	evicted = handler.InvalidateOctopusCache(models.OctopusEvent{
+		Id:                 "Events-2",
+		Category:           "DeploymentSucceeded",
+		RelatedDocumentIds: []string{"Projects-1", "Environments-1"},
+	})
+
+	if len(evicted) != 0 {
+		t.Fatalf("must have ignored the deployment event, got %v", evicted)
+	}
+}
@@ -0,0 +1,223 @@
+package hanlders
+
+import (
+	"context"
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultStatusHistorySize is the number of recent notifications included in the status report.
+const DefaultStatusHistorySize = 100
+
+// statusTracker keeps the status of the most recent notifications in memory. It is updated with the same events
+// written to the audit log, so it is available even when the audit log is disabled.
+type statusTracker struct {
+	mutex         sync.Mutex
+	size          int
+	notifications []*models.NotificationStatus
+}
+
+func newStatusTracker(size int) *statusTracker {
+	return &statusTracker{
+		size:          size,
+		notifications: []*models.NotificationStatus{},
+	}
+}
+
+// newDefaultStatusTracker creates a tracker holding the number of notifications in the STATUS_HISTORY_SIZE
+// environment variable.
+func newDefaultStatusTracker() (*statusTracker, error) {
+	size := DefaultStatusHistorySize
+	if os.Getenv("STATUS_HISTORY_SIZE") != "" {
+		historySize, err := strconv.Atoi(os.Getenv("STATUS_HISTORY_SIZE"))
+
+		if err != nil || historySize <= 0 {
+			return nil, errors.New("octoargosync-init-statuserror - STATUS_HISTORY_SIZE must be a positive integer")
+		}
+
+		size = historySize
+	}
+
+	return newStatusTracker(size), nil
+}
+
+// GetStatus reports the recent notifications, the queued jobs, the state of the Octopus client, and the mapping
+// inventory. A failure to load the inventory is included in the report, as the report is most useful when Octopus
+// is misbehaving.
+func (c *CreateReleaseHandler) GetStatus(ctx context.Context) models.StatusReport {
+	report := models.StatusReport{
+		Generated:            time.Now().UTC(),
+		Notifications:        c.status.snapshot(),
+		PendingNotifications: c.notifications.Pending(),
+		PendingReleases:      c.releases.Pending(),
+		QueueCapacity:        c.releases.Capacity(),
+		Octopus:              c.octo.GetStats(),
+		Inventory:            []models.ApplicationInventory{},
+	}
+
+	inventory, err := c.GetMappingInventory(ctx, "", "")
+
+	if err != nil {
+		errorResponse := models.NewErrorResponse(err)
+		report.InventoryError = &errorResponse
+	} else {
+		report.Inventory = inventory
+	}
+
+	return report
+}
+
+// record updates the status of the notification the event belongs to.
+func (s *statusTracker) record(event audit.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if event.Type == audit.EventNotificationReceived {
+		s.notifications = append(s.notifications, &models.NotificationStatus{
+			CorrelationId: event.CorrelationId,
+			Application:   event.Application,
+			Namespace:     event.Namespace,
+			Received:      event.Time,
+			Details:       event.Message,
+			Outcome:       models.NotificationOutcomePending,
+		})
+
+		if len(s.notifications) > s.size {
+			s.notifications = s.notifications[len(s.notifications)-s.size:]
+		}
+
+		return
+	}
+
+	notification := s.find(event.CorrelationId)
+	if notification == nil {
+		return
+	}
+
+	if event.Type == audit.EventNoProjectMatched {
+		notification.Outcome = models.NotificationOutcomeNoProjects
+		return
+	}
+
+	if event.Type == audit.EventNotificationFailed {
+		notification.Outcome = models.NotificationOutcomeFailed
+		notification.Error = event.Message
+		return
+	}
+
+	if event.Type == audit.EventProjectMatched {
+		notification.Projects = append(notification.Projects, models.ProjectReleaseStatus{
+			Project:     event.Project,
+			Environment: event.Environment,
+			Status:      models.ReleaseStatusQueued,
+			Updated:     event.Time,
+		})
+		return
+	}
+
+	project := findProjectStatus(notification, event.Project)
+	if project == nil {
+		return
+	}
+
+	project.Updated = event.Time
+
+	switch event.Type {
+	case audit.EventVersionComputed:
+		project.Status = models.ReleaseStatusCreating
+		project.Version = event.Version
+	case audit.EventReleaseCreated, audit.EventReleaseReused:
+		project.ReleaseId = event.ReleaseId
+	case audit.EventDeploymentCreated, audit.EventDeploymentAutomatic:
+		project.Status = models.ReleaseStatusDeployed
+		project.DeploymentId = event.DeploymentId
+		project.Error = ""
+	case audit.EventReleaseSuperseded:
+		project.Status = models.ReleaseStatusSuperseded
+	case audit.EventReleaseDropped:
+		project.Status = models.ReleaseStatusDropped
+	case audit.EventReleaseFailed:
+		project.Status = models.ReleaseStatusFailed
+		project.Error = event.Message
+	}
+
+	notification.Outcome = getOutcome(notification)
+}
+
+// attemptFailed records a failed attempt to create a release that will be retried.
+func (s *statusTracker) attemptFailed(correlationId string, projectName string, attempt int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	notification := s.find(correlationId)
+	if notification == nil {
+		return
+	}
+
+	project := findProjectStatus(notification, projectName)
+	if project == nil || !project.Pending() {
+		return
+	}
+
+	project.Status = models.ReleaseStatusRetrying
+	project.Attempts = attempt
+	project.Error = err.Error()
+	project.Updated = time.Now().UTC()
+}
+
+// snapshot returns a copy of the recent notifications, newest first.
+func (s *statusTracker) snapshot() []models.NotificationStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	notifications := make([]models.NotificationStatus, 0, len(s.notifications))
+	for i := len(s.notifications) - 1; i >= 0; i-- {
+		notification := *s.notifications[i]
+		notification.Projects = append([]models.ProjectReleaseStatus{}, notification.Projects...)
+		notifications = append(notifications, notification)
+	}
+
+	return notifications
+}
+
+// find returns the most recent notification with the correlation ID.
+func (s *statusTracker) find(correlationId string) *models.NotificationStatus {
+	for i := len(s.notifications) - 1; i >= 0; i-- {
+		if s.notifications[i].CorrelationId == correlationId {
+			return s.notifications[i]
+		}
+	}
+
+	return nil
+}
+
+func findProjectStatus(notification *models.NotificationStatus, projectName string) *models.ProjectReleaseStatus {
+	for i := range notification.Projects {
+		if notification.Projects[i].Project == projectName {
+			return &notification.Projects[i]
+		}
+	}
+
+	return nil
+}
+
+// getOutcome summarises the status of the notification's releases.
+func getOutcome(notification *models.NotificationStatus) string {
+	outcome := models.NotificationOutcomeCompleted
+	for _, project := range notification.Projects {
+		if project.Status == models.ReleaseStatusFailed {
+			return models.NotificationOutcomeFailed
+		}
+
+		if project.Pending() {
+			outcome = models.NotificationOutcomePending
+		}
+	}
+
+	return outcome
+}
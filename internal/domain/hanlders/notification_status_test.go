@@ -0,0 +1,67 @@
+package hanlders
+
+import (
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"testing"
+	"time"
+)
+
+func TestStatusTrackerFollowsRelease(t *testing.T) {
+	tracker := newStatusTracker(DefaultStatusHistorySize)
+
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventNotificationReceived, CorrelationId: "1", Application: "app1", Namespace: "argocd"})
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventProjectMatched, CorrelationId: "1", Project: "Project 1", Environment: "Development"})
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventProjectMatched, CorrelationId: "1", Project: "Project 2", Environment: "Development"})
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventVersionComputed, CorrelationId: "1", Project: "Project 1", Version: "1.0.0"})
+	tracker.attemptFailed("1", "Project 2", 3, errors.New("Octopus is down"))
+
+	notification := tracker.snapshot()[0]
+
+	if notification.Projects[0].Status != models.ReleaseStatusCreating || notification.Projects[0].Version != "1.0.0" {
+		t.Fatalf("Expected the first release to be created, got %+v", notification.Projects[0])
+	}
+
+	if notification.Projects[1].Status != models.ReleaseStatusRetrying || notification.Projects[1].Attempts != 3 {
+		t.Fatalf("Expected the second release to be retried, got %+v", notification.Projects[1])
+	}
+
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventDeploymentCreated, CorrelationId: "1", Project: "Project 1", DeploymentId: "Deployments-1"})
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventReleaseSuperseded, CorrelationId: "1", Project: "Project 2"})
+
+	if outcome := tracker.snapshot()[0].Outcome; outcome != models.NotificationOutcomeCompleted {
+		t.Fatalf("Expected the notification to be completed, got %s", outcome)
+	}
+
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventNotificationReceived, CorrelationId: "2", Application: "app1", Namespace: "argocd"})
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventProjectMatched, CorrelationId: "2", Project: "Project 1"})
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventReleaseFailed, CorrelationId: "2", Project: "Project 1", Message: "failed"})
+
+	notifications := tracker.snapshot()
+	if notifications[0].CorrelationId != "2" || notifications[0].Outcome != models.NotificationOutcomeFailed {
+		t.Fatalf("Expected the newest notification to have failed, got %+v", notifications[0])
+	}
+}
+
+func TestStatusTrackerKeepsRecentNotifications(t *testing.T) {
+	tracker := newStatusTracker(2)
+
+	for _, correlationId := range []string{"1", "2", "3"} {
+		tracker.record(audit.Event{Time: time.Now(), Type: audit.EventNotificationReceived, CorrelationId: correlationId})
+	}
+
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventNoProjectMatched, CorrelationId: "3"})
+
+	// Events for notifications that are no longer tracked are ignored
+	tracker.record(audit.Event{Time: time.Now(), Type: audit.EventNotificationFailed, CorrelationId: "1", Message: "failed"})
+
+	notifications := tracker.snapshot()
+	if len(notifications) != 2 || notifications[0].CorrelationId != "3" || notifications[1].CorrelationId != "2" {
+		t.Fatalf("Expected the 2 most recent notifications, got %+v", notifications)
+	}
+
+	if notifications[0].Outcome != models.NotificationOutcomeNoProjects || notifications[1].Outcome != models.NotificationOutcomePending {
+		t.Fatalf("Unexpected outcomes %+v", notifications)
+	}
+}
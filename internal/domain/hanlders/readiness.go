@@ -0,0 +1,82 @@
+package hanlders
+
+import (
+	"context"
+	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/health"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/workers"
+)
+
+// queueDegradedRatio is how full a queue can be before the proxy reports it is degraded.
+const queueDegradedRatio = 0.8
+
+// ReadinessChecks returns the checks that determine if the proxy can process notifications.
+func (c *CreateReleaseHandler) ReadinessChecks() []health.Check {
+	return []health.Check{
+		{Name: "octopus", Run: c.checkOctopus},
+		{Name: "argocd", Run: c.checkArgoCD},
+		{Name: "queues", Run: c.checkQueues},
+	}
+}
+
+// checkOctopus fails if the API key or space is invalid, as no release can be created until the configuration is
+// fixed. If Octopus is unavailable the proxy is degraded, as releases are retried until Octopus is available again.
+func (c *CreateReleaseHandler) checkOctopus(ctx context.Context) (health.Status, string) {
+	err := c.octo.CheckConnection(ctx)
+
+	if err == nil {
+		return health.StatusOk, "connected to Octopus"
+	}
+
+	if apperrors.Classify(err).Category == apperrors.Config {
+		return health.StatusFailed, err.Error()
+	}
+
+	return health.StatusDegraded, err.Error()
+}
+
+// checkArgoCD reports the proxy is degraded if ArgoCD can not be reached, as releases are still created, but without
+// the image versions. Only the connection is checked, as listing the applications is expensive in large installs.
+func (c *CreateReleaseHandler) checkArgoCD(ctx context.Context) (health.Status, string) {
+	if c.argo == nil {
+		return health.StatusDegraded, "the ArgoCD client is not configured"
+	}
+
+	err := c.argo.CheckConnection(ctx)
+
+	if err != nil {
+		return health.StatusDegraded, err.Error() + ". Releases will not use the image versions."
+	}
+
+	return health.StatusOk, "connected to ArgoCD"
+}
+
+// checkQueues fails if either queue is full, as Enqueue rejects new notifications while the notification queue is
+// full, and new releases wait for the retry delay while the release queue is full. A queue that is nearly full
+// degrades the proxy. Releases waiting to be retried are not queued, so a long Octopus outage does not fail the check.
+func (c *CreateReleaseHandler) checkQueues(ctx context.Context) (health.Status, string) {
+	status := health.StatusOk
+	message := ""
+	for _, queue := range []struct {
+		name string
+		pool *workers.Pool
+	}{{"notifications", c.notifications}, {"releases", c.releases}} {
+		pending := queue.pool.Pending()
+		capacity := queue.pool.Capacity()
+
+		if queue.pool.Full() {
+			status = health.StatusFailed
+		} else if float64(pending) >= float64(capacity)*queueDegradedRatio && status == health.StatusOk {
+			status = health.StatusDegraded
+		}
+
+		if message != "" {
+			message += ", "
+		}
+
+		message += fmt.Sprintf("%d of %d %s queued", pending, capacity, queue.name)
+	}
+
+	return status, message
+}
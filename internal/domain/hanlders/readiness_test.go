@@ -0,0 +1,94 @@
+package hanlders
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/versioners"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/health"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/workers"
+	"testing"
+	"time"
+)
+
+// getReadiness runs the handler's readiness checks, returning the status of each check.
+func getReadiness(handler *CreateReleaseHandler) (health.Report, map[string]health.CheckResult) {
+	report := health.NewChecker(time.Second, 0, handler.ReadinessChecks()...).Check(context.Background())
+
+	results := map[string]health.CheckResult{}
+	for _, result := range report.Checks {
+		results[result.Name] = result
+	}
+
+	return report, results
+}
+
+func TestReadiness(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	report, results := getReadiness(handler)
+
+	if report.Status != health.StatusOk {
+		t.Fatalf("Expected the proxy to be ready, got %+v", report)
+	}
+
+	if results["argocd"].Message != "connected to ArgoCD" {
+		t.Fatalf("Expected ArgoCD to be connected, got %+v", results["argocd"])
+	}
+}
+
+func TestReadinessWithOctopusDown(t *testing.T) {
+	_, client := createUnavailableMockOctopusClient()
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, results := getReadiness(handler)
+
+	// Releases are retried until Octopus is available, so an outage degrades the proxy rather than failing it
+	if report.Status != health.StatusDegraded || results["octopus"].Status != health.StatusDegraded ||
+		results["queues"].Status != health.StatusOk {
+		t.Fatalf("Expected Octopus to be degraded, got %+v", report)
+	}
+}
+
+func TestReadinessWithoutArgoCD(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+	argo.Close()
+
+	report, results := getReadiness(handler)
+
+	// Releases are still created without ArgoCD, so the proxy remains ready
+	if report.Status != health.StatusDegraded || results["argocd"].Status != health.StatusDegraded ||
+		results["octopus"].Status != health.StatusOk {
+		t.Fatalf("Expected ArgoCD to be degraded, got %+v", report)
+	}
+}
+
+func TestReadinessWithFullReleaseQueue(t *testing.T) {
+	// New releases can not be queued, so they wait for the retry delay instead of being created
+	_, _, client := createMockOctopusClient(true)
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.releases = workers.NewPool(1, 1)
+	unblock := make(chan bool)
+	defer close(unblock)
+
+	err = handler.releases.Submit("Projects-2", func() { <-unblock })
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, results := getReadiness(handler)
+
+	if report.Status != health.StatusFailed || results["queues"].Status != health.StatusFailed {
+		t.Fatalf("Expected the queues to fail, got %+v", report)
+	}
+}
@@ -0,0 +1,107 @@
+package hanlders
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
+	"go.uber.org/zap"
+	"time"
+)
+
+// QueryAudit returns the audit log events selected by the filter, oldest first.
+func (c *CreateReleaseHandler) QueryAudit(ctx context.Context, filter audit.Filter) ([]audit.Event, error) {
+	if c.audit == nil {
+		return nil, apperrors.New(apperrors.CodeAuditDisabled, "the audit log is disabled")
+	}
+
+	events, err := c.audit.Query(ctx, filter)
+
+	if err != nil {
+		return nil, apperrors.Wrap(apperrors.CodeAuditFailed, "failed to read the audit log", err)
+	}
+
+	return events, nil
+}
+
+// newAuditEvent creates an audit event for a notification, and the project the event applies to if it is not nil.
+func newAuditEvent(eventType string, applicationUpdateMessage models.ApplicationUpdateMessage, project *models.ArgoCDProjectExpanded, message string) audit.Event {
+	event := audit.Event{
+		Type:        eventType,
+		Application: applicationUpdateMessage.Application,
+		Namespace:   applicationUpdateMessage.Namespace,
+		Message:     message,
+	}
+
+	if project != nil {
+		if project.Project != nil {
+			event.Project = project.Project.Name
+		}
+
+		if project.Environment != nil {
+			event.Environment = project.Environment.Name
+		}
+	}
+
+	return event
+}
+
+// recordAudit records the event with the notification's correlation ID, and updates the status of the notification.
+// Failing to write to the audit log does not stop a release from being created, so errors are logged.
+func (c *CreateReleaseHandler) recordAudit(ctx context.Context, event audit.Event) {
+	event.Time = time.Now().UTC()
+	event.CorrelationId = apploggers.CorrelationIdFromContext(ctx)
+
+	c.status.record(event)
+
+	if c.audit == nil {
+		return
+	}
+
+	err := c.audit.Record(ctx, event)
+	if err != nil {
+		apploggers.FromContext(ctx, c.logger).GetLogger().Error("octoargosync-audit-error: Failed to record the "+event.Type+" audit event: "+err.Error(),
+			zap.Error(err))
+	}
+}
+
+// notificationFailed records a notification that could not be queued, or whose projects could not be found, so no
+// release was attempted.
+func (c *CreateReleaseHandler) notificationFailed(ctx context.Context, applicationUpdateMessage models.ApplicationUpdateMessage, err error) {
+	event := newAuditEvent(audit.EventNotificationFailed, applicationUpdateMessage, nil, err.Error())
+	event.ErrorCode = string(apperrors.Classify(err).Code)
+	c.recordAudit(ctx, event)
+}
+
+// recordReleaseAudit records the release and deployment returned by the Octopus client.
+func (c *CreateReleaseHandler) recordReleaseAudit(ctx context.Context, project models.ArgoCDProjectExpanded, applicationUpdateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion, result models.ReleaseResult) {
+	if result.ReleaseID == "" {
+		return
+	}
+
+	releaseEvent := newAuditEvent(audit.EventReleaseReused, applicationUpdateMessage, &project, "")
+	if result.ReleaseAction == models.ReleaseActionCreate {
+		releaseEvent.Type = audit.EventReleaseCreated
+	}
+
+	releaseEvent.Version = string(version)
+	releaseEvent.ReleaseId = result.ReleaseID
+	c.recordAudit(ctx, releaseEvent)
+
+	if result.DeploymentAction == "" {
+		return
+	}
+
+	deploymentEvent := newAuditEvent(audit.EventDeploymentCreated, applicationUpdateMessage, &project, "")
+	if result.DeploymentAction == models.DeploymentActionAutomatic {
+		deploymentEvent.Type = audit.EventDeploymentAutomatic
+		deploymentEvent.Message = "Octopus deploys the release automatically"
+	}
+
+	deploymentEvent.Version = string(version)
+	deploymentEvent.ReleaseId = result.ReleaseID
+	deploymentEvent.DeploymentId = result.DeploymentID
+	c.recordAudit(ctx, deploymentEvent)
+}
@@ -0,0 +1,120 @@
+package hanlders
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/audit"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/metrics"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"time"
+)
+
+// releaseJob creates the release for a single project, attempting it until it succeeds, is cancelled, or the Handler
+// retry policy says to stop. Each attempt is queued in the release pool, and the job waits for the next attempt
+// with a timer, so a project that can not be released does not hold a worker needed by the other projects.
+type releaseJob struct {
+	handler *CreateReleaseHandler
+	// ctx is the context of the notification, which is cancelled when the proxy shuts down
+	ctx context.Context
+	// jobCtx is cancelled when the proxy shuts down, or a newer release for the project supersedes this one
+	jobCtx     context.Context
+	cancel     context.CancelFunc
+	project    models.ArgoCDProjectExpanded
+	message    models.ApplicationUpdateMessage
+	added      time.Time
+	logger     apploggers.AppLogger
+	retrier    *retry_config.Retrier
+	completion *notificationCompletion
+}
+
+// submit queues the next attempt to create the release.
+func (j *releaseJob) submit() error {
+	return j.handler.releases.Submit(j.project.Project.ID, j.attempt)
+}
+
+// attempt tries to create the release once, scheduling another attempt if it fails.
+func (j *releaseJob) attempt() {
+	if j.jobCtx.Err() != nil {
+		j.cancelled()
+		return
+	}
+
+	attemptCtx := apploggers.WithLogger(j.jobCtx, j.logger.With(apploggers.Attempt(j.retrier.Attempts()+1)))
+	err := j.handler.lockAndCreateProjectRelease(attemptCtx, j.project, j.message, &j.handler.projectReleases, j.added)
+
+	if err == nil {
+		j.finish(nil)
+		return
+	}
+
+	j.retry(err)
+}
+
+// retry waits for the delay defined by the retry policy, and then queues the next attempt.
+func (j *releaseJob) retry(err error) {
+	if j.jobCtx.Err() != nil {
+		j.cancelled()
+		return
+	}
+
+	delay, retry := j.retrier.Next(err)
+
+	if !retry {
+		j.failed(err)
+		return
+	}
+
+	j.handler.status.attemptFailed(apploggers.CorrelationIdFromContext(j.ctx), j.project.Project.Name, j.retrier.Attempts(), err)
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			// A full queue is treated like any other failed attempt, and is retried after the next delay
+			if err := j.submit(); err != nil {
+				j.retry(apperrors.Wrap(apperrors.CodeQueueFull, "failed to queue the next attempt to create the release", err))
+			}
+		case <-j.jobCtx.Done():
+			j.cancelled()
+		}
+	}()
+}
+
+// cancelled finishes a job that was superseded, or cancelled because the proxy is shutting down.
+func (j *releaseJob) cancelled() {
+	j.logger.GetLogger().Info("Release was cancelled: "+j.jobCtx.Err().Error(), apploggers.Attempt(j.retrier.Attempts()))
+
+	if j.ctx.Err() != nil {
+		j.finish(j.ctx.Err())
+		return
+	}
+
+	j.handler.recordAudit(j.ctx, newAuditEvent(audit.EventReleaseSuperseded, j.message, &j.project,
+		"The release was cancelled by a newer notification for the project"))
+	j.finish(nil)
+}
+
+// failed finishes a job after the last attempt to create the release failed.
+func (j *releaseJob) failed(err error) {
+	// We really, really tried to create the release, but there is nothing left to do but print an error.
+	err = apperrors.WithContext(err, j.message.Namespace+"/"+j.message.Application, j.project.Project.Name)
+	metrics.RecordError("release", err)
+	j.logger.GetLogger().Error("octoargosync-release-failed: Failed to create a release: "+err.Error(),
+		append(apperrors.Fields(err), apploggers.Attempt(j.retrier.Attempts()))...)
+
+	event := newAuditEvent(audit.EventReleaseFailed, j.message, &j.project, err.Error())
+	event.ErrorCode = string(apperrors.Classify(err).Code)
+	j.handler.recordAudit(j.ctx, event)
+
+	j.finish(err)
+}
+
+func (j *releaseJob) finish(err error) {
+	j.cancel()
+	j.completion.finish(err)
+	j.handler.releasesInFlight.Done()
+}
@@ -0,0 +1,89 @@
+package hanlders
+
+import (
+	"context"
+	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"strings"
+	"time"
+)
+
+// DefaultSelfCheckTimeout is how long each self-check can take before it fails.
+const DefaultSelfCheckTimeout = 30 * time.Second
+
+// SelfCheck confirms the proxy can create releases by authenticating to Octopus, checking the space exists and the
+// API key has the required permissions, listing the ArgoCD applications, and discovering the mappings. Checks that
+// depend on Octopus are skipped if the connection fails. Each check fails if it takes longer than the timeout.
+func (c *CreateReleaseHandler) SelfCheck(ctx context.Context, timeout time.Duration) models.SelfCheckReport {
+	report := models.SelfCheckReport{
+		Checks:       []models.SelfCheckResult{},
+		Applications: []models.ApplicationInventory{},
+	}
+
+	runCheck := func(name string, check func(ctx context.Context) (string, error)) bool {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		message, err := check(checkCtx)
+
+		if err != nil {
+			message = err.Error()
+		}
+
+		report.Checks = append(report.Checks, models.SelfCheckResult{Name: name, Passed: err == nil, Message: message})
+		return err == nil
+	}
+
+	connected := runCheck("octopus", func(ctx context.Context) (string, error) {
+		return "connected to Octopus", c.octo.CheckConnection(ctx)
+	})
+
+	runCheck("argocd", func(ctx context.Context) (string, error) {
+		if c.argo == nil {
+			return "", fmt.Errorf("the ArgoCD client is not configured")
+		}
+
+		applications, err := c.argo.ListApplications(ctx)
+		return fmt.Sprintf("found %d applications", len(applications)), err
+	})
+
+	if !connected {
+		report.Checks = append(report.Checks,
+			models.SelfCheckResult{Name: "octopus-permissions", Message: "skipped because Octopus could not be reached"},
+			models.SelfCheckResult{Name: "mappings", Message: "skipped because Octopus could not be reached"})
+		return report
+	}
+
+	runCheck("octopus-permissions", func(ctx context.Context) (string, error) {
+		missing, err := c.octo.CheckPermissions(ctx)
+
+		if err != nil {
+			return "", err
+		}
+
+		if len(missing) != 0 {
+			return "", fmt.Errorf("the API key is missing the %s permissions", strings.Join(missing, ", "))
+		}
+
+		return "the API key has the required permissions", nil
+	})
+
+	runCheck("mappings", func(ctx context.Context) (string, error) {
+		inventory, err := c.GetMappingInventory(ctx, "", "")
+
+		if err != nil {
+			return "", err
+		}
+
+		report.Applications = inventory
+
+		projects := 0
+		for _, application := range inventory {
+			projects += len(application.Projects)
+		}
+
+		return fmt.Sprintf("found %d applications mapped to %d projects", len(inventory), projects), nil
+	})
+
+	return report
+}
@@ -0,0 +1,78 @@
+package hanlders
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/versioners"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getSelfCheckResults runs the self-check, returning the result of each check.
+func getSelfCheckResults(handler *CreateReleaseHandler) (models.SelfCheckReport, map[string]models.SelfCheckResult) {
+	report := handler.SelfCheck(context.Background(), time.Second)
+
+	results := map[string]models.SelfCheckResult{}
+	for _, result := range report.Checks {
+		results[result.Name] = result
+	}
+
+	return report, results
+}
+
+func TestSelfCheck(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+
+	report, results := getSelfCheckResults(handler)
+
+	if len(report.Checks) != 4 || len(report.Failed()) != 0 {
+		t.Fatalf("Expected every check to pass, got %+v", report.Checks)
+	}
+
+	if results["mappings"].Message != "found 1 applications mapped to 1 projects" ||
+		len(report.Applications) != 1 || report.Applications[0].Application != "myapp" {
+		t.Fatalf("Expected the mappings to be discovered, got %+v and %+v", results["mappings"], report.Applications)
+	}
+}
+
+func TestSelfCheckWithMissingPermissions(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+	octopus.RevokePermission("DeploymentCreate")
+
+	report, results := getSelfCheckResults(handler)
+
+	if len(report.Failed()) != 1 || !strings.Contains(results["octopus-permissions"].Message, "DeploymentCreate") {
+		t.Fatalf("Expected the missing permission to be reported, got %+v", report.Checks)
+	}
+}
+
+func TestSelfCheckWithoutArgoCD(t *testing.T) {
+	octopus, argo := createFakeBackends(t)
+	handler := createLiveReleaseHandler(t, octopus, argo)
+	argo.Close()
+
+	report, results := getSelfCheckResults(handler)
+
+	if len(report.Failed()) != 1 || results["argocd"].Passed {
+		t.Fatalf("Expected only the ArgoCD check to fail, got %+v", report.Checks)
+	}
+}
+
+func TestSelfCheckWithOctopusDown(t *testing.T) {
+	_, client := createUnavailableMockOctopusClient()
+	handler, err := createReleaseHandler(&versioners.SimpleRedeploymentVersioner{}, client)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, results := getSelfCheckResults(handler)
+
+	// The checks that depend on Octopus are skipped, and the unconfigured ArgoCD client fails
+	if len(report.Failed()) != 4 || !strings.HasPrefix(results["mappings"].Message, "skipped") {
+		t.Fatalf("Expected every check to fail, got %+v", report.Checks)
+	}
+}
@@ -0,0 +1,61 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"strings"
+)
+
+// KeyHeader is the HTTP header that can be used to supply an explicit idempotency key.
+const KeyHeader = "Idempotency-Key"
+
+// ReplayedHeader is the HTTP header set on responses returned for duplicate notifications.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Result is the response returned for a notification, which is returned again for any duplicate notifications.
+type Result struct {
+	StatusCode int
+	Body       json.RawMessage
+}
+
+// Store remembers the results returned for idempotency keys. A key is remembered with the acknowledgement while the
+// notification is processed, and then either completed with the final result, or forgotten if processing failed.
+type Store interface {
+	// Remember saves the result for the key. If a result was already saved for the key, the original result is
+	// returned along with true to indicate the key is a duplicate.
+	Remember(key string, result Result) (Result, bool, error)
+	// Complete replaces the result for the key once the notification has been processed.
+	Complete(key string, result Result) error
+	// Forget removes the result for the key, allowing the notification to be processed again.
+	Forget(key string) error
+}
+
+// GetKey returns the idempotency key for a notification. An explicit key supplied in a header or the message body
+// is used if present. Otherwise, the key is derived from the application, namespace, revision, and operation start
+// time. An empty string is returned if there is no operation start time, as redeployments of the same revision can
+// not be distinguished from duplicate notifications without it.
+func GetKey(updateMessage models.ApplicationUpdateMessage, headerKey string) string {
+	if strings.TrimSpace(headerKey) != "" {
+		return strings.TrimSpace(headerKey)
+	}
+
+	if strings.TrimSpace(updateMessage.IdempotencyKey) != "" {
+		return strings.TrimSpace(updateMessage.IdempotencyKey)
+	}
+
+	if strings.TrimSpace(updateMessage.OperationStartedAt) == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join([]string{
+		updateMessage.Namespace,
+		updateMessage.Application,
+		updateMessage.CommitSha,
+		updateMessage.TargetRevision,
+		strings.TrimSpace(updateMessage.OperationStartedAt),
+	}, "\n")))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"testing"
+)
+
+func TestGetKey(t *testing.T) {
+	message := models.ApplicationUpdateMessage{
+		Application:        "myapplication",
+		Namespace:          "development",
+		TargetRevision:     "0.0.1",
+		CommitSha:          "abcdefghijklmnop",
+		OperationStartedAt: "2023-08-01T00:00:00Z",
+	}
+
+	if GetKey(message, "") != GetKey(message, "") {
+		t.Fatal("the same message must generate the same key")
+	}
+
+	redeployment := message
+	redeployment.OperationStartedAt = "2023-08-01T01:00:00Z"
+
+	if GetKey(message, "") == GetKey(redeployment, "") {
+		t.Fatal("a redeployment must generate a different key")
+	}
+
+	if GetKey(message, "headerkey") != "headerkey" {
+		t.Fatal("the key in the header must be used")
+	}
+
+	message.IdempotencyKey = "bodykey"
+
+	if GetKey(message, "") != "bodykey" {
+		t.Fatal("the key in the body must be used")
+	}
+
+	if GetKey(models.ApplicationUpdateMessage{Application: "myapplication"}, "") != "" {
+		t.Fatal("a key must not be generated without an operation start time")
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// ApplicationMapping links an ArgoCD application to the Octopus project it creates releases in, as defined by the
+// metadata variables in the project.
+type ApplicationMapping struct {
+	Namespace           string
+	Application         string
+	ProjectID           string
+	Project             string
+	Environment         string
+	Channel             string                `json:",omitempty"`
+	Lifecycle           string                `json:",omitempty"`
+	ReleaseVersionImage string                `json:",omitempty"`
+	PackageVersions     []ImagePackageVersion `json:",omitempty"`
+}
+
+// ApplicationInventory lists the Octopus projects an ArgoCD application creates releases in.
+type ApplicationInventory struct {
+	Namespace   string
+	Application string
+	Projects    []ApplicationMapping
+}
+
+// MappingProblem is a misconfigured metadata variable that will prevent releases from being created.
+type MappingProblem struct {
+	Namespace   string
+	Application string
+	ProjectID   string
+	Project     string
+	Variable    string
+	Code        string
+	Message     string
+}
+
+// MappingValidationReport is the result of validating the mappings.
+type MappingValidationReport struct {
+	Started   time.Time
+	Completed time.Time
+	Problems  []MappingProblem
+	// Error is set if the validation could not be completed, for example if Octopus was unavailable
+	Error *ErrorResponse `json:",omitempty"`
+}
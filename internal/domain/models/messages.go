@@ -1,5 +1,7 @@
 package models
 
+import "github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+
 // ApplicationUpdateMessage is the message sent by the ArgoCD notification service
 type ApplicationUpdateMessage struct {
 	Application    string
@@ -10,10 +12,30 @@ type ApplicationUpdateMessage struct {
 	CommitSha      string
 	Images         []string
 	Project        string
+	// OperationStartedAt is the time the ArgoCD sync operation started, and is used to identify duplicate notifications
+	OperationStartedAt string
+	// IdempotencyKey optionally identifies duplicate notifications explicitly
+	IdempotencyKey string
 }
 
 // ErrorResponse is the response sent to the client if there was an error
 type ErrorResponse struct {
-	Status  string
-	Message string
+	Status      string
+	Message     string
+	Code        string `json:",omitempty"`
+	Category    string `json:",omitempty"`
+	Remediation string `json:",omitempty"`
+}
+
+// NewErrorResponse builds the response for an error, including the error code and remediation hint.
+func NewErrorResponse(err error) ErrorResponse {
+	appError := apperrors.Classify(err)
+
+	return ErrorResponse{
+		Status:      "Error",
+		Message:     err.Error(),
+		Code:        string(appError.Code),
+		Category:    string(appError.Category),
+		Remediation: appError.Remediation,
+	}
 }
@@ -0,0 +1,24 @@
+package models
+
+// OctopusSubscriptionPayload is the body of the webhook sent by an Octopus subscription.
+type OctopusSubscriptionPayload struct {
+	Timestamp string
+	EventType string
+	Payload   OctopusEventPayload
+}
+
+// OctopusEventPayload holds the event that triggered an Octopus subscription.
+type OctopusEventPayload struct {
+	ServerUri string
+	Event     OctopusEvent
+}
+
+// OctopusEvent is an audit event raised by Octopus. The related document IDs identify the resources, like projects,
+// variable sets, and environments, that were changed.
+type OctopusEvent struct {
+	Id                 string
+	Category           string
+	Message            string
+	SpaceId            string
+	RelatedDocumentIds []string
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// The Octopus resources below hold only the fields used by the proxy. They are independent of the Octopus client
+// library, so the library used to talk to Octopus can be replaced without changing the domain logic.
+
+// Project is an Octopus project.
+type Project struct {
+	ID                  string
+	Name                string
+	LifecycleID         string
+	DeploymentProcessID string
+}
+
+// Environment is an Octopus environment.
+type Environment struct {
+	ID   string
+	Name string
+}
+
+// Channel is an Octopus project channel. A channel without a lifecycle uses the project's lifecycle.
+type Channel struct {
+	ID          string
+	Name        string
+	ProjectID   string
+	LifecycleID string
+	IsDefault   bool
+}
+
+// Lifecycle is an Octopus lifecycle.
+type Lifecycle struct {
+	ID     string
+	Name   string
+	Phases []Phase
+}
+
+// Phase is a phase in a lifecycle, listing the environments that can be deployed to in the phase.
+type Phase struct {
+	Name                       string
+	AutomaticDeploymentTargets []string
+	OptionalDeploymentTargets  []string
+}
+
+// Release is an Octopus release.
+type Release struct {
+	ID        string
+	ProjectID string
+	ChannelID string
+	Version   string
+	Assembled time.Time
+}
+
+// Variable is an Octopus project variable.
+type Variable struct {
+	Name  string
+	Value string
+}
+
+// VariableSet holds the variables of an Octopus project.
+type VariableSet struct {
+	Variables []Variable
+}
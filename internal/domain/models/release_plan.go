@@ -0,0 +1,52 @@
+package models
+
+const (
+	// ReleaseActionCreate means a new release would be created
+	ReleaseActionCreate = "create"
+	// ReleaseActionReuse means an existing release with the same version would be deployed
+	ReleaseActionReuse = "reuse"
+	// DeploymentActionCreate means the proxy would deploy the release to the environment
+	DeploymentActionCreate = "create"
+	// DeploymentActionAutomatic means Octopus would deploy the new release because the environment is an automatic
+	// deployment target in the first phase of the lifecycle
+	DeploymentActionAutomatic = "automatic"
+)
+
+// ReleasePlan describes what the proxy would do in response to an ArgoCD notification.
+type ReleasePlan struct {
+	Application string
+	Namespace   string
+	Images      []string
+	Projects    []ProjectReleasePlan
+	Warnings    []string `json:",omitempty"`
+}
+
+// ProjectReleasePlan describes the release that would be created and deployed for a single project.
+type ProjectReleasePlan struct {
+	ProjectID         string
+	Project           string
+	Environment       string
+	Channel           string
+	Lifecycle         string
+	Version           string
+	ReleaseAction     string         `json:",omitempty"`
+	ExistingReleaseID string         `json:",omitempty"`
+	DeploymentAction  string         `json:",omitempty"`
+	Packages          []PackagePlan  `json:",omitempty"`
+	Error             *ErrorResponse `json:",omitempty"`
+}
+
+// PackagePlan is a package version that would be selected when creating a release.
+type PackagePlan struct {
+	ActionName           string
+	PackageReferenceName string
+	Version              string
+}
+
+// ReleaseResult describes the release that was created or reused, and how it was deployed.
+type ReleaseResult struct {
+	ReleaseID        string
+	ReleaseAction    string
+	DeploymentID     string `json:",omitempty"`
+	DeploymentAction string
+}
@@ -0,0 +1,26 @@
+package models
+
+// SelfCheckResult is the result of one of the checks run when the proxy starts.
+type SelfCheckResult struct {
+	Name    string
+	Passed  bool
+	Message string
+}
+
+// SelfCheckReport is the result of the startup self-check, including the mappings discovered in Octopus.
+type SelfCheckReport struct {
+	Checks       []SelfCheckResult
+	Applications []ApplicationInventory
+}
+
+// Failed returns the checks that did not pass.
+func (r SelfCheckReport) Failed() []SelfCheckResult {
+	failed := []SelfCheckResult{}
+	for _, check := range r.Checks {
+		if !check.Passed {
+			failed = append(failed, check)
+		}
+	}
+
+	return failed
+}
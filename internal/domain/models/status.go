@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+const (
+	// NotificationOutcomePending means releases for the notification are still being created
+	NotificationOutcomePending = "pending"
+	// NotificationOutcomeCompleted means every release for the notification was deployed, superseded, or dropped
+	NotificationOutcomeCompleted = "completed"
+	// NotificationOutcomeFailed means the notification or at least one of its releases failed
+	NotificationOutcomeFailed = "failed"
+	// NotificationOutcomeNoProjects means no Octopus projects are mapped to the application
+	NotificationOutcomeNoProjects = "no-projects"
+
+	// ReleaseStatusQueued means the release job is waiting for a worker
+	ReleaseStatusQueued = "queued"
+	// ReleaseStatusCreating means the release version was generated and the release is being created
+	ReleaseStatusCreating = "creating"
+	// ReleaseStatusRetrying means an attempt to create the release failed, and it will be retried
+	ReleaseStatusRetrying = "retrying"
+	// ReleaseStatusDeployed means the release was deployed by the proxy, or will be deployed automatically by Octopus
+	ReleaseStatusDeployed = "deployed"
+	// ReleaseStatusSuperseded means a newer notification for the project replaced the release
+	ReleaseStatusSuperseded = "superseded"
+	// ReleaseStatusDropped means a newer release was already deployed
+	ReleaseStatusDropped = "dropped"
+	// ReleaseStatusFailed means the release could not be created after every retry
+	ReleaseStatusFailed = "failed"
+)
+
+// NotificationStatus describes a recent notification and the releases it created.
+type NotificationStatus struct {
+	CorrelationId string
+	Application   string
+	Namespace     string
+	Received      time.Time
+	Details       string `json:",omitempty"`
+	Outcome       string
+	Error         string                 `json:",omitempty"`
+	Projects      []ProjectReleaseStatus `json:",omitempty"`
+}
+
+// ProjectReleaseStatus describes the release created for a single project in response to a notification.
+type ProjectReleaseStatus struct {
+	Project      string
+	Environment  string
+	Status       string
+	Version      string `json:",omitempty"`
+	ReleaseId    string `json:",omitempty"`
+	DeploymentId string `json:",omitempty"`
+	Attempts     int    `json:",omitempty"`
+	Error        string `json:",omitempty"`
+	Updated      time.Time
+}
+
+// Pending returns true if the release is still waiting to be created.
+func (p ProjectReleaseStatus) Pending() bool {
+	return p.Status == ReleaseStatusQueued || p.Status == ReleaseStatusCreating || p.Status == ReleaseStatusRetrying
+}
+
+// OctopusClientStats describes the Octopus client's cache, mapping index, and circuit breaker.
+type OctopusClientStats struct {
+	CacheEntries         int
+	CacheHits            int64
+	CacheMisses          int64
+	IndexLoaded          bool
+	IndexedProjects      int
+	IndexLastRefresh     time.Time
+	IndexLastFullRefresh time.Time
+	CircuitOpen          bool
+	CircuitOpenUntil     *time.Time `json:",omitempty"`
+}
+
+// StatusReport describes the current state of the proxy.
+type StatusReport struct {
+	Generated            time.Time
+	Notifications        []NotificationStatus
+	PendingNotifications int
+	PendingReleases      int
+	QueueCapacity        int
+	Octopus              OctopusClientStats
+	Inventory            []ApplicationInventory
+	// InventoryError is set if the mapping inventory could not be loaded, for example if Octopus was unavailable
+	InventoryError *ErrorResponse `json:",omitempty"`
+}
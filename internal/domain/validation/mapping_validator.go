@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/metrics"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often the mappings are validated in the background.
+const DefaultInterval = 15 * time.Minute
+
+// MappingValidatorSource finds the problems with the mappings.
+type MappingValidatorSource interface {
+	ValidateMappings(ctx context.Context) ([]models.MappingProblem, error)
+}
+
+// MappingValidator validates the mappings periodically, keeping the latest report so misconfigured variables are
+// found before an ArgoCD application is deployed.
+type MappingValidator struct {
+	logger   apploggers.AppLogger
+	source   MappingValidatorSource
+	interval time.Duration
+	mutex    sync.Mutex
+	report   *models.MappingValidationReport
+	// running serialises the validations, as they make many requests to Octopus
+	running sync.Mutex
+}
+
+// NewMappingValidator creates a validator that runs at the supplied interval. An interval of 0 disables the
+// background validation.
+func NewMappingValidator(source MappingValidatorSource, interval time.Duration) (*MappingValidator, error) {
+	logger, err := apploggers.NewDevProdLogger()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &MappingValidator{
+		logger:   logger,
+		source:   source,
+		interval: interval,
+	}, nil
+}
+
+// NewDefaultMappingValidator creates a validator that runs at the interval defined in the
+// MAPPING_VALIDATION_INTERVAL environment variable.
+func NewDefaultMappingValidator(source MappingValidatorSource) (*MappingValidator, error) {
+	interval := DefaultInterval
+	if os.Getenv("MAPPING_VALIDATION_INTERVAL") != "" {
+		duration, err := time.ParseDuration(os.Getenv("MAPPING_VALIDATION_INTERVAL"))
+
+		if err != nil {
+			return nil, errors.New("octoargosync-init-validationerror - MAPPING_VALIDATION_INTERVAL must be a duration like 15m: " + err.Error())
+		}
+
+		interval = duration
+	}
+
+	return NewMappingValidator(source, interval)
+}
+
+// Start validates the mappings immediately and then at the configured interval until the context is cancelled.
+func (v *MappingValidator) Start(ctx context.Context) {
+	if v.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(v.interval)
+		defer ticker.Stop()
+
+		for {
+			v.Validate(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Validate validates the mappings now, records the results in the metrics and logs, and returns the report.
+func (v *MappingValidator) Validate(ctx context.Context) models.MappingValidationReport {
+	v.running.Lock()
+	defer v.running.Unlock()
+
+	report := models.MappingValidationReport{
+		Started:  time.Now(),
+		Problems: []models.MappingProblem{},
+	}
+
+	problems, err := v.source.ValidateMappings(ctx)
+	report.Completed = time.Now()
+
+	if err != nil {
+		// A cancelled validation says nothing about the mappings, so the previous report is kept
+		if ctx.Err() != nil {
+			return report
+		}
+
+		metrics.RecordError("validation", err)
+		v.logger.GetLogger().Error("octoargosync-validation-error: Failed to validate the mappings: "+err.Error(), apperrors.Fields(err)...)
+
+		errorResponse := models.NewErrorResponse(err)
+		report.Error = &errorResponse
+	} else {
+		report.Problems = problems
+		metrics.RecordMappingValidation(problems)
+
+		for _, problem := range problems {
+			v.logger.GetLogger().Warn("octoargosync-validation-problem: "+problem.Message,
+				zap.String("project", problem.Project),
+				zap.String("variable", problem.Variable),
+				zap.String("errorCode", problem.Code))
+		}
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.report = &report
+
+	return report
+}
+
+// LatestReport returns the report from the last validation, or false if the mappings have not been validated.
+func (v *MappingValidator) LatestReport() (models.MappingValidationReport, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.report == nil {
+		return models.MappingValidationReport{}, false
+	}
+
+	return *v.report, true
+}
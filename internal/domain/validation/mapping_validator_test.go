@@ -0,0 +1,104 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"testing"
+	"time"
+)
+
+type mockSource struct {
+	problems []models.MappingProblem
+	err      error
+	calls    chan bool
+}
+
+func (m *mockSource) ValidateMappings(ctx context.Context) ([]models.MappingProblem, error) {
+	if m.calls != nil {
+		m.calls <- true
+	}
+
+	return m.problems, m.err
+}
+
+func TestValidate(t *testing.T) {
+	source := &mockSource{
+		problems: []models.MappingProblem{
+			{
+				Project:  "Project 1",
+				Variable: "Metadata.ArgoCD.Application[argocd/myapp].Environment",
+				Code:     string(apperrors.CodeOctopusEnvironmentNotFound),
+				Message:  "failed to find an environment called Developmnt",
+			},
+		},
+	}
+
+	validator, err := NewMappingValidator(source, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := validator.LatestReport(); found {
+		t.Fatal("must not have a report before validating")
+	}
+
+	report := validator.Validate(context.Background())
+
+	if len(report.Problems) != 1 || report.Error != nil {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if value := testutil.ToFloat64(metrics.MappingProblems.WithLabelValues(string(apperrors.CodeOctopusEnvironmentNotFound))); value != 1 {
+		t.Fatalf("expected the metric to count 1 problem, got %v", value)
+	}
+
+	latest, found := validator.LatestReport()
+
+	if !found || len(latest.Problems) != 1 {
+		t.Fatal("must have kept the latest report")
+	}
+}
+
+func TestValidateReportsErrors(t *testing.T) {
+	source := &mockSource{err: apperrors.New(apperrors.CodeOctopusUnavailable, "requests to Octopus are paused")}
+
+	validator, err := NewMappingValidator(source, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := validator.Validate(context.Background())
+
+	if report.Error == nil || report.Error.Code != string(apperrors.CodeOctopusUnavailable) {
+		t.Fatalf("expected the report to include the error, got %+v", report)
+	}
+}
+
+func TestStartValidatesPeriodically(t *testing.T) {
+	source := &mockSource{err: errors.New("octopus is unavailable"), calls: make(chan bool)}
+
+	validator, err := NewMappingValidator(source, 10*time.Millisecond)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	validator.Start(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-source.calls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the mappings must be validated periodically")
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package versioners
 
 import (
+	"context"
 	"github.com/Masterminds/semver/v3"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
@@ -15,7 +16,7 @@ type DefaultVersioner struct {
 
 // GenerateReleaseVersion will use the target revision, then a matching image version, then a git sha, then just a timestamp
 // to generate the release version.
-func (o *DefaultVersioner) GenerateReleaseVersion(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error) {
+func (o *DefaultVersioner) GenerateReleaseVersion(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error) {
 	timestamp := time.Now().Format("20060102150405")
 
 	sha := strings.TrimSpace(updateMessage.CommitSha)
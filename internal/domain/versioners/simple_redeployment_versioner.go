@@ -1,6 +1,7 @@
 package versioners
 
 import (
+	"context"
 	"github.com/Masterminds/semver/v3"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
@@ -15,7 +16,7 @@ type SimpleRedeploymentVersioner struct {
 
 // GenerateReleaseVersion extracts the version from the target revision or the image version. It pays no attention
 // to existing releases, meaning redeployments from Argo trigger redeployemnts in Octopus.
-func (o *SimpleRedeploymentVersioner) GenerateReleaseVersion(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error) {
+func (o *SimpleRedeploymentVersioner) GenerateReleaseVersion(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error) {
 
 	fallbackVersion := time.Now().Format("2006.01.02.150405")
 
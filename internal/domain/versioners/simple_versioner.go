@@ -1,9 +1,10 @@
 package versioners
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/Masterminds/semver/v3"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
@@ -26,27 +27,27 @@ func NewSimpleVersioner(octo octopus_apis.OctopusClient) SimpleVersioner {
 
 // GenerateReleaseVersion will use the target revision, then a matching image version, then a git sha. It uses semver metadata
 // to ensure release versions are unique, treating redeployments as unique releases.
-func (o *SimpleVersioner) GenerateReleaseVersion(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error) {
+func (o *SimpleVersioner) GenerateReleaseVersion(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error) {
 	if o.octo == nil {
-		return "", errors.New("octo can not be nil")
+		return "", apperrors.New(apperrors.CodeVersionerFailed, "octo can not be nil")
 	}
 
 	fallbackVersion := time.Now().Format("2006.01.02.150405")
 
-	releases, err := o.octo.GetReleaseVersions(project.Project)
+	releases, err := o.octo.GetReleaseVersions(ctx, project.Project)
 
 	if err != nil {
-		return "", err
+		return "", apperrors.WithContext(err, updateMessage.Namespace+"/"+updateMessage.Application, project.Project.Name)
 	}
 
 	// the target revision is a useful version
 	if len(Semver.FindStringSubmatch(updateMessage.TargetRevision)) != 0 {
 		version := types.OctopusReleaseVersion(updateMessage.TargetRevision)
 
-		isDeployed, err := o.octo.IsDeployed(project.Project, version, project.Environment)
+		isDeployed, err := o.octo.IsDeployed(ctx, project.Project, version, project.Environment)
 
 		if err != nil {
-			return "", err
+			return "", apperrors.WithContext(err, updateMessage.Namespace+"/"+updateMessage.Application, project.Project.Name)
 		}
 
 		if !isDeployed {
@@ -97,10 +98,10 @@ func (o *SimpleVersioner) GenerateReleaseVersion(project models.ArgoCDProjectExp
 
 			version := versions[0]
 
-			isDeployed, err := o.octo.IsDeployed(project.Project, version, project.Environment)
+			isDeployed, err := o.octo.IsDeployed(ctx, project.Project, version, project.Environment)
 
 			if err != nil {
-				return "", err
+				return "", apperrors.WithContext(err, updateMessage.Namespace+"/"+updateMessage.Application, project.Project.Name)
 			}
 
 			if !isDeployed {
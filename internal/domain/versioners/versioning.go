@@ -1,11 +1,12 @@
 package versioners
 
 import (
+	"context"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
 )
 
 // ReleaseVersioner defines the functions required to create an Octopus release version
 type ReleaseVersioner interface {
-	GenerateReleaseVersion(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error)
+	GenerateReleaseVersion(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) (types.OctopusReleaseVersion, error)
 }
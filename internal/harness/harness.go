@@ -0,0 +1,537 @@
+// Package harness replays recorded ArgoCD notifications through the proxy's router against fake Octopus and
+// ArgoCD servers, and compares the Octopus API calls with golden files. Each scenario is a directory containing:
+//
+//   - backends.json, which describes the resources in the fake Octopus and ArgoCD servers.
+//   - notifications/*.json, which are the recorded notification payloads, replayed in file name order.
+//   - expected.json, which is the golden file holding the response to each notification and the Octopus API calls
+//     that changed Octopus while the notification was processed.
+
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/hanlders"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/argocd_apis"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/fakes"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/octopus_apis"
+	"github.com/samber/lo"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	harnessBackendsFile      = "backends.json"
+	harnessNotificationsDir  = "notifications"
+	harnessGoldenFile        = "expected.json"
+	harnessNotificationRoute = "/api/octopusrelease"
+)
+
+// harnessBackends describes the resources in the fake servers. Resources refer to each other by name.
+type harnessBackends struct {
+	Octopus harnessOctopus
+	ArgoCD  harnessArgoCD
+}
+
+type harnessOctopus struct {
+	Environments []string
+	Lifecycles   []harnessLifecycle
+	Feeds        []harnessFeed
+	Projects     []harnessProject
+}
+
+type harnessLifecycle struct {
+	Name   string
+	Phases []harnessPhase
+}
+
+type harnessPhase struct {
+	Name                  string
+	AutomaticEnvironments []string
+	OptionalEnvironments  []string
+}
+
+type harnessFeed struct {
+	Name string
+	// Packages maps package IDs to their versions, with the latest version listed first
+	Packages map[string][]string
+}
+
+type harnessProject struct {
+	Name      string
+	Lifecycle string
+	Channels  []harnessChannel
+	Steps     []harnessStep
+	Variables []models.Variable
+	Releases  []harnessRelease
+}
+
+type harnessChannel struct {
+	Name      string
+	Lifecycle string
+}
+
+type harnessStep struct {
+	Name             string
+	Feed             string
+	PackageId        string
+	PackageReference string
+}
+
+type harnessRelease struct {
+	Version string
+	// Channel is the name of the release's channel, defaulting to the project's default channel
+	Channel    string
+	DeployedTo []string
+}
+
+type harnessArgoCD struct {
+	Applications []harnessApplication
+}
+
+type harnessApplication struct {
+	Namespace string
+	Name      string
+	Project   string
+	Images    []string
+	// RevisionImages maps commit SHAs to the images deployed by that commit, overriding Images for notifications
+	// with a matching CommitSha
+	RevisionImages map[string][]string
+}
+
+// harnessNotificationResult is the golden record of a replayed notification.
+type harnessNotificationResult struct {
+	Notification string
+	Status       int
+	OctopusCalls []harnessOctopusCall
+}
+
+type harnessOctopusCall struct {
+	Method string
+	Path   string
+	Body   json.RawMessage `json:",omitempty"`
+}
+
+// RouterFactory creates the routes served by the proxy for a handler connected to the fake servers.
+type RouterFactory func(ctx context.Context, createReleaseHandler *hanlders.CreateReleaseHandler) (http.Handler, error)
+
+// Run replays the scenario in the directory, or every scenario in the subdirectories of the directory, through the
+// routes created by newRouter. The golden files are rewritten instead of compared if update is true.
+func Run(ctx context.Context, dir string, update bool, out io.Writer, newRouter RouterFactory) error {
+	scenarios, err := findHarnessScenarios(dir)
+
+	if err != nil {
+		return err
+	}
+
+	if len(scenarios) == 0 {
+		return errors.New("no scenarios containing " + harnessBackendsFile + " were found in " + dir)
+	}
+
+	var harnessErrors error
+	for _, scenario := range scenarios {
+		results, err := runHarnessScenario(ctx, scenario, newRouter)
+
+		if err == nil {
+			if update {
+				err = writeHarnessGolden(filepath.Join(scenario, harnessGoldenFile), results)
+			} else {
+				err = compareHarnessGolden(filepath.Join(scenario, harnessGoldenFile), results)
+			}
+		}
+
+		if err != nil {
+			fmt.Fprintln(out, "FAIL "+scenario)
+			harnessErrors = errors.Join(harnessErrors, fmt.Errorf("scenario %s: %w", scenario, err))
+			continue
+		}
+
+		fmt.Fprintf(out, "ok   %s (%d notifications)\n", scenario, len(results))
+	}
+
+	return harnessErrors
+}
+
+// findHarnessScenarios returns the directory if it is a scenario, or the scenarios in its subdirectories.
+func findHarnessScenarios(dir string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, harnessBackendsFile)); err == nil {
+		return []string{dir}, nil
+	}
+
+	scenarios, err := filepath.Glob(filepath.Join(dir, "*", harnessBackendsFile))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(scenarios)
+
+	return lo.Map(scenarios, func(item string, index int) string {
+		return filepath.Dir(item)
+	}), nil
+}
+
+// runHarnessScenario replays the notifications in a scenario one at a time, waiting for each notification to be
+// processed before replaying the next.
+func runHarnessScenario(ctx context.Context, dir string, newRouter RouterFactory) ([]harnessNotificationResult, error) {
+	notificationFiles, err := filepath.Glob(filepath.Join(dir, harnessNotificationsDir, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(notificationFiles)
+
+	scenario, err := StartScenario(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer scenario.Close()
+
+	scenarioCtx, cancel := context.WithCancel(ctx)
+	defer scenario.Handler.Wait()
+	defer cancel()
+
+	router, err := newRouter(scenarioCtx, scenario.Handler)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []harnessNotificationResult{}
+	for _, notificationFile := range notificationFiles {
+		payload, err := os.ReadFile(notificationFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, scenario.NewNotificationRequest(payload))
+
+		scenario.Handler.Wait()
+
+		results = append(results, harnessNotificationResult{
+			Notification: filepath.Base(notificationFile),
+			Status:       recorder.Code,
+			OctopusCalls: getHarnessOctopusCalls(scenario.Octopus),
+		})
+	}
+
+	return results, nil
+}
+
+// Scenario is a handler connected to fake Octopus and ArgoCD servers holding the resources in a scenario's
+// backends.json file.
+type Scenario struct {
+	Octopus  *fakes.FakeOctopusServer
+	ArgoCD   *fakes.FakeArgoCDServer
+	Handler  *hanlders.CreateReleaseHandler
+	backends harnessBackends
+}
+
+// StartScenario starts the fake servers for the scenario in the directory. The scenario must be closed when it is no
+// longer needed.
+func StartScenario(dir string) (*Scenario, error) {
+	scenario := &Scenario{}
+	if err := readHarnessJson(filepath.Join(dir, harnessBackendsFile), &scenario.backends); err != nil {
+		return nil, err
+	}
+
+	octopus, err := newHarnessOctopus(scenario.backends.Octopus)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scenario.Octopus = octopus
+
+	argo, err := newHarnessArgoCD(scenario.backends.ArgoCD)
+
+	if err != nil {
+		octopus.Close()
+		return nil, err
+	}
+
+	scenario.ArgoCD = argo
+
+	scenario.Handler, err = newHarnessHandler(octopus, argo)
+
+	if err != nil {
+		scenario.Close()
+		return nil, err
+	}
+
+	return scenario, nil
+}
+
+// NewNotificationRequest sets the images deployed by the application in the recorded notification payload, clears
+// the recorded Octopus requests, and returns the request sending the notification to the proxy.
+func (s *Scenario) NewNotificationRequest(payload []byte) *http.Request {
+	setHarnessImages(s.ArgoCD, s.backends.ArgoCD, payload)
+	s.Octopus.ResetRequests()
+
+	request := httptest.NewRequest(http.MethodPost, harnessNotificationRoute, bytes.NewReader(payload))
+	request.Header.Set("Content-Type", "application/json")
+
+	return request
+}
+
+// Close stops the fake servers.
+func (s *Scenario) Close() {
+	s.ArgoCD.Close()
+	s.Octopus.Close()
+}
+
+// newHarnessHandler creates a handler connected to the fake servers. The release jobs run on a single worker, so the
+// Octopus API calls are made in the same order in every run.
+func newHarnessHandler(octopus *fakes.FakeOctopusServer, argo *fakes.FakeArgoCDServer) (*hanlders.CreateReleaseHandler, error) {
+	octopusClient, err := octopus_apis.NewLiveOctopusClientForConnection(octopus_apis.OctopusConnection{
+		Server:  octopus.URL(),
+		ApiKey:  fakes.FakeOctopusApiKey,
+		SpaceId: fakes.FakeOctopusSpaceId,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	argoClient, err := argocd_apis.NewClientForConnection(argocd_apis.ArgoCDConnection{
+		Server:    argo.Address(),
+		Token:     fakes.FakeArgoCDToken,
+		PlainText: true,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hanlders.NewCreateReleaseHandlerFromConfig(hanlders.HandlerConfig{
+		Octopus:  octopusClient,
+		ArgoCD:   argoClient,
+		PoolSize: 1,
+	})
+}
+
+// newHarnessOctopus starts a fake Octopus server holding the resources in the scenario.
+func newHarnessOctopus(backend harnessOctopus) (*fakes.FakeOctopusServer, error) {
+	octopus := fakes.NewFakeOctopusServer()
+
+	environmentIds := map[string]string{}
+	for _, environment := range backend.Environments {
+		environmentIds[environment] = octopus.AddEnvironment(environment)
+	}
+
+	getEnvironmentIds := func(names []string) ([]string, error) {
+		ids := []string{}
+		for _, name := range names {
+			id, found := environmentIds[name]
+			if !found {
+				return nil, errors.New("unknown environment " + name)
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+
+	lifecycleIds := map[string]string{}
+	for _, lifecycle := range backend.Lifecycles {
+		phases := []*lifecycles.Phase{}
+		for _, phase := range lifecycle.Phases {
+			automatic, err := getEnvironmentIds(phase.AutomaticEnvironments)
+			if err != nil {
+				octopus.Close()
+				return nil, err
+			}
+
+			optional, err := getEnvironmentIds(phase.OptionalEnvironments)
+			if err != nil {
+				octopus.Close()
+				return nil, err
+			}
+
+			phases = append(phases, &lifecycles.Phase{
+				Name:                       phase.Name,
+				AutomaticDeploymentTargets: automatic,
+				OptionalDeploymentTargets:  optional,
+			})
+		}
+
+		lifecycleIds[lifecycle.Name] = octopus.AddLifecycle(lifecycle.Name, phases...)
+	}
+
+	feedIds := map[string]string{}
+	for _, feed := range backend.Feeds {
+		feedIds[feed.Name] = octopus.AddFeed(feed.Name)
+
+		packageIds := lo.Keys(feed.Packages)
+		sort.Strings(packageIds)
+		for _, packageId := range packageIds {
+			octopus.AddPackageVersions(feedIds[feed.Name], packageId, feed.Packages[packageId]...)
+		}
+	}
+
+	for _, project := range backend.Projects {
+		projectId := octopus.AddProject(project.Name, lifecycleIds[project.Lifecycle])
+
+		channelIds := map[string]string{"": octopus.DefaultChannelID(projectId)}
+		for _, channel := range project.Channels {
+			channelIds[channel.Name] = octopus.AddChannel(projectId, channel.Name, lifecycleIds[channel.Lifecycle])
+		}
+
+		for _, step := range project.Steps {
+			octopus.AddDeploymentStep(projectId, step.Name, feedIds[step.Feed], step.PackageId, step.PackageReference)
+		}
+
+		for _, variable := range project.Variables {
+			octopus.AddVariable(projectId, variable.Name, variable.Value)
+		}
+
+		for _, release := range project.Releases {
+			releaseId := octopus.AddRelease(projectId, channelIds[release.Channel], release.Version)
+
+			deployedTo, err := getEnvironmentIds(release.DeployedTo)
+			if err != nil {
+				octopus.Close()
+				return nil, err
+			}
+
+			for _, environmentId := range deployedTo {
+				octopus.AddDeployment(releaseId, environmentId)
+			}
+		}
+	}
+
+	return octopus, nil
+}
+
+// newHarnessArgoCD starts a fake ArgoCD server holding the applications in the scenario.
+func newHarnessArgoCD(backend harnessArgoCD) (*fakes.FakeArgoCDServer, error) {
+	argo, err := fakes.NewFakeArgoCDServer()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, projectName := range lo.Uniq(lo.Map(backend.Applications, func(item harnessApplication, index int) string {
+		return item.Project
+	})) {
+		argo.AddProject(projectName)
+	}
+
+	for _, application := range backend.Applications {
+		argo.AddApplication(application.Namespace, application.Name, application.Project, application.Images...)
+	}
+
+	return argo, nil
+}
+
+// setHarnessImages sets the images deployed by the application in the notification to the images of the notified
+// commit. Payloads that can not be read are replayed unchanged, as rejecting them is part of the scenario.
+func setHarnessImages(argo *fakes.FakeArgoCDServer, backend harnessArgoCD, payload []byte) {
+	notification := models.ApplicationUpdateMessage{}
+	if json.Unmarshal(payload, &notification) != nil {
+		return
+	}
+
+	application, found := lo.Find(backend.Applications, func(item harnessApplication) bool {
+		return item.Namespace == notification.Namespace && item.Name == notification.Application
+	})
+
+	if !found {
+		return
+	}
+
+	images, found := application.RevisionImages[notification.CommitSha]
+
+	if !found {
+		images = application.Images
+	}
+
+	argo.SetImages(application.Namespace, application.Name, images...)
+}
+
+// getHarnessOctopusCalls returns the requests that changed Octopus. Queries are not recorded, as they depend on
+// caching and paging rather than the behaviour of the proxy.
+func getHarnessOctopusCalls(octopus *fakes.FakeOctopusServer) []harnessOctopusCall {
+	return lo.FilterMap(octopus.Requests(), func(item fakes.FakeOctopusRequest, index int) (harnessOctopusCall, bool) {
+		call := harnessOctopusCall{
+			Method: item.Method,
+			Path:   item.Path,
+		}
+
+		if json.Valid([]byte(item.Body)) {
+			call.Body = json.RawMessage(item.Body)
+		}
+
+		return call, item.Method != http.MethodGet
+	})
+}
+
+func writeHarnessGolden(file string, results []harnessNotificationResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// compareHarnessGolden compares the results with the golden file, reporting the first notification that differs.
+func compareHarnessGolden(file string, results []harnessNotificationResult) error {
+	expected := []harnessNotificationResult{}
+	if err := readHarnessJson(file, &expected); err != nil {
+		return err
+	}
+
+	for index, result := range results {
+		actualJson, err := json.MarshalIndent(result, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		if index >= len(expected) {
+			return fmt.Errorf("the notification %s is not in the golden file:\n%s", result.Notification, actualJson)
+		}
+
+		expectedJson, err := json.MarshalIndent(expected[index], "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(actualJson, expectedJson) {
+			return fmt.Errorf("the notification %s did not match the golden file\nexpected:\n%s\nactual:\n%s",
+				result.Notification, expectedJson, actualJson)
+		}
+	}
+
+	if len(expected) > len(results) {
+		return fmt.Errorf("the golden file expected %d notifications, but %d were replayed", len(expected), len(results))
+	}
+
+	return nil
+}
+
+func readHarnessJson(file string, value any) error {
+	data, err := os.ReadFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
@@ -0,0 +1,96 @@
+package apploggers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go.uber.org/zap"
+	"regexp"
+)
+
+// CorrelationIdHeader is the HTTP header holding the correlation ID of a notification.
+const CorrelationIdHeader = "X-Correlation-ID"
+
+// validCorrelationId limits the correlation IDs accepted from clients to values that are safe to log.
+var validCorrelationId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type loggerKey struct{}
+
+type correlationIdKey struct{}
+
+// The fields below are added to the log entries of a notification, allowing the entries for a single notification,
+// project, or release attempt to be grouped together.
+
+func CorrelationId(id string) zap.Field {
+	return zap.String("correlationId", id)
+}
+
+func Application(name string) zap.Field {
+	return zap.String("application", name)
+}
+
+func Namespace(name string) zap.Field {
+	return zap.String("namespace", name)
+}
+
+func Project(name string) zap.Field {
+	return zap.String("project", name)
+}
+
+func Environment(name string) zap.Field {
+	return zap.String("environment", name)
+}
+
+func Version(version string) zap.Field {
+	return zap.String("version", version)
+}
+
+func Attempt(attempt int) zap.Field {
+	return zap.Int("attempt", attempt)
+}
+
+// NewCorrelationId returns a random correlation ID.
+func NewCorrelationId() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// GetCorrelationId returns the correlation ID supplied by a client, or a new correlation ID if the client did not
+// supply a valid ID.
+func GetCorrelationId(clientId string) string {
+	if validCorrelationId.MatchString(clientId) {
+		return clientId
+	}
+
+	return NewCorrelationId()
+}
+
+// WithCorrelationId returns a copy of the context holding the correlation ID.
+func WithCorrelationId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIdKey{}, id)
+}
+
+// CorrelationIdFromContext returns the correlation ID in the context, or an empty string if there is none.
+func CorrelationIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIdKey{}).(string)
+	return id
+}
+
+// WithLogger returns a copy of the context holding the logger, so functions called with the context log with the
+// same fields.
+func WithLogger(ctx context.Context, logger AppLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger in the context, or the default logger if there is none.
+func FromContext(ctx context.Context, defaultLogger AppLogger) AppLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(AppLogger); ok {
+		return logger
+	}
+
+	return defaultLogger
+}
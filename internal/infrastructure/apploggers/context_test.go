@@ -0,0 +1,83 @@
+package apploggers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChildLoggerAddsFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := NewAppLogger(zap.New(core))
+
+	child := logger.With(Application("myapp"), Namespace("argocd")).With(Attempt(2))
+	child.GetLogger().Info("child")
+	logger.GetLogger().Info("parent")
+
+	entries := logs.All()
+	fields := entries[0].ContextMap()
+	if fields["application"] != "myapp" || fields["namespace"] != "argocd" || fields["attempt"] != int64(2) {
+		t.Fatalf("Unexpected fields %v", fields)
+	}
+
+	if len(entries[1].Context) != 0 {
+		t.Fatalf("The parent logger must not include the child's fields, got %v", entries[1].ContextMap())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	defaultLogger := NewAppLogger(zap.NewNop())
+	contextLogger := NewAppLogger(zap.NewNop())
+
+	if FromContext(context.Background(), defaultLogger) != defaultLogger {
+		t.Fatal("Expected the default logger when the context has no logger")
+	}
+
+	if FromContext(WithLogger(context.Background(), contextLogger), defaultLogger) != contextLogger {
+		t.Fatal("Expected the logger in the context")
+	}
+}
+
+func TestGetCorrelationId(t *testing.T) {
+	if GetCorrelationId("abc-123") != "abc-123" {
+		t.Fatal("Expected a valid correlation ID to be reused")
+	}
+
+	for _, clientId := range []string{"", "has spaces", "line\nbreak"} {
+		if id := GetCorrelationId(clientId); id == clientId || !validCorrelationId.MatchString(id) {
+			t.Fatalf("Expected a new correlation ID for %q, got %q", clientId, id)
+		}
+	}
+}
+
+func TestMiddlewareEchoesCorrelationId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+
+	var requestId string
+	r.GET("/", func(c *gin.Context) {
+		requestId = CorrelationIdFromContext(c.Request.Context())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(CorrelationIdHeader, "client-id")
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, request)
+
+	if requestId != "client-id" || recorder.Header().Get(CorrelationIdHeader) != "client-id" {
+		t.Fatalf("Expected the client's correlation ID, got %q and %q", requestId, recorder.Header().Get(CorrelationIdHeader))
+	}
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if requestId == "" || recorder.Header().Get(CorrelationIdHeader) != requestId {
+		t.Fatalf("Expected a generated correlation ID, got %q and %q", requestId, recorder.Header().Get(CorrelationIdHeader))
+	}
+}
@@ -38,3 +38,12 @@ func NewDevProdLogger() (*DevProdAppLogger, error) {
 func (d *DevProdAppLogger) GetLogger() *zap.Logger {
 	return d.log
 }
+
+func (d *DevProdAppLogger) With(fields ...zap.Field) AppLogger {
+	return &DevProdAppLogger{log: d.log.With(fields...)}
+}
+
+// NewAppLogger wraps an existing zap logger, such as one that records the log entries in tests.
+func NewAppLogger(log *zap.Logger) *DevProdAppLogger {
+	return &DevProdAppLogger{log: log}
+}
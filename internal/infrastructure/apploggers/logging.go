@@ -4,4 +4,6 @@ import "go.uber.org/zap"
 
 type AppLogger interface {
 	GetLogger() *zap.Logger
+	// With returns a child logger that adds the fields to every log entry.
+	With(fields ...zap.Field) AppLogger
 }
@@ -0,0 +1,17 @@
+package apploggers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware assigns a correlation ID to each HTTP request, echoing it in the X-Correlation-ID response header. A
+// valid correlation ID supplied in the request header is reused, so the proxy's logs can be matched to the client's.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationId := GetCorrelationId(c.GetHeader(CorrelationIdHeader))
+
+		c.Header(CorrelationIdHeader, correlationId)
+		c.Request = c.Request.WithContext(WithCorrelationId(c.Request.Context(), correlationId))
+		c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package argocd_apis
+
+import (
+	"context"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// ArgoClient defines the ArgoCD queries made by the proxy.
+type ArgoClient interface {
+	// CheckConnection confirms ArgoCD is reachable and the token is valid, without retrying the request.
+	CheckConnection(ctx context.Context) error
+	// GetClusters returns the clusters registered with ArgoCD.
+	GetClusters(ctx context.Context) ([]v1alpha1.Cluster, error)
+	// ListApplications returns every application the token can access.
+	ListApplications(ctx context.Context) ([]v1alpha1.Application, error)
+	// GetProject returns the named ArgoCD project.
+	GetProject(ctx context.Context, name string) (*v1alpha1.AppProject, error)
+	// GetApplication returns the named ArgoCD application.
+	GetApplication(ctx context.Context, name string, namespace string) (*v1alpha1.Application, error)
+	// GetApplicationResourceTree returns the resources, and the images they reference, deployed by an application.
+	GetApplicationResourceTree(ctx context.Context, name string, namespace string) (*v1alpha1.ApplicationTree, error)
+}
@@ -3,16 +3,20 @@ package argocd_apis
 import (
 	"context"
 	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
-	"github.com/avast/retry-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"os"
+	"strings"
 
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
 
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/session"
 )
 
 // ArgoCDClient provides access to the ArgoCD API
@@ -20,24 +24,51 @@ type ArgoCDClient struct {
 	projectClient     project.ProjectServiceClient
 	clusterClient     cluster.ClusterServiceClient
 	applicationClient application.ApplicationServiceClient
+	sessionClient     session.SessionServiceClient
 }
 
+// ArgoCDConnection is the ArgoCD server and token the client connects to.
+type ArgoCDConnection struct {
+	Server string
+	Token  string
+	// PlainText connects to servers that do not use TLS
+	PlainText bool
+}
+
+// GetArgoCDConnection reads the connection from the ARGOCD_SERVER, ARGOCD_TOKEN, and ARGOCD_PLAINTEXT environment
+// variables.
+func GetArgoCDConnection() ArgoCDConnection {
+	return ArgoCDConnection{
+		Server:    os.Getenv("ARGOCD_SERVER"),
+		Token:     os.Getenv("ARGOCD_TOKEN"),
+		PlainText: strings.ToLower(os.Getenv("ARGOCD_PLAINTEXT")) == "true",
+	}
+}
+
+// NewClient creates a client for the ArgoCD server defined in the ARGOCD_SERVER environment variable. Set
+// ARGOCD_PLAINTEXT to true to connect to servers that do not use TLS.
 func NewClient() (*ArgoCDClient, error) {
-	if os.Getenv("ARGOCD_SERVER") == "" {
-		return nil, errors.New("ARGOCD_SERVER must be defined")
+	return NewClientForConnection(GetArgoCDConnection())
+}
+
+// NewClientForConnection creates a client for the supplied ArgoCD server.
+func NewClientForConnection(connection ArgoCDConnection) (*ArgoCDClient, error) {
+	if connection.Server == "" {
+		return nil, apperrors.New(apperrors.CodeArgoConfigMissing, "ARGOCD_SERVER must be defined")
 	}
 
-	if os.Getenv("ARGOCD_TOKEN") == "" {
-		return nil, errors.New("ARGOCD_TOKEN must be defined")
+	if connection.Token == "" {
+		return nil, apperrors.New(apperrors.CodeArgoConfigMissing, "ARGOCD_TOKEN must be defined")
 	}
 
 	apiClient, err := apiclient.NewClient(&apiclient.ClientOptions{
-		ServerAddr: os.Getenv("ARGOCD_SERVER"),
+		ServerAddr: connection.Server,
 		Insecure:   true,
-		AuthToken:  os.Getenv("ARGOCD_TOKEN"),
+		PlainText:  connection.PlainText,
+		AuthToken:  connection.Token,
 	})
 	if err != nil {
-		return nil, err
+		return nil, apperrors.Wrap(apperrors.CodeArgoClientFailed, "failed to create the ArgoCD API client", err)
 	}
 
 	_, projectClient, err := apiClient.NewProjectClient()
@@ -55,21 +86,42 @@ func NewClient() (*ArgoCDClient, error) {
 		return nil, err
 	}
 
+	_, sessionClient, err := apiClient.NewSessionClient()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ArgoCDClient{
 		projectClient:     projectClient,
 		clusterClient:     clusterClient,
 		applicationClient: applicationClient,
+		sessionClient:     sessionClient,
 	}, nil
 }
 
-func (c *ArgoCDClient) GetClusters() ([]v1alpha1.Cluster, error) {
+// CheckConnection requests the user of the token, which is a small response regardless of how many applications
+// ArgoCD manages. The request is not retried, so a slow ArgoCD fails the check rather than timing it out.
+func (c *ArgoCDClient) CheckConnection(ctx context.Context) error {
+	userInfo, err := c.sessionClient.GetUserInfo(ctx, &session.GetUserInfoRequest{})
+
+	if err != nil {
+		return classifyError(err, "failed to connect to ArgoCD", apperrors.CodeArgoRequestFailed, "")
+	}
+
+	if !userInfo.LoggedIn {
+		return apperrors.New(apperrors.CodeArgoClientFailed, "ArgoCD did not accept the ARGOCD_TOKEN")
+	}
+
+	return nil
+}
+
+func (c *ArgoCDClient) GetClusters(ctx context.Context) ([]v1alpha1.Cluster, error) {
 	var cl *v1alpha1.ClusterList
-	err := retry.Do(
-		func() error {
-			var err error
-			cl, err = c.clusterClient.List(context.Background(), &cluster.ClusterQuery{})
-			return err
-		}, retry_config.RetryOptions...)
+	err := retry_config.Do(ctx, retry_config.ArgoRead, func() error {
+		var err error
+		cl, err = c.clusterClient.List(ctx, &cluster.ClusterQuery{})
+		return classifyError(err, "failed to list the clusters", apperrors.CodeArgoRequestFailed, "")
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -77,45 +129,81 @@ func (c *ArgoCDClient) GetClusters() ([]v1alpha1.Cluster, error) {
 	return cl.Items, nil
 }
 
-func (c *ArgoCDClient) GetProject(name string) (*v1alpha1.AppProject, error) {
+func (c *ArgoCDClient) ListApplications(ctx context.Context) ([]v1alpha1.Application, error) {
+	var applications *v1alpha1.ApplicationList
+	err := retry_config.Do(ctx, retry_config.ArgoRead, func() error {
+		var err error
+		applications, err = c.applicationClient.List(ctx, &application.ApplicationQuery{})
+		return classifyError(err, "failed to list the applications", apperrors.CodeArgoRequestFailed, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return applications.Items, nil
+}
+
+func (c *ArgoCDClient) GetProject(ctx context.Context, name string) (*v1alpha1.AppProject, error) {
 	var appProject *v1alpha1.AppProject
-	err := retry.Do(
-		func() error {
-			var err error
-			appProject, err = c.projectClient.Get(context.Background(), &project.ProjectQuery{
-				Name: name,
-			})
-			return err
+	err := retry_config.Do(ctx, retry_config.ArgoRead, func() error {
+		var err error
+		appProject, err = c.projectClient.Get(ctx, &project.ProjectQuery{
+			Name: name,
 		})
+		return classifyError(err, "failed to get the project "+name, apperrors.CodeArgoProjectNotFound, "")
+	})
 
 	return appProject, err
 }
 
-func (c *ArgoCDClient) GetApplication(name string, namespace string) (*v1alpha1.Application, error) {
+func (c *ArgoCDClient) GetApplication(ctx context.Context, name string, namespace string) (*v1alpha1.Application, error) {
 	var argoApplication *v1alpha1.Application
-	err := retry.Do(
-		func() error {
-			var err error
-			argoApplication, err = c.applicationClient.Get(context.Background(), &application.ApplicationQuery{
-				Name:         &name,
-				AppNamespace: &namespace,
-			})
-			return err
-		}, retry_config.RetryOptions...)
+	err := retry_config.Do(ctx, retry_config.ArgoRead, func() error {
+		var err error
+		argoApplication, err = c.applicationClient.Get(ctx, &application.ApplicationQuery{
+			Name:         &name,
+			AppNamespace: &namespace,
+		})
+		return classifyError(err, "failed to get the application", apperrors.CodeArgoApplicationNotFound, namespace+"/"+name)
+	})
 
 	return argoApplication, err
 }
 
-func (c *ArgoCDClient) GetApplicationResourceTree(name string, namespace string) (*v1alpha1.ApplicationTree, error) {
+func (c *ArgoCDClient) GetApplicationResourceTree(ctx context.Context, name string, namespace string) (*v1alpha1.ApplicationTree, error) {
 	var resourceTree *v1alpha1.ApplicationTree
-	err := retry.Do(
-		func() error {
-			var err error
-			resourceTree, err = c.applicationClient.ResourceTree(context.Background(), &application.ResourcesQuery{
-				ApplicationName: &name,
-				AppNamespace:    &namespace,
-			})
-			return err
-		}, retry_config.RetryOptions...)
+	err := retry_config.Do(ctx, retry_config.ArgoRead, func() error {
+		var err error
+		resourceTree, err = c.applicationClient.ResourceTree(ctx, &application.ResourcesQuery{
+			ApplicationName: &name,
+			AppNamespace:    &namespace,
+		})
+		return classifyError(err, "failed to get the application resource tree", apperrors.CodeArgoApplicationNotFound, namespace+"/"+name)
+	})
 	return resourceTree, err
 }
+
+// classifyError gives errors returned by ArgoCD an error code, so missing resources and invalid tokens are not
+// retried. notFoundCode is the code of the error returned when the requested resource does not exist.
+func classifyError(err error, message string, notFoundCode apperrors.Code, applicationName string) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var appError *apperrors.Error
+	switch status.Code(err) {
+	case codes.NotFound:
+		appError = apperrors.Wrap(notFoundCode, message, err)
+	case codes.Unauthenticated, codes.PermissionDenied:
+		appError = apperrors.Wrap(apperrors.CodeArgoClientFailed, message, err)
+	default:
+		appError = apperrors.Wrap(apperrors.CodeArgoRequestFailed, message, err)
+	}
+
+	appError.Application = applicationName
+	return appError
+}
@@ -0,0 +1,151 @@
+package argocd_apis
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/fakes"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"testing"
+	"time"
+)
+
+// createFakeArgoCD starts a fake ArgoCD server with the argocd/myapp application, and returns a client connected to it
+// with the supplied token.
+func createFakeArgoCD(t *testing.T, token string) (*fakes.FakeArgoCDServer, *ArgoCDClient) {
+	fake, err := fakes.NewFakeArgoCDServer()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(fake.Close)
+
+	fake.AddProject("default")
+	fake.AddCluster("in-cluster", "https://kubernetes.default.svc")
+	fake.AddApplication("argocd", "myapp", "default", "nginx:1.25.0", "busybox:1.36.0")
+
+	t.Setenv("ARGOCD_SERVER", fake.Address())
+	t.Setenv("ARGOCD_TOKEN", token)
+	t.Setenv("ARGOCD_PLAINTEXT", "true")
+
+	client, err := NewClient()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, client
+}
+
+func TestGetApplicationResourceTree(t *testing.T) {
+	_, client := createFakeArgoCD(t, fakes.FakeArgoCDToken)
+
+	tree, err := client.GetApplicationResourceTree(context.Background(), "myapp", "argocd")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tree.Nodes) != 1 || len(tree.Nodes[0].Images) != 2 || tree.Nodes[0].Images[0] != "nginx:1.25.0" {
+		t.Fatalf("Unexpected resource tree %+v", tree)
+	}
+
+	argoApplication, err := client.GetApplication(context.Background(), "myapp", "argocd")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appProject, err := client.GetProject(context.Background(), argoApplication.Spec.Project)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := client.GetClusters(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if appProject.Name != "default" || len(clusters) != 1 {
+		t.Fatalf("Unexpected project %+v and clusters %+v", appProject, clusters)
+	}
+}
+
+func TestGetMissingApplication(t *testing.T) {
+	_, client := createFakeArgoCD(t, fakes.FakeArgoCDToken)
+
+	_, err := client.GetApplicationResourceTree(context.Background(), "missing", "argocd")
+
+	appError := apperrors.Classify(err)
+
+	if appError.Code != apperrors.CodeArgoApplicationNotFound || appError.Application != "argocd/missing" {
+		t.Fatalf("Expected a missing application error, got %v", err)
+	}
+}
+
+func TestGetMissingProject(t *testing.T) {
+	_, client := createFakeArgoCD(t, fakes.FakeArgoCDToken)
+
+	_, err := client.GetProject(context.Background(), "missing")
+
+	if apperrors.Classify(err).Code != apperrors.CodeArgoProjectNotFound {
+		t.Fatalf("Expected a missing project error, got %v", err)
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	fake, client := createFakeArgoCD(t, "invalid")
+
+	_, err := client.GetApplication(context.Background(), "myapp", "argocd")
+
+	if apperrors.Classify(err).Code != apperrors.CodeArgoClientFailed {
+		t.Fatalf("Expected an authentication error, got %v", err)
+	}
+
+	// Authentication errors are permanent, so the request is not retried
+	if len(fake.Requests()) != 1 {
+		t.Fatalf("Expected 1 request, got %v", fake.Requests())
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	fake, client := createFakeArgoCD(t, fakes.FakeArgoCDToken)
+
+	if err := client.CheckConnection(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The readiness check must not list the applications
+	if requests := fake.Requests(); len(requests) != 1 || requests[0] != "/session.SessionService/GetUserInfo" {
+		t.Fatalf("Expected a single session request, got %v", requests)
+	}
+
+	fake.Close()
+
+	// Connection failures are not retried, so the check fails before the ArgoRead retry delay
+	start := time.Now()
+	if err := client.CheckConnection(context.Background()); apperrors.Classify(err).Code != apperrors.CodeArgoRequestFailed {
+		t.Fatalf("Expected a request failure, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= time.Duration(retry_config.GetPolicy(retry_config.ArgoRead).Delay) {
+		t.Fatalf("Expected the check to fail without a retry, took %v", elapsed)
+	}
+}
+
+func TestListApplications(t *testing.T) {
+	fake, client := createFakeArgoCD(t, fakes.FakeArgoCDToken)
+	fake.AddApplication("other", "otherapp", "default")
+
+	applications, err := client.ListApplications(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applications) != 2 {
+		t.Fatalf("Expected 2 applications, got %d", len(applications))
+	}
+}
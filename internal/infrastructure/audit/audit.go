@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The types of event recorded in the audit log.
+const (
+	// EventNotificationReceived is recorded when a notification from ArgoCD is processed
+	EventNotificationReceived = "notification-received"
+	// EventNotificationFailed is recorded when a notification could not be queued, or its projects could not be found
+	EventNotificationFailed = "notification-failed"
+	// EventProjectMatched is recorded for each Octopus project mapped to the application
+	EventProjectMatched = "project-matched"
+	// EventNoProjectMatched is recorded when no Octopus project is mapped to the application
+	EventNoProjectMatched = "no-project-matched"
+	// EventVersionComputed is recorded when the release version for a project is generated
+	EventVersionComputed = "version-computed"
+	// EventReleaseCreated is recorded when a new release is created
+	EventReleaseCreated = "release-created"
+	// EventReleaseReused is recorded when an existing release with the same version is deployed
+	EventReleaseReused = "release-reused"
+	// EventDeploymentCreated is recorded when the proxy deploys a release
+	EventDeploymentCreated = "deployment-created"
+	// EventDeploymentAutomatic is recorded when Octopus deploys a new release to an automatic deployment target
+	EventDeploymentAutomatic = "deployment-automatic"
+	// EventReleaseSuperseded is recorded when a release is replaced by a newer notification for the same project
+	EventReleaseSuperseded = "release-superseded"
+	// EventReleaseDropped is recorded when a release is skipped because a newer release was already deployed
+	EventReleaseDropped = "release-dropped"
+	// EventReleaseFailed is recorded when a release could not be created after every retry
+	EventReleaseFailed = "release-failed"
+)
+
+// Event is an entry in the audit log.
+type Event struct {
+	Time          time.Time
+	Type          string
+	CorrelationId string `json:",omitempty"`
+	Application   string `json:",omitempty"`
+	Namespace     string `json:",omitempty"`
+	Project       string `json:",omitempty"`
+	Environment   string `json:",omitempty"`
+	Version       string `json:",omitempty"`
+	ReleaseId     string `json:",omitempty"`
+	DeploymentId  string `json:",omitempty"`
+	ErrorCode     string `json:",omitempty"`
+	Message       string `json:",omitempty"`
+}
+
+// Filter selects the events returned by a query. Empty fields match every event.
+type Filter struct {
+	Application string
+	Project     string
+	From        time.Time
+	To          time.Time
+	// Limit is the maximum number of events to return, keeping the most recent events. Zero returns every event.
+	Limit int
+}
+
+// Matches returns true if the event is selected by the filter. The application and project are matched by name.
+func (f Filter) Matches(event Event) bool {
+	if f.Application != "" && f.Application != event.Application && f.Application != event.Namespace+"/"+event.Application {
+		return false
+	}
+
+	if f.Project != "" && f.Project != event.Project {
+		return false
+	}
+
+	if !f.From.IsZero() && event.Time.Before(f.From) {
+		return false
+	}
+
+	if !f.To.IsZero() && event.Time.After(f.To) {
+		return false
+	}
+
+	return true
+}
+
+// AuditSink records the actions taken by the proxy in an append only log.
+type AuditSink interface {
+	// Record appends the event to the audit log
+	Record(ctx context.Context, event Event) error
+	// Query returns the events selected by the filter, oldest first
+	Query(ctx context.Context, filter Filter) ([]Event, error)
+}
+
+// NewDefaultSink creates the sink configured by the AUDIT_LOG_DIRECTORY, AUDIT_LOG_MAX_SIZE_MB, and
+// AUDIT_LOG_MAX_FILES environment variables. A nil sink is returned if the audit log is disabled, which is the default.
+func NewDefaultSink() (AuditSink, error) {
+	if os.Getenv("AUDIT_LOG_DIRECTORY") == "" {
+		return nil, nil
+	}
+
+	maxSize := DefaultMaxSizeMb
+	if os.Getenv("AUDIT_LOG_MAX_SIZE_MB") != "" {
+		size, err := strconv.Atoi(os.Getenv("AUDIT_LOG_MAX_SIZE_MB"))
+
+		if err != nil || size <= 0 {
+			return nil, errors.New("octoargosync-init-auditerror - AUDIT_LOG_MAX_SIZE_MB must be a positive integer")
+		}
+
+		maxSize = size
+	}
+
+	maxFiles := DefaultMaxFiles
+	if os.Getenv("AUDIT_LOG_MAX_FILES") != "" {
+		files, err := strconv.Atoi(os.Getenv("AUDIT_LOG_MAX_FILES"))
+
+		if err != nil || files <= 0 {
+			return nil, errors.New("octoargosync-init-auditerror - AUDIT_LOG_MAX_FILES must be a positive integer")
+		}
+
+		maxFiles = files
+	}
+
+	return NewFileSink(os.Getenv("AUDIT_LOG_DIRECTORY"), int64(maxSize)*1024*1024, maxFiles)
+}
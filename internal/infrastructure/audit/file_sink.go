@@ -0,0 +1,314 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSizeMb is the size of the audit log file before it is rotated.
+const DefaultMaxSizeMb = 10
+
+// DefaultMaxFiles is the number of rotated audit log files that are kept.
+const DefaultMaxFiles = 10
+
+const currentFile = "audit.jsonl"
+
+const rotatedPrefix = "audit-"
+
+const rotatedSuffix = ".jsonl"
+
+// rotatedTimeFormat names the rotated files so they sort in the order they were written.
+const rotatedTimeFormat = "20060102T150405.000000000Z"
+
+// FileSink writes the audit log as JSON lines. Once the current file exceeds the maximum size it is renamed with the
+// time it was rotated, and the oldest rotated files are deleted once there are more than the maximum number of files.
+type FileSink struct {
+	directory string
+	maxSize   int64
+	maxFiles  int
+	mutex     sync.Mutex
+	file      *os.File
+	size      int64
+}
+
+func NewFileSink(directory string, maxSize int64, maxFiles int) (*FileSink, error) {
+	err := os.MkdirAll(directory, 0755)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &FileSink{
+		directory: directory,
+		maxSize:   maxSize,
+		maxFiles:  maxFiles,
+	}
+
+	err = sink.open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (f *FileSink) Record(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// A single event larger than the maximum size is written to an empty file rather than rotated forever
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	written, err := f.file.Write(line)
+	f.size += int64(written)
+
+	return err
+}
+
+// Query returns the events selected by the filter, oldest first. The files are opened while holding the lock, so a
+// rotation during the query does not skip or delete a file, and then read without it, newest first, stopping once the
+// limit is reached.
+func (f *FileSink) Query(ctx context.Context, filter Filter) ([]Event, error) {
+	files, err := f.snapshot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeFiles(files)
+
+	events := []Event{}
+	for i := len(files) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		remaining := 0
+		if filter.Limit > 0 {
+			remaining = filter.Limit - len(events)
+		}
+
+		fileEvents, err := readEvents(files[i], filter, remaining)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(fileEvents, events...)
+
+		if filter.Limit > 0 && len(events) >= filter.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// Close closes the current audit log file.
+func (f *FileSink) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.file.Close()
+}
+
+// open opens the current file for appending, continuing any file written before the proxy restarted.
+func (f *FileSink) open() error {
+	file, err := os.OpenFile(filepath.Join(f.directory, currentFile), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return errors.Join(err, file.Close())
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	// A line truncated when the proxy crashed is terminated, so the next event starts on a new line
+	if f.size > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, f.size-1); err != nil {
+			return errors.Join(err, file.Close())
+		}
+
+		if last[0] != '\n' {
+			written, err := file.Write([]byte{'\n'})
+			f.size += int64(written)
+
+			if err != nil {
+				return errors.Join(err, file.Close())
+			}
+		}
+	}
+
+	return nil
+}
+
+// rotate renames the current file and opens a new one, deleting the oldest rotated files.
+func (f *FileSink) rotate() error {
+	err := f.file.Close()
+
+	if err != nil {
+		return err
+	}
+
+	rotated := rotatedPrefix + time.Now().UTC().Format(rotatedTimeFormat) + rotatedSuffix
+	err = os.Rename(filepath.Join(f.directory, currentFile), filepath.Join(f.directory, rotated))
+
+	if err != nil {
+		return errors.Join(err, f.open())
+	}
+
+	err = f.open()
+
+	if err != nil {
+		return err
+	}
+
+	files, err := f.rotatedFiles()
+
+	if err != nil {
+		return err
+	}
+
+	for len(files) > f.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// rotatedFiles returns the paths of the rotated files, oldest first.
+func (f *FileSink) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(f.directory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), rotatedPrefix) && strings.HasSuffix(entry.Name(), rotatedSuffix) {
+			files = append(files, filepath.Join(f.directory, entry.Name()))
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// snapshotFile is a file opened by a query, and the size of the file when it was opened.
+type snapshotFile struct {
+	file *os.File
+	size int64
+}
+
+// snapshot opens the rotated files and the current file, oldest first. The current file is limited to the size
+// written so far, so events recorded while the query runs are not returned.
+func (f *FileSink) snapshot() (files []snapshotFile, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	defer func() {
+		if err != nil {
+			closeFiles(files)
+		}
+	}()
+
+	paths, err := f.rotatedFiles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+
+		if err != nil {
+			return files, err
+		}
+
+		files = append(files, snapshotFile{file: file})
+
+		info, err := file.Stat()
+
+		if err != nil {
+			return files, err
+		}
+
+		files[len(files)-1].size = info.Size()
+	}
+
+	current, err := os.Open(filepath.Join(f.directory, currentFile))
+
+	if err != nil {
+		return files, err
+	}
+
+	return append(files, snapshotFile{file: current, size: f.size}), nil
+}
+
+func closeFiles(files []snapshotFile) {
+	for _, file := range files {
+		_ = file.file.Close()
+	}
+}
+
+// readEvents returns the last limit events in a file selected by the filter, or every selected event if the limit
+// is zero. Lines that can not be parsed, like a line truncated when the proxy crashed, are skipped.
+func readEvents(file snapshotFile, filter Filter, limit int) ([]Event, error) {
+	events := []Event{}
+	scanner := bufio.NewScanner(io.NewSectionReader(file.file, 0, file.size))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		event := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+
+		if !filter.Matches(event) {
+			continue
+		}
+
+		events = append(events, event)
+
+		// Only the most recent events are kept, so a file is never held in memory
+		if limit > 0 && len(events) > limit {
+			events = events[1:]
+		}
+	}
+
+	return events, scanner.Err()
+}
@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryFiltersEvents(t *testing.T) {
+	sink, err := NewFileSink(t.TempDir(), 1024*1024, DefaultMaxFiles)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Time: start, Type: EventNotificationReceived, Application: "app1", Namespace: "argocd"},
+		{Time: start.Add(time.Minute), Type: EventProjectMatched, Application: "app1", Namespace: "argocd", Project: "Project 1"},
+		{Time: start.Add(2 * time.Minute), Type: EventProjectMatched, Application: "app1", Namespace: "argocd", Project: "Project 2"},
+		{Time: start.Add(3 * time.Minute), Type: EventNotificationReceived, Application: "app2", Namespace: "argocd"},
+	}
+
+	for _, event := range events {
+		if err := sink.Record(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter   Filter
+		expected int
+	}{
+		{Filter{}, 4},
+		{Filter{Application: "app1"}, 3},
+		{Filter{Application: "argocd/app2"}, 1},
+		{Filter{Project: "Project 2"}, 1},
+		{Filter{From: start.Add(time.Minute), To: start.Add(2 * time.Minute)}, 2},
+		{Filter{Application: "app1", Limit: 1}, 1},
+	}
+
+	for _, test := range tests {
+		found, err := sink.Query(context.Background(), test.filter)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(found) != test.expected {
+			t.Fatalf("Expected %d events for %+v, got %+v", test.expected, test.filter, found)
+		}
+	}
+
+	// The limit keeps the most recent events
+	found, _ := sink.Query(context.Background(), Filter{Application: "app1", Limit: 1})
+	if found[0].Project != "Project 2" {
+		t.Fatalf("Expected the most recent event, got %+v", found)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	directory := t.TempDir()
+	sink, err := NewFileSink(directory, 200, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	for i := 0; i < 20; i++ {
+		err := sink.Record(context.Background(), Event{Time: time.Now(), Type: EventNotificationReceived, Application: "app1", Message: strings.Repeat("x", 50)})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(directory, "audit-*.jsonl"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %v", rotated)
+	}
+
+	for _, file := range append(rotated, filepath.Join(directory, currentFile)) {
+		if info, err := os.Stat(file); err != nil || info.Size() > 200 {
+			t.Fatalf("Expected %s to be smaller than the maximum size", file)
+		}
+	}
+
+	// The events in the deleted files are gone, but the remaining events are returned oldest first
+	found, err := sink.Query(context.Background(), Filter{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) == 0 || len(found) >= 20 {
+		t.Fatalf("Expected the oldest events to be deleted, got %d events", len(found))
+	}
+
+	for i := 1; i < len(found); i++ {
+		if found[i].Time.Before(found[i-1].Time) {
+			t.Fatal("Expected the events to be returned oldest first")
+		}
+	}
+}
+
+func TestQueryLimitSpansRotatedFiles(t *testing.T) {
+	sink, err := NewFileSink(t.TempDir(), 200, 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		err := sink.Record(context.Background(), Event{Time: start.Add(time.Duration(i) * time.Minute), Type: EventNotificationReceived, Application: "app1", Message: strings.Repeat("x", 50)})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The files snapshot by the query are still read once they have been rotated
+	files, err := sink.snapshot()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer closeFiles(files)
+
+	for i := 0; i < 5; i++ {
+		if err := sink.Record(context.Background(), Event{Time: time.Now(), Application: "app2", Message: strings.Repeat("x", 50)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := readEvents(files[len(files)-1], Filter{Application: "app1"}, 0)
+
+	if err != nil || len(events) == 0 {
+		t.Fatalf("Expected the current file to be read after it was rotated, got %v %v", events, err)
+	}
+
+	found, err := sink.Query(context.Background(), Filter{Application: "app1", Limit: 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 5 {
+		t.Fatalf("Expected 5 events, got %d", len(found))
+	}
+
+	for i, event := range found {
+		if expected := start.Add(time.Duration(15+i) * time.Minute); !event.Time.Equal(expected) {
+			t.Fatalf("Expected the most recent events oldest first, got %v at %d", event.Time, i)
+		}
+	}
+}
+
+func TestReopenAppends(t *testing.T) {
+	directory := t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(directory, 1024*1024, DefaultMaxFiles)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sink.Record(context.Background(), Event{Time: time.Now(), Type: EventReleaseCreated}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A truncated line written by a crashed proxy is ignored, and does not corrupt the next event
+	file, _ := os.OpenFile(filepath.Join(directory, currentFile), os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = file.WriteString("{\"Type\":\"rel")
+	_ = file.Close()
+
+	sink, err := NewFileSink(directory, 1024*1024, DefaultMaxFiles)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	if err := sink.Record(context.Background(), Event{Time: time.Now(), Type: EventDeploymentCreated}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := sink.Query(context.Background(), Filter{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 3 || found[2].Type != EventDeploymentCreated {
+		t.Fatalf("Expected the events written before the restart, got %+v", found)
+	}
+}
+
+func TestNewDefaultSink(t *testing.T) {
+	t.Setenv("AUDIT_LOG_DIRECTORY", "")
+
+	sink, err := NewDefaultSink()
+
+	if err != nil || sink != nil {
+		t.Fatal("Expected the audit log to be disabled by default")
+	}
+
+	t.Setenv("AUDIT_LOG_DIRECTORY", t.TempDir())
+	t.Setenv("AUDIT_LOG_MAX_FILES", "none")
+
+	if _, err := NewDefaultSink(); err == nil {
+		t.Fatal("Expected an invalid AUDIT_LOG_MAX_FILES to be rejected")
+	}
+}
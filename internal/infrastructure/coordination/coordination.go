@@ -0,0 +1,183 @@
+package coordination
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLeaseDuration is how long a lock is held before it is considered abandoned by a replica that has crashed.
+const DefaultLeaseDuration = 5 * time.Minute
+
+// errLockLost is returned when renewing a lock that has been taken by another replica.
+var errLockLost = errors.New("the lock is held by another replica")
+
+// pollInterval is how often a replica waiting on a lock checks to see if the lock has been released.
+const pollInterval = 500 * time.Millisecond
+
+// ProcessedUpdate describes the most recent application update processed by any replica while holding a project lock.
+type ProcessedUpdate struct {
+	Time time.Time
+}
+
+// ProjectLocker provides locks that are shared between proxy replicas. Locks are taken out for each Octopus project,
+// ensuring only one replica processes a release for a project at any time, and that releases are processed in order.
+type ProjectLocker interface {
+	// Acquire blocks until the lock identified by the key is held, or the context is done.
+	Acquire(ctx context.Context, key string) (ProjectLock, error)
+}
+
+// ProjectLock is a lock held by this replica.
+type ProjectLock interface {
+	// LastProcessed returns the last update processed by any replica holding this lock.
+	LastProcessed() ProcessedUpdate
+	// Lost is closed if the lock could not be renewed before the lease expired, or was taken by another replica.
+	Lost() <-chan struct{}
+	// Release frees the lock, recording the last processed update for the next replica to acquire it.
+	Release(ctx context.Context, processed ProcessedUpdate) error
+}
+
+// NewProjectLocker creates the locker configured by the COORDINATION_BACKEND environment variable. A nil locker is
+// returned if coordination between replicas is disabled, which is the default.
+func NewProjectLocker() (ProjectLocker, error) {
+	leaseDuration := DefaultLeaseDuration
+	if os.Getenv("COORDINATION_LEASE_DURATION") != "" {
+		duration, err := time.ParseDuration(os.Getenv("COORDINATION_LEASE_DURATION"))
+
+		if err != nil {
+			return nil, errors.New("octoargosync-init-coordinationerror - COORDINATION_LEASE_DURATION must be a duration like 5m: " + err.Error())
+		}
+
+		leaseDuration = duration
+	}
+
+	switch strings.ToLower(os.Getenv("COORDINATION_BACKEND")) {
+	case "":
+		return nil, nil
+	case "file":
+		if os.Getenv("COORDINATION_LOCK_DIRECTORY") == "" {
+			return nil, errors.New("octoargosync-init-coordinationerror - COORDINATION_LOCK_DIRECTORY must be defined when COORDINATION_BACKEND is file")
+		}
+
+		return NewFileLocker(os.Getenv("COORDINATION_LOCK_DIRECTORY"), getIdentity(), leaseDuration)
+	case "kubernetes":
+		return NewKubernetesLeaseLocker(os.Getenv("COORDINATION_LEASE_NAMESPACE"), getIdentity(), leaseDuration)
+	default:
+		return nil, errors.New("octoargosync-init-coordinationerror - COORDINATION_BACKEND must be one of file or kubernetes")
+	}
+}
+
+// getIdentity returns a name that uniquely identifies this replica. The POD_NAME environment variable can be
+// exposed with the downward API, and otherwise the hostname is used, which defaults to the pod name in Kubernetes.
+func getIdentity() string {
+	if os.Getenv("POD_NAME") != "" {
+		return os.Getenv("POD_NAME")
+	}
+
+	hostname, err := os.Hostname()
+
+	if err != nil {
+		return "octoargosync"
+	}
+
+	return hostname
+}
+
+// sanitizeKey converts a lock key, which is typically an Octopus project ID, into a string that is safe to use as a
+// file or Kubernetes resource name.
+func sanitizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+
+		return '-'
+	}, key)
+}
+
+// waitForRetry pauses before another attempt to acquire a lock, returning early if the context is done.
+func waitForRetry(ctx context.Context) error {
+	select {
+	case <-time.After(pollInterval):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newToken returns a random value identifying a single acquisition of a lock.
+func newToken() string {
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// heartbeat renews a lock every third of the lease duration while it is held, so a release that takes longer than
+// the lease duration does not lose the lock to another replica. The lock is lost if it was taken by another replica,
+// or could not be renewed before the lease expired.
+type heartbeat struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	lost     chan struct{}
+}
+
+func startHeartbeat(leaseDuration time.Duration, renew func(ctx context.Context) error) *heartbeat {
+	h := &heartbeat{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		lost: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(h.done)
+
+		interval := leaseDuration / 3
+		if interval <= 0 {
+			interval = time.Millisecond
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), leaseDuration)
+			err := renew(ctx)
+			cancel()
+
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+
+			if errors.Is(err, errLockLost) || time.Since(renewed) > leaseDuration {
+				close(h.lost)
+				return
+			}
+		}
+	}()
+
+	return h
+}
+
+// Stop stops renewing the lock, waiting for any renewal in progress to finish.
+func (h *heartbeat) Stop() {
+	h.stopOnce.Do(func() { close(h.stop) })
+	<-h.done
+}
@@ -0,0 +1,245 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileLocker implements locks with files in a shared directory. Locks are acquired by exclusively creating a lock
+// file holding a token unique to the acquisition, and the last processed update is saved in a state file. This is
+// useful for replicas sharing a volume, and for testing.
+//
+// The lock file's modification time is updated while the lock is held. A lock file that has not been updated for
+// longer than the lease duration was abandoned, and is taken over by moving it aside and checking it still holds the
+// abandoned token, so only one replica can take over the lock.
+type FileLocker struct {
+	directory     string
+	identity      string
+	leaseDuration time.Duration
+}
+
+type fileLock struct {
+	locker        *FileLocker
+	key           string
+	token         string
+	lastProcessed ProcessedUpdate
+	heartbeat     *heartbeat
+}
+
+func NewFileLocker(directory string, identity string, leaseDuration time.Duration) (*FileLocker, error) {
+	err := os.MkdirAll(directory, 0755)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileLocker{
+		directory:     directory,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+	}, nil
+}
+
+func (f *FileLocker) Acquire(ctx context.Context, key string) (ProjectLock, error) {
+	lockFile := f.lockFile(key)
+	token := newToken()
+
+	for {
+		file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+
+		if err == nil {
+			_, err = file.WriteString(f.identity + "\n" + token + "\n" + time.Now().Format(time.RFC3339Nano))
+			closeErr := file.Close()
+
+			if err != nil || closeErr != nil {
+				_, removeErr := f.removeLockFile(key, token, false)
+				return nil, errors.Join(err, closeErr, removeErr)
+			}
+
+			lastProcessed, err := f.readState(key)
+
+			if err != nil {
+				_, removeErr := f.removeLockFile(key, token, false)
+				return nil, errors.Join(err, removeErr)
+			}
+
+			lock := &fileLock{
+				locker:        f,
+				key:           key,
+				token:         token,
+				lastProcessed: lastProcessed,
+			}
+			lock.heartbeat = startHeartbeat(f.leaseDuration, lock.renew)
+
+			return lock, nil
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		// A lock that was not renewed for longer than the lease duration was abandoned by a replica that crashed
+		if owner, modified, err := f.readLockFile(lockFile); err == nil && owner != "" && time.Since(modified) > f.leaseDuration {
+			if _, err := f.removeLockFile(key, owner, true); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if err := waitForRetry(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *FileLocker) lockFile(key string) string {
+	return filepath.Join(f.directory, sanitizeKey(key)+".lock")
+}
+
+func (f *FileLocker) stateFile(key string) string {
+	return filepath.Join(f.directory, sanitizeKey(key)+".state")
+}
+
+// readLockFile returns the token in a lock file, and when the lock was last renewed. The token is empty if the lock
+// file is still being written.
+func (f *FileLocker) readLockFile(lockFile string) (string, time.Time, error) {
+	info, err := os.Stat(lockFile)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	contents, err := os.ReadFile(lockFile)
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	lines := strings.Split(string(contents), "\n")
+
+	if len(lines) < 3 {
+		return "", info.ModTime(), nil
+	}
+
+	return lines[1], info.ModTime(), nil
+}
+
+// removeLockFile removes the lock file if it holds the token, returning false if the lock is held by another
+// acquisition. If abandoned is true, the lock file is only removed if it has not been renewed for longer than the
+// lease duration. The lock file is moved aside before it is checked, so a lock file created or renewed by another
+// replica after it was last read is never removed, and is put back instead.
+func (f *FileLocker) removeLockFile(key string, token string, abandoned bool) (bool, error) {
+	lockFile := f.lockFile(key)
+	movedFile := lockFile + "." + newToken() + ".removed"
+
+	err := os.Rename(lockFile, movedFile)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	owner, modified, err := f.readLockFile(movedFile)
+
+	if err == nil && owner == token && (!abandoned || time.Since(modified) > f.leaseDuration) {
+		return true, os.Remove(movedFile)
+	}
+
+	// Put the lock back. If yet another replica has created a lock file in the meantime, the replica whose lock was
+	// moved aside finds its lock was lost when the lock is next renewed.
+	linkErr := os.Link(movedFile, lockFile)
+
+	if errors.Is(linkErr, os.ErrExist) {
+		linkErr = nil
+	}
+
+	return false, errors.Join(err, linkErr, os.Remove(movedFile))
+}
+
+func (f *FileLocker) readState(key string) (ProcessedUpdate, error) {
+	state, err := os.ReadFile(f.stateFile(key))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return ProcessedUpdate{}, nil
+	}
+
+	if err != nil {
+		return ProcessedUpdate{}, err
+	}
+
+	processed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(state)))
+
+	if err != nil {
+		return ProcessedUpdate{}, err
+	}
+
+	return ProcessedUpdate{Time: processed}, nil
+}
+
+func (f *FileLocker) writeState(key string, processed ProcessedUpdate) error {
+	// Write to a temporary file and rename it so readers never see a partially written file
+	tempFile := f.stateFile(key) + "." + sanitizeKey(f.identity) + ".tmp"
+	err := os.WriteFile(tempFile, []byte(processed.Time.Format(time.RFC3339Nano)), 0644)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile, f.stateFile(key))
+}
+
+func (l *fileLock) LastProcessed() ProcessedUpdate {
+	return l.lastProcessed
+}
+
+func (l *fileLock) Lost() <-chan struct{} {
+	return l.heartbeat.lost
+}
+
+// renew updates the modification time of the lock file if it is still held by this lock.
+func (l *fileLock) renew(ctx context.Context) error {
+	lockFile := l.locker.lockFile(l.key)
+	owner, _, err := l.locker.readLockFile(lockFile)
+
+	if errors.Is(err, os.ErrNotExist) || (err == nil && owner != l.token) {
+		return errLockLost
+	}
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return os.Chtimes(lockFile, now, now)
+}
+
+func (l *fileLock) Release(ctx context.Context, processed ProcessedUpdate) error {
+	l.heartbeat.Stop()
+
+	// The lock expired and was taken by another replica, so there is nothing to release
+	owner, _, err := l.locker.readLockFile(l.locker.lockFile(l.key))
+
+	if errors.Is(err, os.ErrNotExist) || (err == nil && owner != l.token) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var stateErr error
+	if processed.Time.After(l.lastProcessed.Time) {
+		stateErr = l.locker.writeState(l.key, processed)
+	}
+
+	_, removeErr := l.locker.removeLockFile(l.key, l.token, false)
+	return errors.Join(stateErr, removeErr)
+}
@@ -0,0 +1,325 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileLockIsExclusive(t *testing.T) {
+	locker, err := NewFileLocker(t.TempDir(), "replica1", DefaultLeaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := locker.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err = locker.Acquire(ctx, "Projects-1")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("must not acquire a lock that is already held")
+	}
+
+	// Locks for other projects are independent
+	otherLock, err := locker.Acquire(context.Background(), "Projects-2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = otherLock.Release(context.Background(), ProcessedUpdate{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lock.Release(context.Background(), ProcessedUpdate{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = locker.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lock.Release(context.Background(), ProcessedUpdate{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileLockRecordsLastProcessed(t *testing.T) {
+	directory := t.TempDir()
+	processed := time.Now().Add(-time.Minute)
+
+	replica1, err := NewFileLocker(directory, "replica1", DefaultLeaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replica2, err := NewFileLocker(directory, "replica2", DefaultLeaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lock.LastProcessed().Time.IsZero() {
+		t.Fatal("a new lock must not have a last processed time")
+	}
+
+	err = lock.Release(context.Background(), ProcessedUpdate{Time: processed})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = replica2.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lock.LastProcessed().Time.Equal(processed) {
+		t.Fatal("the last processed time must be shared between replicas")
+	}
+
+	// An older update must not overwrite the last processed time
+	err = lock.Release(context.Background(), ProcessedUpdate{Time: processed.Add(-time.Minute)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lock.LastProcessed().Time.Equal(processed) {
+		t.Fatal("the last processed time must not go backwards")
+	}
+}
+
+func TestAbandonedFileLockIsAcquired(t *testing.T) {
+	directory := t.TempDir()
+
+	replica1, err := NewFileLocker(directory, "replica1", time.Millisecond)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a replica that crashed and stopped renewing the lock
+	lock.(*fileLock).heartbeat.Stop()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err = replica1.Acquire(ctx, "Projects-1")
+
+	if err != nil {
+		t.Fatal("must acquire a lock that was held for longer than the lease duration")
+	}
+}
+
+func TestFileLockIsRenewed(t *testing.T) {
+	directory := t.TempDir()
+	leaseDuration := 50 * time.Millisecond
+
+	replica1, err := NewFileLocker(directory, "replica1", leaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replica2, err := NewFileLocker(directory, "replica2", leaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer lock.Release(context.Background(), ProcessedUpdate{})
+
+	// The lock is held for longer than the lease duration
+	ctx, cancel := context.WithTimeout(context.Background(), 5*leaseDuration)
+	defer cancel()
+
+	_, err = replica2.Acquire(ctx, "Projects-1")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("must not acquire a lock that is being renewed")
+	}
+}
+
+func TestExpiredFileLockIsNotReleased(t *testing.T) {
+	directory := t.TempDir()
+
+	leaseDuration := 30 * time.Millisecond
+
+	replica1, err := NewFileLocker(directory, "replica1", leaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replica2, err := NewFileLocker(directory, "replica2", leaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiredLock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a replica that paused for longer than its lease
+	expiredLock.(*fileLock).heartbeat.Stop()
+	time.Sleep(2 * leaseDuration)
+
+	lock, err := replica2.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := expiredLock.(*fileLock).renew(context.Background()); !errors.Is(err, errLockLost) {
+		t.Fatalf("Expected the expired lock to be lost, got %v", err)
+	}
+
+	err = expiredLock.Release(context.Background(), ProcessedUpdate{Time: time.Now()})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "projects-1.lock")); err != nil {
+		t.Fatal("must not remove a lock taken by another replica")
+	}
+
+	if !lock.LastProcessed().Time.IsZero() {
+		t.Fatal("must not record the update processed by an expired lock")
+	}
+
+	err = lock.Release(context.Background(), ProcessedUpdate{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileLockIsLost(t *testing.T) {
+	directory := t.TempDir()
+	leaseDuration := 30 * time.Millisecond
+
+	replica1, err := NewFileLocker(directory, "replica1", leaseDuration)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another replica replaces the lock file
+	err = os.WriteFile(filepath.Join(directory, "projects-1.lock"), []byte("replica2\nothertoken\n"), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the lock to be lost")
+	}
+}
+
+func TestAbandonedFileLockIsTakenOverOnce(t *testing.T) {
+	directory := t.TempDir()
+	lockFile := filepath.Join(directory, "projects-1.lock")
+
+	err := os.WriteFile(lockFile, []byte("crashed\nabandonedtoken\n"), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abandoned := time.Now().Add(-time.Hour)
+	err = os.Chtimes(lockFile, abandoned, abandoned)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := atomic.Int32{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			locker, err := NewFileLocker(directory, "replica", time.Minute)
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			if _, err := locker.Acquire(ctx, "Projects-1"); err == nil {
+				acquired.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if acquired.Load() != 1 {
+		t.Fatalf("Expected exactly one replica to take over the abandoned lock, %d did", acquired.Load())
+	}
+}
@@ -0,0 +1,249 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationclient "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/pointer"
+	"os"
+	"strings"
+	"time"
+)
+
+// LastProcessedAnnotation is the lease annotation that records the time of the last processed update.
+const LastProcessedAnnotation = "octoargosync.octopus.com/last-processed"
+
+// leasePrefix is added to the name of each lease created by the proxy.
+const leasePrefix = "octoargosync-"
+
+// serviceAccountNamespace is the file holding the namespace of the pod in Kubernetes.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// KubernetesLeaseLocker implements locks with Kubernetes Lease resources. Each lock is a lease, with the holder
+// identity set to the replica holding the lock, and the last processed update saved in an annotation. The lease is
+// renewed while the lock is held, and an acquisition is identified by the holder identity and acquire time, so a
+// replica never renews or releases a lease that expired and was acquired again.
+type KubernetesLeaseLocker struct {
+	leases        coordinationclient.LeaseInterface
+	identity      string
+	leaseDuration time.Duration
+}
+
+type kubernetesLeaseLock struct {
+	locker        *KubernetesLeaseLocker
+	name          string
+	acquired      *metav1.MicroTime
+	lastProcessed ProcessedUpdate
+	heartbeat     *heartbeat
+}
+
+// NewKubernetesLeaseLocker creates a locker using the in-cluster Kubernetes configuration. If namespace is empty,
+// the namespace of the pod is used.
+func NewKubernetesLeaseLocker(namespace string, identity string, leaseDuration time.Duration) (*KubernetesLeaseLocker, error) {
+	config, err := rest.InClusterConfig()
+
+	if err != nil {
+		return nil, errors.New("octoargosync-init-coordinationerror - failed to load the in-cluster Kubernetes configuration: " + err.Error())
+	}
+
+	client, err := coordinationclient.NewForConfig(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace == "" {
+		namespaceData, err := os.ReadFile(serviceAccountNamespace)
+
+		if err != nil {
+			return nil, errors.New("octoargosync-init-coordinationerror - COORDINATION_LEASE_NAMESPACE must be defined when the pod namespace can not be read: " + err.Error())
+		}
+
+		namespace = strings.TrimSpace(string(namespaceData))
+	}
+
+	return NewKubernetesLeaseLockerFromClient(client.Leases(namespace), identity, leaseDuration), nil
+}
+
+// NewKubernetesLeaseLockerFromClient creates a locker from an existing lease client.
+func NewKubernetesLeaseLockerFromClient(leases coordinationclient.LeaseInterface, identity string, leaseDuration time.Duration) *KubernetesLeaseLocker {
+	return &KubernetesLeaseLocker{
+		leases:        leases,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+	}
+}
+
+func (k *KubernetesLeaseLocker) Acquire(ctx context.Context, key string) (ProjectLock, error) {
+	name := leasePrefix + sanitizeKey(key)
+
+	for {
+		lease, err := k.leases.Get(ctx, name, metav1.GetOptions{})
+
+		if k8serrors.IsNotFound(err) {
+			lease, err = k.leases.Create(ctx, k.newLease(name), metav1.CreateOptions{})
+
+			if err == nil {
+				return k.newLock(name, lease)
+			}
+
+			// Another replica created the lease first, so try again
+			if k8serrors.IsAlreadyExists(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if k.isAvailable(lease) {
+			now := metav1.NewMicroTime(time.Now())
+			lease.Spec.HolderIdentity = pointer.String(k.identity)
+			lease.Spec.LeaseDurationSeconds = pointer.Int32(int32(k.leaseDuration.Seconds()))
+			lease.Spec.AcquireTime = &now
+			lease.Spec.RenewTime = &now
+
+			// The update fails with a conflict if another replica modified the lease after we read it
+			lease, err = k.leases.Update(ctx, lease, metav1.UpdateOptions{})
+
+			if err == nil {
+				return k.newLock(name, lease)
+			}
+
+			if !k8serrors.IsConflict(err) {
+				return nil, err
+			}
+		}
+
+		if err := waitForRetry(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isAvailable returns true if the lease is not held, or the replica holding it has let it expire.
+func (k *KubernetesLeaseLocker) isAvailable(lease *coordinationv1.Lease) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return true
+	}
+
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
+
+func (k *KubernetesLeaseLocker) newLease(name string) *coordinationv1.Lease {
+	now := metav1.NewMicroTime(time.Now())
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "octoargosync",
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       pointer.String(k.identity),
+			LeaseDurationSeconds: pointer.Int32(int32(k.leaseDuration.Seconds())),
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	}
+}
+
+func (k *KubernetesLeaseLocker) newLock(name string, lease *coordinationv1.Lease) (ProjectLock, error) {
+	lastProcessed := ProcessedUpdate{}
+
+	if value, ok := lease.Annotations[LastProcessedAnnotation]; ok {
+		processed, err := time.Parse(time.RFC3339Nano, value)
+
+		if err != nil {
+			return nil, errors.New("the annotation " + LastProcessedAnnotation + " on lease " + name + " is not a valid time: " + err.Error())
+		}
+
+		lastProcessed.Time = processed
+	}
+
+	lock := &kubernetesLeaseLock{
+		locker:        k,
+		name:          name,
+		acquired:      lease.Spec.AcquireTime,
+		lastProcessed: lastProcessed,
+	}
+	lock.heartbeat = startHeartbeat(k.leaseDuration, lock.renew)
+
+	return lock, nil
+}
+
+func (l *kubernetesLeaseLock) LastProcessed() ProcessedUpdate {
+	return l.lastProcessed
+}
+
+func (l *kubernetesLeaseLock) Lost() <-chan struct{} {
+	return l.heartbeat.lost
+}
+
+// isHeld returns true if the lease is still held by this acquisition of the lock.
+func (l *kubernetesLeaseLock) isHeld(lease *coordinationv1.Lease) bool {
+	return lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == l.locker.identity &&
+		lease.Spec.AcquireTime != nil && l.acquired != nil && lease.Spec.AcquireTime.Equal(l.acquired)
+}
+
+// renew updates the renew time of the lease if it is still held by this lock.
+func (l *kubernetesLeaseLock) renew(ctx context.Context) error {
+	lease, err := l.locker.leases.Get(ctx, l.name, metav1.GetOptions{})
+
+	if k8serrors.IsNotFound(err) {
+		return errLockLost
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !l.isHeld(lease) {
+		return errLockLost
+	}
+
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+
+	_, err = l.locker.leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+func (l *kubernetesLeaseLock) Release(ctx context.Context, processed ProcessedUpdate) error {
+	l.heartbeat.Stop()
+
+	lease, err := l.locker.leases.Get(ctx, l.name, metav1.GetOptions{})
+
+	if err != nil {
+		return err
+	}
+
+	// The lease expired and was taken by another replica, so there is nothing to release
+	if !l.isHeld(lease) {
+		return nil
+	}
+
+	if processed.Time.After(l.lastProcessed.Time) {
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		lease.Annotations[LastProcessedAnnotation] = processed.Time.Format(time.RFC3339Nano)
+	}
+
+	lease.Spec.HolderIdentity = pointer.String("")
+
+	_, err = l.locker.leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
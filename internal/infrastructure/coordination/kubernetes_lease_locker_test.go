@@ -0,0 +1,108 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestKubernetesLeaseIsExclusive(t *testing.T) {
+	leases := fake.NewSimpleClientset().CoordinationV1().Leases("argocd")
+	replica1 := NewKubernetesLeaseLockerFromClient(leases, "replica1", DefaultLeaseDuration)
+	replica2 := NewKubernetesLeaseLockerFromClient(leases, "replica2", DefaultLeaseDuration)
+	processed := time.Now().Add(-time.Minute)
+
+	lock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err = replica2.Acquire(ctx, "Projects-1")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("must not acquire a lease held by another replica")
+	}
+
+	err = lock.Release(context.Background(), ProcessedUpdate{Time: processed})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = replica2.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lock.LastProcessed().Time.Equal(processed) {
+		t.Fatal("the last processed time must be shared between replicas")
+	}
+}
+
+func TestExpiredKubernetesLeaseIsNotReleased(t *testing.T) {
+	leases := fake.NewSimpleClientset().CoordinationV1().Leases("argocd")
+	replica1 := NewKubernetesLeaseLockerFromClient(leases, "replica1", DefaultLeaseDuration)
+	replica2 := NewKubernetesLeaseLockerFromClient(leases, "replica2", DefaultLeaseDuration)
+
+	expiredLock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a replica that paused for longer than its lease
+	expiredLock.(*kubernetesLeaseLock).heartbeat.Stop()
+	lease, err := leases.Get(context.Background(), "octoargosync-projects-1", metav1.GetOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	lease.Spec.AcquireTime = &expired
+	lease.Spec.RenewTime = &expired
+
+	if _, err := leases.Update(context.Background(), lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same replica acquires the lease again for another release
+	lock, err := replica1.Acquire(context.Background(), "Projects-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := expiredLock.(*kubernetesLeaseLock).renew(context.Background()); !errors.Is(err, errLockLost) {
+		t.Fatalf("Expected the expired lock to be lost, got %v", err)
+	}
+
+	err = expiredLock.Release(context.Background(), ProcessedUpdate{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err = replica2.Acquire(ctx, "Projects-1")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("must not release a lease acquired again after it expired")
+	}
+
+	err = lock.Release(context.Background(), ProcessedUpdate{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
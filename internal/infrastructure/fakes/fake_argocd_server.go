@@ -0,0 +1,277 @@
+package fakes
+
+import (
+	"context"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/session"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/samber/lo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"sync"
+)
+
+// FakeArgoCDToken is the token accepted by the fake ArgoCD server.
+const FakeArgoCDToken = "fake-argocd-token"
+
+// fakeArgoCDDefaultNamespace is the namespace used by queries that do not define an application namespace.
+const fakeArgoCDDefaultNamespace = "argocd"
+
+// FakeArgoCDServer is an in-process ArgoCD gRPC API. It serves the application, project, cluster, and session services
+// used by the proxy from memory, so the ArgoCD client can be tested by pointing ARGOCD_SERVER at the server's address
+// with ARGOCD_PLAINTEXT set to true.
+//
+// Every application has a resource tree with a single Deployment referencing the application's images. Calls to any
+// other service method return an Unimplemented error.
+type FakeArgoCDServer struct {
+	server       *grpc.Server
+	listener     net.Listener
+	mutex        sync.Mutex
+	applications []*v1alpha1.Application
+	images       map[string][]string
+	projects     []*v1alpha1.AppProject
+	clusters     []v1alpha1.Cluster
+	requests     []string
+}
+
+// NewFakeArgoCDServer starts a fake ArgoCD server listening on a random local port. The server must be closed when
+// it is no longer needed.
+func NewFakeArgoCDServer() (*FakeArgoCDServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		return nil, err
+	}
+
+	fake := &FakeArgoCDServer{
+		listener: listener,
+		images:   map[string][]string{},
+	}
+
+	fake.server = grpc.NewServer(grpc.UnaryInterceptor(fake.authenticate))
+	application.RegisterApplicationServiceServer(fake.server, &fakeApplicationService{fake: fake})
+	project.RegisterProjectServiceServer(fake.server, &fakeProjectService{fake: fake})
+	cluster.RegisterClusterServiceServer(fake.server, &fakeClusterService{fake: fake})
+	session.RegisterSessionServiceServer(fake.server, &fakeSessionService{})
+
+	go func() {
+		// Serve only returns once the server is stopped
+		_ = fake.server.Serve(listener)
+	}()
+
+	return fake, nil
+}
+
+// Address returns the address to assign to ARGOCD_SERVER.
+func (f *FakeArgoCDServer) Address() string {
+	return f.listener.Addr().String()
+}
+
+// Close stops the server.
+func (f *FakeArgoCDServer) Close() {
+	f.server.Stop()
+}
+
+// AddApplication adds an application in the ArgoCD project, deploying the supplied images.
+func (f *FakeArgoCDServer) AddApplication(namespace string, name string, projectName string, images ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.applications = append(f.applications, &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1alpha1.ApplicationSpec{
+			Project: projectName,
+		},
+	})
+	f.images[namespace+"/"+name] = images
+}
+
+// SetImages replaces the images deployed by an application.
+func (f *FakeArgoCDServer) SetImages(namespace string, name string, images ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.images[namespace+"/"+name] = images
+}
+
+// AddProject adds an ArgoCD project.
+func (f *FakeArgoCDServer) AddProject(name string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.projects = append(f.projects, &v1alpha1.AppProject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: fakeArgoCDDefaultNamespace,
+		},
+	})
+}
+
+// AddCluster adds a cluster.
+func (f *FakeArgoCDServer) AddCluster(name string, server string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.clusters = append(f.clusters, v1alpha1.Cluster{
+		Name:   name,
+		Server: server,
+	})
+}
+
+// Requests returns the full names of the gRPC methods called on the server, in the order they were received.
+func (f *FakeArgoCDServer) Requests() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.requests...)
+}
+
+// authenticate records the request and rejects any request that does not include the fake token.
+func (f *FakeArgoCDServer) authenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, info.FullMethod)
+	f.mutex.Unlock()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !lo.Contains(md.Get(apiclient.MetaDataTokenKey), FakeArgoCDToken) {
+		return nil, status.Error(codes.Unauthenticated, "invalid session: token is not valid")
+	}
+
+	return handler(ctx, req)
+}
+
+// findApplication returns the named application. Queries without a namespace use the default ArgoCD namespace.
+func (f *FakeArgoCDServer) findApplication(name string, namespace string) (*v1alpha1.Application, error) {
+	if namespace == "" {
+		namespace = fakeArgoCDDefaultNamespace
+	}
+
+	argoApplication, found := lo.Find(f.applications, func(item *v1alpha1.Application) bool {
+		return item.Name == name && item.Namespace == namespace
+	})
+
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "applications.argoproj.io \"%s\" not found", name)
+	}
+
+	return argoApplication, nil
+}
+
+type fakeApplicationService struct {
+	application.UnimplementedApplicationServiceServer
+	fake *FakeArgoCDServer
+}
+
+func (s *fakeApplicationService) List(ctx context.Context, query *application.ApplicationQuery) (*v1alpha1.ApplicationList, error) {
+	s.fake.mutex.Lock()
+	defer s.fake.mutex.Unlock()
+
+	items := lo.FilterMap(s.fake.applications, func(item *v1alpha1.Application, index int) (v1alpha1.Application, bool) {
+		matches := (query.GetName() == "" || query.GetName() == item.Name) &&
+			(query.GetAppNamespace() == "" || query.GetAppNamespace() == item.Namespace)
+		return *item.DeepCopy(), matches
+	})
+
+	return &v1alpha1.ApplicationList{Items: items}, nil
+}
+
+func (s *fakeApplicationService) Get(ctx context.Context, query *application.ApplicationQuery) (*v1alpha1.Application, error) {
+	s.fake.mutex.Lock()
+	defer s.fake.mutex.Unlock()
+
+	argoApplication, err := s.fake.findApplication(query.GetName(), query.GetAppNamespace())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return argoApplication.DeepCopy(), nil
+}
+
+func (s *fakeApplicationService) ResourceTree(ctx context.Context, query *application.ResourcesQuery) (*v1alpha1.ApplicationTree, error) {
+	s.fake.mutex.Lock()
+	defer s.fake.mutex.Unlock()
+
+	argoApplication, err := s.fake.findApplication(query.GetApplicationName(), query.GetAppNamespace())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1alpha1.ApplicationTree{
+		Nodes: []v1alpha1.ResourceNode{
+			{
+				ResourceRef: v1alpha1.ResourceRef{
+					Group:     "apps",
+					Version:   "v1",
+					Kind:      "Deployment",
+					Namespace: argoApplication.Namespace,
+					Name:      argoApplication.Name,
+				},
+				Images: append([]string{}, s.fake.images[argoApplication.Namespace+"/"+argoApplication.Name]...),
+			},
+		},
+	}, nil
+}
+
+type fakeProjectService struct {
+	project.UnimplementedProjectServiceServer
+	fake *FakeArgoCDServer
+}
+
+func (s *fakeProjectService) List(ctx context.Context, query *project.ProjectQuery) (*v1alpha1.AppProjectList, error) {
+	s.fake.mutex.Lock()
+	defer s.fake.mutex.Unlock()
+
+	return &v1alpha1.AppProjectList{
+		Items: lo.Map(s.fake.projects, func(item *v1alpha1.AppProject, index int) v1alpha1.AppProject {
+			return *item.DeepCopy()
+		}),
+	}, nil
+}
+
+func (s *fakeProjectService) Get(ctx context.Context, query *project.ProjectQuery) (*v1alpha1.AppProject, error) {
+	s.fake.mutex.Lock()
+	defer s.fake.mutex.Unlock()
+
+	appProject, found := lo.Find(s.fake.projects, func(item *v1alpha1.AppProject) bool {
+		return item.Name == query.GetName()
+	})
+
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "appprojects.argoproj.io \"%s\" not found", query.GetName())
+	}
+
+	return appProject.DeepCopy(), nil
+}
+
+type fakeClusterService struct {
+	cluster.UnimplementedClusterServiceServer
+	fake *FakeArgoCDServer
+}
+
+func (s *fakeClusterService) List(ctx context.Context, query *cluster.ClusterQuery) (*v1alpha1.ClusterList, error) {
+	s.fake.mutex.Lock()
+	defer s.fake.mutex.Unlock()
+
+	return &v1alpha1.ClusterList{Items: append([]v1alpha1.Cluster{}, s.fake.clusters...)}, nil
+}
+
+type fakeSessionService struct {
+	session.UnimplementedSessionServiceServer
+}
+
+// GetUserInfo is only reached with a valid token, as other requests are rejected by the authenticate interceptor.
+func (s *fakeSessionService) GetUserInfo(ctx context.Context, query *session.GetUserInfoRequest) (*session.GetUserInfoResponse, error) {
+	return &session.GetUserInfoResponse{LoggedIn: true, Username: "admin"}, nil
+}
@@ -0,0 +1,673 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/channels"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/deployments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/environments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/feeds"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/packages"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/projects"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/releases"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/variables"
+	"github.com/samber/lo"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeOctopusApiKey is the API key accepted by the fake Octopus server.
+const FakeOctopusApiKey = "API-FAKEOCTOPUSAPIKEY"
+
+// FakeOctopusSpaceId is the space served by the fake Octopus server.
+const FakeOctopusSpaceId = "Spaces-1"
+
+// FakeOctopusUserId is the user the fake Octopus server authenticates the API key as.
+const FakeOctopusUserId = "Users-1"
+
+// fakeOctopusPermissions are the space permissions granted to the fake user, unless they have been revoked.
+var fakeOctopusPermissions = []string{
+	"ProjectView",
+	"ProcessView",
+	"VariableView",
+	"EnvironmentView",
+	"LifecycleView",
+	"FeedView",
+	"ReleaseView",
+	"ReleaseCreate",
+	"DeploymentView",
+	"DeploymentCreate",
+}
+
+// fakeOctopusStart is the time assigned to the first resource created by the fake Octopus server. Every resource
+// created after it is a minute newer, so results are ordered the same way in every run.
+var fakeOctopusStart = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FakeOctopusRequest is a request received by the fake Octopus server.
+type FakeOctopusRequest struct {
+	Method string
+	Path   string
+	Query  string `json:",omitempty"`
+	Body   string `json:",omitempty"`
+}
+
+// FakeOctopusServer is an in-process Octopus REST API. It serves the subset of the API used by the proxy from memory,
+// so the live Octopus client can be tested by pointing OCTOPUS_SERVER at the server's URL.
+//
+// Resources are added with the Add functions. Releases and deployments created through the API are stored and can be
+// inspected, and every request is recorded. Octopus does not automatically deploy releases created by the fake, and
+// channel version rules are ignored when selecting package versions.
+type FakeOctopusServer struct {
+	server              *httptest.Server
+	mutex               sync.Mutex
+	ids                 map[string]int
+	clock               time.Time
+	environments        []*environments.Environment
+	lifecycles          []*lifecycles.Lifecycle
+	projects            []*projects.Project
+	channels            []*channels.Channel
+	variables           map[string]*variables.VariableSet
+	deploymentProcesses map[string]*deployments.DeploymentProcess
+	templates           map[string]*deployments.DeploymentProcessTemplate
+	feeds               []*feeds.FeedResource
+	packageVersions     map[string][]string
+	releases            []*releases.Release
+	deployments         []*deployments.Deployment
+	revokedPermissions  map[string]bool
+	requests            []FakeOctopusRequest
+}
+
+// NewFakeOctopusServer starts a fake Octopus server. The server must be closed when it is no longer needed.
+func NewFakeOctopusServer() *FakeOctopusServer {
+	fake := &FakeOctopusServer{
+		ids:                 map[string]int{},
+		clock:               fakeOctopusStart,
+		variables:           map[string]*variables.VariableSet{},
+		deploymentProcesses: map[string]*deployments.DeploymentProcess{},
+		templates:           map[string]*deployments.DeploymentProcessTemplate{},
+		packageVersions:     map[string][]string{},
+		revokedPermissions:  map[string]bool{},
+	}
+
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.handle))
+
+	return fake
+}
+
+// URL returns the URL to assign to OCTOPUS_SERVER.
+func (f *FakeOctopusServer) URL() string {
+	return f.server.URL
+}
+
+// Close stops the server.
+func (f *FakeOctopusServer) Close() {
+	f.server.Close()
+}
+
+// RevokePermission removes a space permission from the fake user, like ReleaseCreate.
+func (f *FakeOctopusServer) RevokePermission(permission string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.revokedPermissions[permission] = true
+}
+
+// AddEnvironment adds an environment, returning its ID.
+func (f *FakeOctopusServer) AddEnvironment(name string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	environment := environments.NewEnvironment(name)
+	environment.ID = f.nextId("Environments")
+	environment.SpaceID = FakeOctopusSpaceId
+	f.environments = append(f.environments, environment)
+
+	return environment.ID
+}
+
+// AddLifecycle adds a lifecycle with the supplied phases, returning its ID.
+func (f *FakeOctopusServer) AddLifecycle(name string, phases ...*lifecycles.Phase) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	lifecycle := lifecycles.NewLifecycle(name)
+	lifecycle.ID = f.nextId("Lifecycles")
+	lifecycle.SpaceID = FakeOctopusSpaceId
+	lifecycle.Phases = phases
+	f.lifecycles = append(f.lifecycles, lifecycle)
+
+	return lifecycle.ID
+}
+
+// AddProject adds a project with a default channel, an empty deployment process, and no variables, returning the
+// project's ID. The default channel has no lifecycle, so it uses the project's lifecycle.
+func (f *FakeOctopusServer) AddProject(name string, lifecycleId string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	project := projects.NewProject(name, lifecycleId, "ProjectGroups-1")
+	project.ID = f.nextId("Projects")
+	project.SpaceID = FakeOctopusSpaceId
+	project.DeploymentProcessID = "deploymentprocess-" + project.ID
+	project.VariableSetID = "variableset-" + project.ID
+	f.projects = append(f.projects, project)
+
+	channel := channels.NewChannel("Default", project.ID)
+	channel.ID = f.nextId("Channels")
+	channel.IsDefault = true
+	f.channels = append(f.channels, channel)
+
+	deploymentProcess := deployments.NewDeploymentProcess(project.ID)
+	deploymentProcess.ID = project.DeploymentProcessID
+	deploymentProcess.Links = map[string]string{
+		"Template": "/api/" + FakeOctopusSpaceId + "/deploymentprocesses/" + deploymentProcess.ID + "/template{?channel,releaseId}",
+	}
+	f.deploymentProcesses[deploymentProcess.ID] = deploymentProcess
+	f.templates[deploymentProcess.ID] = &deployments.DeploymentProcessTemplate{DeploymentProcessId: deploymentProcess.ID}
+
+	f.variables[project.ID] = &variables.VariableSet{OwnerID: project.ID, Variables: []*variables.Variable{}}
+
+	return project.ID
+}
+
+// AddChannel adds a channel to a project, returning its ID. An empty lifecycle ID means the channel uses the
+// project's lifecycle.
+func (f *FakeOctopusServer) AddChannel(projectId string, name string, lifecycleId string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	channel := channels.NewChannel(name, projectId)
+	channel.ID = f.nextId("Channels")
+	channel.LifecycleID = lifecycleId
+	f.channels = append(f.channels, channel)
+
+	return channel.ID
+}
+
+// DefaultChannelID returns the ID of a project's default channel.
+func (f *FakeOctopusServer) DefaultChannelID(projectId string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	channel, _ := lo.Find(f.channels, func(item *channels.Channel) bool {
+		return item.ProjectID == projectId && item.IsDefault
+	})
+
+	if channel == nil {
+		return ""
+	}
+
+	return channel.ID
+}
+
+// AddVariable adds an unscoped variable to a project.
+func (f *FakeOctopusServer) AddVariable(projectId string, name string, value string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	variable := variables.NewVariable(name)
+	variable.ID = f.nextId("Variables")
+	variable.Value = value
+	f.variables[projectId].Variables = append(f.variables[projectId].Variables, variable)
+}
+
+// AddFeed adds a docker feed, returning its ID.
+func (f *FakeOctopusServer) AddFeed(name string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	feed := feeds.NewFeedResource(name, feeds.FeedTypeDocker)
+	feed.ID = f.nextId("Feeds")
+	feed.SpaceID = FakeOctopusSpaceId
+	feed.Links = map[string]string{
+		"SearchPackageVersionsTemplate": "/api/" + FakeOctopusSpaceId + "/feeds/" + feed.ID +
+			"/packages/versions{?packageId,take,skip,includePreRelease,versionRange,preReleaseTag,filter,includeReleaseNotes}",
+	}
+	f.feeds = append(f.feeds, feed)
+
+	return feed.ID
+}
+
+// AddPackageVersions adds versions of a package to a feed. The latest version must be listed first.
+func (f *FakeOctopusServer) AddPackageVersions(feedId string, packageId string, versions ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.packageVersions[feedId+"/"+packageId] = append(f.packageVersions[feedId+"/"+packageId], versions...)
+}
+
+// AddDeploymentStep adds a step with a single action that deploys a package to a project's deployment process. An
+// empty package reference name adds the step's primary package.
+func (f *FakeOctopusServer) AddDeploymentStep(projectId string, stepName string, feedId string, packageId string, packageReferenceName string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deploymentProcessId := "deploymentprocess-" + projectId
+	deploymentProcess := f.deploymentProcesses[deploymentProcessId]
+
+	action := deployments.NewDeploymentAction(stepName, "Octopus.KubernetesDeployContainers")
+	action.ID = f.nextId("Actions")
+	action.Packages = []*packages.PackageReference{{
+		FeedID:    feedId,
+		Name:      packageReferenceName,
+		PackageID: packageId,
+	}}
+
+	step := deployments.NewDeploymentStep(stepName)
+	step.ID = f.nextId("Steps")
+	step.Actions = []*deployments.DeploymentAction{action}
+	deploymentProcess.Steps = append(deploymentProcess.Steps, step)
+
+	template := f.templates[deploymentProcessId]
+	template.Packages = append(template.Packages, releases.ReleaseTemplatePackage{
+		ActionName:           stepName,
+		FeedID:               feedId,
+		IsResolvable:         true,
+		PackageID:            packageId,
+		PackageReferenceName: packageReferenceName,
+		StepName:             stepName,
+	})
+}
+
+// AddRelease adds a release to a project's channel, returning its ID.
+func (f *FakeOctopusServer) AddRelease(projectId string, channelId string, version string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	release := releases.NewRelease(channelId, projectId, version)
+	release.ID = f.nextId("Releases")
+	release.SpaceID = FakeOctopusSpaceId
+	release.Assembled = f.tick()
+	f.releases = append(f.releases, release)
+
+	return release.ID
+}
+
+// AddDeployment adds a deployment of a release to an environment, returning its ID.
+func (f *FakeOctopusServer) AddDeployment(releaseId string, environmentId string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deployment := deployments.NewDeployment(environmentId, releaseId)
+	f.addDeployment(deployment)
+
+	return deployment.ID
+}
+
+// Releases returns the releases in the order they were created.
+func (f *FakeOctopusServer) Releases() []*releases.Release {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]*releases.Release{}, f.releases...)
+}
+
+// Deployments returns the deployments in the order they were created.
+func (f *FakeOctopusServer) Deployments() []*deployments.Deployment {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]*deployments.Deployment{}, f.deployments...)
+}
+
+// Requests returns the requests received by the server.
+func (f *FakeOctopusServer) Requests() []FakeOctopusRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]FakeOctopusRequest{}, f.requests...)
+}
+
+// ResetRequests clears the recorded requests.
+func (f *FakeOctopusServer) ResetRequests() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests = nil
+}
+
+func (f *FakeOctopusServer) nextId(prefix string) string {
+	f.ids[prefix]++
+	return prefix + "-" + strconv.Itoa(f.ids[prefix])
+}
+
+func (f *FakeOctopusServer) tick() time.Time {
+	f.clock = f.clock.Add(time.Minute)
+	return f.clock
+}
+
+func (f *FakeOctopusServer) addDeployment(deployment *deployments.Deployment) {
+	deployment.ID = f.nextId("Deployments")
+	deployment.SpaceID = FakeOctopusSpaceId
+	created := f.tick()
+	deployment.Created = &created
+
+	if release, found := lo.Find(f.releases, func(item *releases.Release) bool { return item.ID == deployment.ReleaseID }); found {
+		deployment.ProjectID = release.ProjectID
+		deployment.ChannelID = release.ChannelID
+	}
+
+	f.deployments = append(f.deployments, deployment)
+}
+
+func (f *FakeOctopusServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests = append(f.requests, FakeOctopusRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Body:   string(body),
+	})
+
+	if r.Header.Get("X-Octopus-ApiKey") != FakeOctopusApiKey {
+		writeFakeError(w, http.StatusUnauthorized, "You must be logged in to perform this action.")
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(path) == 1 && path[0] == "api":
+		writeFakeJson(w, http.StatusOK, fakeRoot("/api", map[string]string{"Spaces": "/api/spaces{/id}{?skip,ids,take,partialName}"}))
+	case r.Method == http.MethodGet && matchFakeRoute(path, "api/spaces/all"):
+		writeFakeJson(w, http.StatusOK, []map[string]any{{"Id": FakeOctopusSpaceId, "Name": "Default", "IsDefault": true}})
+	case r.Method == http.MethodGet && matchFakeRoute(path, "api/users/me"):
+		writeFakeJson(w, http.StatusOK, map[string]any{"Id": FakeOctopusUserId, "Username": "octoargosync"})
+	case r.Method == http.MethodGet && matchFakeRoute(path, "api/users/"+FakeOctopusUserId+"/permissions"):
+		writeFakeJson(w, http.StatusOK, f.userPermissions())
+	case len(path) >= 2 && path[0] == "api" && path[1] == FakeOctopusSpaceId:
+		f.handleSpace(w, r, path[2:], body)
+	default:
+		writeFakeError(w, http.StatusNotFound, "The resource you requested was not found.")
+	}
+}
+
+func (f *FakeOctopusServer) handleSpace(w http.ResponseWriter, r *http.Request, path []string, body []byte) {
+	query := r.URL.Query()
+	route := func(method string, pattern string) bool {
+		return r.Method == method && matchFakeRoute(path, pattern)
+	}
+
+	switch {
+	case route(http.MethodGet, ""):
+		writeFakeJson(w, http.StatusOK, fakeRoot("/api/"+FakeOctopusSpaceId, fakeSpaceLinks()))
+	case route(http.MethodGet, "projects"):
+		writeFakePage(w, filterByIds(f.projects, query), query)
+	case route(http.MethodGet, "projects/{id}/releases"):
+		writeFakePage(w, f.projectReleases(path[1]), query)
+	case route(http.MethodGet, "projects/{id}/releases/{version}"):
+		release, found := lo.Find(f.projectReleases(path[1]), func(item *releases.Release) bool { return item.Version == path[3] })
+		writeFakeItem(w, release, found)
+	case route(http.MethodGet, "channels"):
+		writeFakePage(w, filterByIds(f.channels, query), query)
+	case route(http.MethodGet, "channels/{id}"):
+		channel, found := lo.Find(f.channels, func(item *channels.Channel) bool { return item.ID == path[1] })
+		writeFakeItem(w, channel, found)
+	case route(http.MethodGet, "environments"):
+		writeFakePage(w, lo.Filter(filterByIds(f.environments, query), func(item *environments.Environment, index int) bool {
+			return query.Get("name") == "" || strings.EqualFold(item.Name, query.Get("name"))
+		}), query)
+	case route(http.MethodGet, "lifecycles"):
+		writeFakePage(w, filterByIds(f.lifecycles, query), query)
+	case route(http.MethodGet, "variables/{id}"):
+		variableSet, found := f.variables[strings.TrimPrefix(path[1], "variableset-")]
+		writeFakeItem(w, variableSet, found)
+	case route(http.MethodGet, "deploymentprocesses/{id}"):
+		deploymentProcess, found := f.deploymentProcesses[path[1]]
+		writeFakeItem(w, deploymentProcess, found)
+	case route(http.MethodGet, "deploymentprocesses/{id}/template"):
+		template, found := f.templates[path[1]]
+		writeFakeItem(w, template, found)
+	case route(http.MethodGet, "feeds"):
+		writeFakePage(w, filterByIds(f.feeds, query), query)
+	case route(http.MethodGet, "feeds/{id}/packages/versions"):
+		writeFakePage(w, f.feedPackageVersions(path[1], query.Get("packageId")), query)
+	case route(http.MethodGet, "releases/{id}/deployments"):
+		writeFakePage(w, lo.Filter(f.deployments, func(item *deployments.Deployment, index int) bool {
+			return item.ReleaseID == path[1]
+		}), query)
+	case route(http.MethodGet, "releases/{id}"):
+		release, found := lo.Find(f.releases, func(item *releases.Release) bool { return item.ID == path[1] })
+		writeFakeItem(w, release, found)
+	case route(http.MethodGet, "deployments"):
+		writeFakePage(w, f.filterDeployments(query), query)
+	case route(http.MethodPost, "releases"):
+		f.createRelease(w, body)
+	case route(http.MethodPost, "deployments"):
+		f.createDeployment(w, body)
+	default:
+		writeFakeError(w, http.StatusNotFound, "The resource you requested was not found.")
+	}
+}
+
+// matchFakeRoute matches a request path to a pattern, where segments in braces match any value.
+func matchFakeRoute(path []string, pattern string) bool {
+	segments := []string{}
+	if pattern != "" {
+		segments = strings.Split(pattern, "/")
+	}
+
+	if len(path) != len(segments) {
+		return false
+	}
+
+	for index, segment := range segments {
+		if !strings.HasPrefix(segment, "{") && segment != path[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// userPermissions returns the permissions of the fake user, granted in the fake space.
+func (f *FakeOctopusServer) userPermissions() map[string]any {
+	spacePermissions := map[string][]map[string]any{}
+	for _, permission := range fakeOctopusPermissions {
+		if !f.revokedPermissions[permission] {
+			spacePermissions[permission] = []map[string]any{{
+				"SpaceId":                    FakeOctopusSpaceId,
+				"RestrictedToProjectIds":     []string{},
+				"RestrictedToEnvironmentIds": []string{},
+				"RestrictedToTenantIds":      []string{},
+			}}
+		}
+	}
+
+	return map[string]any{
+		"Id":                FakeOctopusUserId,
+		"SpacePermissions":  spacePermissions,
+		"SystemPermissions": []string{},
+	}
+}
+
+// projectReleases returns the releases of a project, newest first.
+func (f *FakeOctopusServer) projectReleases(projectId string) []*releases.Release {
+	projectReleases := lo.Filter(f.releases, func(item *releases.Release, index int) bool {
+		return item.ProjectID == projectId
+	})
+
+	sort.SliceStable(projectReleases, func(i, j int) bool {
+		return projectReleases[i].Assembled.After(projectReleases[j].Assembled)
+	})
+
+	return projectReleases
+}
+
+func (f *FakeOctopusServer) feedPackageVersions(feedId string, packageId string) []*packages.PackageVersion {
+	return lo.Map(f.packageVersions[feedId+"/"+packageId], func(item string, index int) *packages.PackageVersion {
+		packageVersion := packages.NewPackageVersion()
+		packageVersion.FeedID = feedId
+		packageVersion.PackageID = packageId
+		packageVersion.Version = item
+		return packageVersion
+	})
+}
+
+// filterDeployments returns the deployments matching the projects and environments filters, newest first.
+func (f *FakeOctopusServer) filterDeployments(query url.Values) []*deployments.Deployment {
+	filter := func(name string) []string {
+		return lo.FlatMap(query[name], func(item string, index int) []string { return strings.Split(item, ",") })
+	}
+	projectIds := filter("projects")
+	environmentIds := filter("environments")
+
+	filtered := lo.Filter(f.deployments, func(item *deployments.Deployment, index int) bool {
+		return (len(projectIds) == 0 || lo.Contains(projectIds, item.ProjectID)) &&
+			(len(environmentIds) == 0 || lo.Contains(environmentIds, item.EnvironmentID))
+	})
+
+	return lo.Reverse(filtered)
+}
+
+func (f *FakeOctopusServer) createRelease(w http.ResponseWriter, body []byte) {
+	release := &releases.Release{}
+	if err := json.Unmarshal(body, release); err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !lo.ContainsBy(f.projects, func(item *projects.Project) bool { return item.ID == release.ProjectID }) {
+		writeFakeError(w, http.StatusBadRequest, "The project "+release.ProjectID+" does not exist.")
+		return
+	}
+
+	if lo.ContainsBy(f.releases, func(item *releases.Release) bool {
+		return item.ProjectID == release.ProjectID && item.Version == release.Version
+	}) {
+		writeFakeError(w, http.StatusBadRequest, "A release with the version "+release.Version+" already exists.")
+		return
+	}
+
+	release.ID = f.nextId("Releases")
+	release.SpaceID = FakeOctopusSpaceId
+	release.Assembled = f.tick()
+	f.releases = append(f.releases, release)
+
+	writeFakeJson(w, http.StatusCreated, release)
+}
+
+func (f *FakeOctopusServer) createDeployment(w http.ResponseWriter, body []byte) {
+	deployment := &deployments.Deployment{}
+	if err := json.Unmarshal(body, deployment); err != nil {
+		writeFakeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !lo.ContainsBy(f.releases, func(item *releases.Release) bool { return item.ID == deployment.ReleaseID }) {
+		writeFakeError(w, http.StatusBadRequest, "The release "+deployment.ReleaseID+" does not exist.")
+		return
+	}
+
+	if !lo.ContainsBy(f.environments, func(item *environments.Environment) bool { return item.ID == deployment.EnvironmentID }) {
+		writeFakeError(w, http.StatusBadRequest, "The environment "+deployment.EnvironmentID+" does not exist.")
+		return
+	}
+
+	f.addDeployment(deployment)
+
+	writeFakeJson(w, http.StatusCreated, deployment)
+}
+
+// fakeSpaceLinks returns the links to the collections in the space, which the client library uses to build requests.
+func fakeSpaceLinks() map[string]string {
+	prefix := "/api/" + FakeOctopusSpaceId
+	return map[string]string{
+		"Channels":            prefix + "/channels{/id}{?skip,take,ids,partialName}",
+		"DeploymentProcesses": prefix + "/deploymentprocesses{/id}{?skip,take,ids}",
+		"Deployments":         prefix + "/deployments{/id}{?skip,take,ids,projects,environments,tenants,channels,taskState,partialName}",
+		"Environments":        prefix + "/environments{/id}{?name,skip,ids,take,partialName}",
+		"Feeds":               prefix + "/feeds{/id}{?skip,take,ids,partialName,feedType,name}",
+		"Lifecycles":          prefix + "/lifecycles{/id}{?skip,take,ids,partialName}",
+		"Projects":            prefix + "/projects{/id}{?name,skip,ids,clone,take,partialName,clonedFromProjectId}",
+		"Releases":            prefix + "/releases{/id}{?skip,ignoreChannelRules,take,ids}",
+		"Variables":           prefix + "/variables{/id}{?ids}",
+	}
+}
+
+func fakeRoot(self string, links map[string]string) map[string]any {
+	links["Self"] = self
+	return map[string]any{
+		"Application":    "Octopus Deploy",
+		"Version":        "2023.2.0",
+		"ApiVersion":     "3.0.0",
+		"InstallationId": "00000000-0000-0000-0000-000000000000",
+		"Links":          links,
+	}
+}
+
+// filterByIds returns the resources matching the ids query parameter, which may be repeated or comma separated.
+func filterByIds[T interface{ GetID() string }](items []T, query url.Values) []T {
+	ids := lo.FlatMap(query["ids"], func(item string, index int) []string {
+		return strings.Split(item, ",")
+	})
+
+	if len(ids) == 0 {
+		return items
+	}
+
+	return lo.Filter(items, func(item T, index int) bool {
+		return lo.Contains(ids, item.GetID())
+	})
+}
+
+func writeFakePage[T any](w http.ResponseWriter, items []T, query url.Values) {
+	// Octopus returns 30 items when the page size is not specified
+	skip, _ := strconv.Atoi(query.Get("skip"))
+	take, err := strconv.Atoi(query.Get("take"))
+
+	if err != nil || take <= 0 {
+		take = 30
+	}
+
+	page := lo.Subset(items, skip, uint(take))
+
+	writeFakeJson(w, http.StatusOK, map[string]any{
+		"Items":        append([]T{}, page...),
+		"ItemsPerPage": take,
+		"TotalResults": len(items),
+	})
+}
+
+func writeFakeItem[T any](w http.ResponseWriter, item T, found bool) {
+	if !found {
+		writeFakeError(w, http.StatusNotFound, "The resource you requested was not found.")
+		return
+	}
+
+	writeFakeJson(w, http.StatusOK, item)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, message string) {
+	writeFakeJson(w, status, map[string]any{"ErrorMessage": message, "Errors": []string{message}})
+}
+
+func writeFakeJson(w http.ResponseWriter, status int, body any) {
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		status = http.StatusInternalServerError
+		data = []byte(fmt.Sprintf(`{"ErrorMessage":%q}`, err.Error()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
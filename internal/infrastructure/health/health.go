@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Status is the result of a readiness check.
+type Status string
+
+const (
+	// StatusOk means the dependency is working
+	StatusOk Status = "ok"
+	// StatusDegraded means the dependency is not working, but the proxy can still accept notifications, for example
+	// by queuing releases until Octopus is available again
+	StatusDegraded Status = "degraded"
+	// StatusFailed means the proxy can not process notifications until the problem is fixed
+	StatusFailed Status = "failed"
+)
+
+// DefaultTimeout is how long each check can take before it is reported as failed.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultCacheTtl is how long the result of the checks is reused, so frequent probes do not overload Octopus or ArgoCD.
+const DefaultCacheTtl = 10 * time.Second
+
+// Check tests a dependency, returning a message describing the result.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// CheckResult is the result of a single check.
+type CheckResult struct {
+	Name     string
+	Status   Status
+	Message  string `json:",omitempty"`
+	Duration string
+}
+
+// Report is the result of every check. The status is the worst status of the individual checks.
+type Report struct {
+	Status  Status
+	Checked time.Time
+	Checks  []CheckResult
+}
+
+// Checker runs the readiness checks, caching the report to limit the requests made by frequent probes.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	cacheTtl time.Duration
+	mutex    sync.Mutex
+	report   *Report
+}
+
+func NewChecker(timeout time.Duration, cacheTtl time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:   checks,
+		timeout:  timeout,
+		cacheTtl: cacheTtl,
+	}
+}
+
+// NewDefaultChecker creates a Checker configured with the READINESS_TIMEOUT and READINESS_CACHE_TTL environment
+// variables.
+func NewDefaultChecker(checks ...Check) (*Checker, error) {
+	timeout, err := getDurationEnv("READINESS_TIMEOUT", DefaultTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cacheTtl, err := getDurationEnv("READINESS_CACHE_TTL", DefaultCacheTtl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewChecker(timeout, cacheTtl, checks...), nil
+}
+
+// Check runs the checks concurrently, or returns the cached report if it is still fresh.
+func (c *Checker) Check(ctx context.Context) Report {
+	// Concurrent probes wait for the same checks to complete rather than running them again
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.report != nil && time.Since(c.report.Checked) < c.cacheTtl {
+		return *c.report
+	}
+
+	results := make([]CheckResult, len(c.checks))
+	var wait sync.WaitGroup
+	for i, check := range c.checks {
+		wait.Add(1)
+		go func(i int, check Check) {
+			defer wait.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+
+	wait.Wait()
+
+	report := Report{
+		Status:  StatusOk,
+		Checked: time.Now().UTC(),
+		Checks:  results,
+	}
+
+	for _, result := range results {
+		report.Status = worst(report.Status, result.Status)
+	}
+
+	// A check cancelled by the probe disconnecting is not a result worth caching
+	if ctx.Err() == nil {
+		c.report = &report
+	}
+
+	return report
+}
+
+// run runs a single check with the timeout.
+func (c *Checker) run(ctx context.Context, check Check) CheckResult {
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	started := time.Now()
+	status, message := check.Run(checkCtx)
+
+	if checkCtx.Err() != nil && status == StatusOk {
+		status, message = StatusFailed, "the check did not complete within "+c.timeout.String()
+	}
+
+	return CheckResult{
+		Name:     check.Name,
+		Status:   status,
+		Message:  message,
+		Duration: time.Since(started).Round(time.Millisecond).String(),
+	}
+}
+
+// worst returns the more severe of two statuses.
+func worst(first Status, second Status) Status {
+	if first == StatusFailed || second == StatusFailed {
+		return StatusFailed
+	}
+
+	if first == StatusDegraded || second == StatusDegraded {
+		return StatusDegraded
+	}
+
+	return StatusOk
+}
+
+func getDurationEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	if os.Getenv(name) == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(os.Getenv(name))
+
+	if err != nil {
+		return 0, errors.New("octoargosync-init-healtherror - " + name + " must be a duration like 5s: " + err.Error())
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReportHasWorstStatus(t *testing.T) {
+	checker := NewChecker(time.Second, 0,
+		Check{Name: "ok", Run: func(ctx context.Context) (Status, string) { return StatusOk, "" }},
+		Check{Name: "degraded", Run: func(ctx context.Context) (Status, string) { return StatusDegraded, "slow" }})
+
+	report := checker.Check(context.Background())
+
+	if report.Status != StatusDegraded || len(report.Checks) != 2 || report.Checks[1].Message != "slow" {
+		t.Fatalf("Expected a degraded report, got %+v", report)
+	}
+
+	checker = NewChecker(time.Second, 0,
+		Check{Name: "failed", Run: func(ctx context.Context) (Status, string) { return StatusFailed, "" }},
+		Check{Name: "degraded", Run: func(ctx context.Context) (Status, string) { return StatusDegraded, "" }})
+
+	if report := checker.Check(context.Background()); report.Status != StatusFailed {
+		t.Fatalf("Expected a failed report, got %+v", report)
+	}
+}
+
+func TestReportIsCached(t *testing.T) {
+	runs := atomic.Int32{}
+	checker := NewChecker(time.Second, time.Hour,
+		Check{Name: "counted", Run: func(ctx context.Context) (Status, string) {
+			runs.Add(1)
+			return StatusOk, ""
+		}})
+
+	checker.Check(context.Background())
+	checker.Check(context.Background())
+
+	if runs.Load() != 1 {
+		t.Fatalf("Expected the check to run once, ran %d times", runs.Load())
+	}
+}
+
+func TestCheckTimesOut(t *testing.T) {
+	checker := NewChecker(10*time.Millisecond, 0,
+		Check{Name: "hung", Run: func(ctx context.Context) (Status, string) {
+			<-ctx.Done()
+			return StatusOk, ""
+		}})
+
+	if report := checker.Check(context.Background()); report.Status != StatusFailed {
+		t.Fatalf("Expected a check that does not complete to fail, got %+v", report)
+	}
+}
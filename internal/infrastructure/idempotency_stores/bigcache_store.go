@@ -0,0 +1,112 @@
+package idempotency_stores
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/idempotency"
+	"github.com/allegro/bigcache/v3"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long the results for idempotency keys are remembered by default.
+const DefaultTTL = 24 * time.Hour
+
+// BigCacheStore is an in memory idempotency.Store whose entries expire after a TTL.
+type BigCacheStore struct {
+	bigCache *bigcache.BigCache
+	// mutex ensures checking for and saving a result is atomic
+	mutex sync.Mutex
+}
+
+func NewBigCacheStore(ttl time.Duration) (*BigCacheStore, error) {
+	bCache, err := bigcache.New(context.Background(), bigcache.DefaultConfig(ttl))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &BigCacheStore{
+		bigCache: bCache,
+	}, nil
+}
+
+func (s *BigCacheStore) Remember(key string, result idempotency.Result) (idempotency.Result, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existingData, err := s.bigCache.Get(key)
+
+	if err == nil {
+		existing := idempotency.Result{}
+		err = json.Unmarshal(existingData, &existing)
+
+		if err != nil {
+			return idempotency.Result{}, false, err
+		}
+
+		return existing, true, nil
+	}
+
+	if !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return idempotency.Result{}, false, err
+	}
+
+	resultData, err := json.Marshal(result)
+
+	if err != nil {
+		return idempotency.Result{}, false, err
+	}
+
+	err = s.bigCache.Set(key, resultData)
+
+	if err != nil {
+		return idempotency.Result{}, false, err
+	}
+
+	return result, false, nil
+}
+
+func (s *BigCacheStore) Complete(key string, result idempotency.Result) error {
+	resultData, err := json.Marshal(result)
+
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.bigCache.Set(key, resultData)
+}
+
+func (s *BigCacheStore) Forget(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.bigCache.Delete(key)
+
+	if err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// NewDefaultStore creates a BigCacheStore with the TTL defined in the IDEMPOTENCY_TTL environment variable.
+func NewDefaultStore() (*BigCacheStore, error) {
+	ttl := DefaultTTL
+	if os.Getenv("IDEMPOTENCY_TTL") != "" {
+		duration, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+
+		if err != nil {
+			return nil, errors.New("octoargosync-init-idempotencyerror - IDEMPOTENCY_TTL must be a duration like 24h: " + err.Error())
+		}
+
+		ttl = duration
+	}
+
+	return NewBigCacheStore(ttl)
+}
@@ -0,0 +1,81 @@
+package idempotency_stores
+
+import (
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/idempotency"
+	"testing"
+	"time"
+)
+
+func TestRememberDuplicate(t *testing.T) {
+	store, err := NewBigCacheStore(time.Minute)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, duplicate, err := store.Remember("key", idempotency.Result{StatusCode: 202, Body: []byte(`{"status":"OK"}`)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if duplicate {
+		t.Fatal("the first result must not be a duplicate")
+	}
+
+	result, duplicate, err := store.Remember("key", idempotency.Result{StatusCode: 500, Body: []byte(`{}`)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !duplicate || result.StatusCode != 202 || string(result.Body) != `{"status":"OK"}` {
+		t.Fatal("the original result must be returned for a duplicate")
+	}
+
+	err = store.Forget("key")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, duplicate, err = store.Remember("key", idempotency.Result{StatusCode: 202})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if duplicate {
+		t.Fatal("a forgotten key must not be a duplicate")
+	}
+}
+
+func TestCompleteReplacesResult(t *testing.T) {
+	store, err := NewBigCacheStore(time.Minute)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = store.Remember("key", idempotency.Result{StatusCode: 202, Body: []byte(`{"status":"OK"}`)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Complete("key", idempotency.Result{StatusCode: 200, Body: []byte(`{"status":"OK","outcome":"completed"}`)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, duplicate, err := store.Remember("key", idempotency.Result{StatusCode: 202})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !duplicate || result.StatusCode != 200 {
+		t.Fatalf("the completed result must be returned for a duplicate, got %+v", result)
+	}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Errors counts the errors reported by the proxy by the stage of the pipeline that failed and the error code.
+var Errors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "octoargosync",
+	Name:      "errors_total",
+	Help:      "The number of errors by stage, error code, and category.",
+}, []string{"stage", "code", "category"})
+
+// MappingProblems is the number of problems found by the last mapping validation by error code.
+var MappingProblems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "octoargosync",
+	Name:      "mapping_problems",
+	Help:      "The number of problems found by the last mapping validation by error code.",
+}, []string{"code"})
+
+// MappingValidationTimestamp is the time the mappings were last validated successfully.
+var MappingValidationTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "octoargosync",
+	Name:      "mapping_validation_timestamp_seconds",
+	Help:      "The unix time the mappings were last validated successfully.",
+})
+
+// RecordError increments the error count for the error's code and category.
+func RecordError(stage string, err error) {
+	if err == nil {
+		return
+	}
+
+	appError := apperrors.Classify(err)
+	Errors.WithLabelValues(stage, string(appError.Code), string(appError.Category)).Inc()
+}
+
+// RecordMappingValidation replaces the mapping problem counts with the results of a validation.
+func RecordMappingValidation(problems []models.MappingProblem) {
+	MappingProblems.Reset()
+
+	for _, problem := range problems {
+		MappingProblems.WithLabelValues(problem.Code).Inc()
+	}
+
+	MappingValidationTimestamp.SetToCurrentTime()
+}
@@ -0,0 +1,53 @@
+package octopus_apis
+
+import (
+	"strings"
+)
+
+// Invalidate evicts the cached resources referenced by the document IDs of an Octopus event, so changes made in
+// Octopus take effect without waiting for the cache to expire.
+func (o *LiveOctopusClient) Invalidate(documentIds []string) []string {
+	projectIds := []string{}
+	for _, documentId := range documentIds {
+		if strings.HasPrefix(documentId, "Projects-") {
+			projectIds = append(projectIds, documentId)
+		}
+	}
+
+	evicted := []string{}
+	for _, documentId := range documentIds {
+		switch {
+		case strings.HasPrefix(documentId, "variableset-Projects-"):
+			// Project variable sets have IDs like variableset-Projects-1
+			o.index.invalidateProject(strings.TrimPrefix(documentId, "variableset-"))
+			evicted = append(evicted, documentId)
+		case strings.HasPrefix(documentId, "Projects-"):
+			o.index.invalidateProject(documentId)
+			evicted = append(evicted, documentId)
+		case strings.HasPrefix(documentId, "Channels-"):
+			// Channel events reference the channel's project, whose default channel may have changed
+			evicted = append(evicted, o.evict("AllChannels")...)
+			for _, projectId := range projectIds {
+				evicted = append(evicted, o.evict(projectId+"-DefaultChannel")...)
+			}
+		case strings.HasPrefix(documentId, "Environments-"):
+			// Environments are cached by name, so the eviction uses the name the environment had when it was cached
+			if name, ok := o.environmentNames.LoadAndDelete(documentId); ok {
+				evicted = append(evicted, o.evict("Environments-"+name.(string))...)
+			}
+		case strings.HasPrefix(documentId, "Lifecycles-"):
+			evicted = append(evicted, o.evict(documentId)...)
+		}
+	}
+
+	return evicted
+}
+
+// evict removes an entry from the cache, returning the key if it was cached.
+func (o *LiveOctopusClient) evict(key string) []string {
+	if err := o.bigCache.Delete(key); err != nil {
+		return nil
+	}
+
+	return []string{key}
+}
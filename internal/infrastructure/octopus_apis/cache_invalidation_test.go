@@ -0,0 +1,56 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/allegro/bigcache/v3"
+	"golang.org/x/exp/slices"
+	"testing"
+	"time"
+)
+
+func TestInvalidate(t *testing.T) {
+	source := newFakeProjectSource()
+	source.addProject("Projects-1", "Project 1", "argocd/app1")
+
+	bCache, err := bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &LiveOctopusClient{
+		bigCache: bCache,
+		index:    createTestIndex(t, source),
+	}
+
+	for _, key := range []string{"AllChannels", "Projects-1-DefaultChannel", "Projects-2-DefaultChannel", "Environments-Development", "Environments-Test", "Lifecycles-1"} {
+		if err := bCache.Set(key, []byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.environmentNames.Store("Environments-1", "Development")
+
+	if _, err := client.index.snapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	evicted := client.Invalidate([]string{"Projects-1", "Channels-1", "Environments-1", "Lifecycles-1"})
+	waitForRefresh(client.index)
+
+	for _, key := range []string{"Projects-1", "AllChannels", "Projects-1-DefaultChannel", "Environments-Development", "Lifecycles-1"} {
+		if !slices.Contains(evicted, key) {
+			t.Fatalf("Expected %s to be evicted, got %v", key, evicted)
+		}
+	}
+
+	// Resources that were not changed remain cached
+	for _, key := range []string{"Projects-2-DefaultChannel", "Environments-Test"} {
+		if _, err := bCache.Get(key); err != nil {
+			t.Fatalf("Expected %s to remain cached", key)
+		}
+	}
+
+	if source.getVariableLoads("Projects-1") != 2 {
+		t.Fatal("Expected the project variables to be reloaded")
+	}
+}
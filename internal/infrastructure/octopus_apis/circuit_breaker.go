@@ -0,0 +1,123 @@
+package octopus_apis
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitOpenError is returned when requests to Octopus are not sent because the circuit breaker is open.
+type CircuitOpenError struct {
+	Until time.Time
+	// Err is the error returned by the failed request, if any
+	Err error
+}
+
+func (e *CircuitOpenError) Error() string {
+	message := "requests to Octopus are paused until " + e.Until.Format(time.RFC3339) + " because Octopus is unavailable or overloaded"
+
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+
+	return message
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns how long to wait before Octopus can be contacted again.
+func (e *CircuitOpenError) RetryAfter() time.Duration {
+	return time.Until(e.Until)
+}
+
+// CircuitBreaker stops requests being sent to Octopus after a number of consecutive failures, or when Octopus
+// responds asking the client to back off. Once the cooldown has passed, a single request is allowed through to test
+// if Octopus is available again.
+type CircuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Allow returns an error if the circuit is open and a request must not be sent.
+func (c *CircuitBreaker) Allow() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.openUntil.IsZero() {
+		return nil
+	}
+
+	if time.Now().Before(c.openUntil) || c.probing {
+		return &CircuitOpenError{Until: c.openUntil}
+	}
+
+	// The cooldown has passed, so allow one request through to test the connection
+	c.probing = true
+	return nil
+}
+
+// Success records a successful request, closing the circuit.
+func (c *CircuitBreaker) Success() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.failures = 0
+	c.openUntil = time.Time{}
+	c.probing = false
+}
+
+// Failure records a failed request, opening the circuit if the threshold of consecutive failures is reached.
+func (c *CircuitBreaker) Failure() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.failures++
+	c.probing = false
+
+	if c.threshold > 0 && c.failures >= c.threshold {
+		c.openUntil = time.Now().Add(c.cooldown)
+	}
+}
+
+// Trip opens the circuit immediately for the supplied duration, or the cooldown if it is longer.
+func (c *CircuitBreaker) Trip(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if duration < c.cooldown {
+		duration = c.cooldown
+	}
+
+	c.failures++
+	c.probing = false
+	c.openUntil = time.Now().Add(duration)
+}
+
+// OpenUntil returns the time requests are being blocked until, and false if the circuit is closed.
+func (c *CircuitBreaker) OpenUntil() (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.openUntil, !c.openUntil.IsZero() && time.Now().Before(c.openUntil)
+}
+
+// Release records that an allowed request was not sent or was cancelled, allowing another request to test the
+// connection.
+func (c *CircuitBreaker) Release() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.probing = false
+}
@@ -0,0 +1,76 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/channels"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/deployments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/environments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/projects"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/releases"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/samber/lo"
+)
+
+// projectPages iterates over the projects in the space.
+func (o *LiveOctopusClient) projectPages(ctx context.Context) *PageIterator[*models.Project] {
+	return NewPageIterator(ctx, DefaultPageSize, func(skip int, take int) (Page[*models.Project], error) {
+		octopusProjects, err := o.client.Projects.Get(projects.ProjectsQuery{Skip: skip, Take: take})
+
+		if err != nil {
+			return Page[*models.Project]{}, err
+		}
+
+		return Page[*models.Project]{
+			Items: lo.Map(octopusProjects.Items, func(item *projects.Project, index int) *models.Project {
+				return toProject(item)
+			}),
+			TotalResults: octopusProjects.TotalResults,
+		}, nil
+	})
+}
+
+// channelPages iterates over the channels in the space.
+func (o *LiveOctopusClient) channelPages(ctx context.Context) *PageIterator[*models.Channel] {
+	return NewPageIterator(ctx, DefaultPageSize, func(skip int, take int) (Page[*models.Channel], error) {
+		octopusChannels, err := o.client.Channels.Get(channels.Query{Skip: skip, Take: take})
+
+		if err != nil {
+			return Page[*models.Channel]{}, err
+		}
+
+		return Page[*models.Channel]{
+			Items: lo.Map(octopusChannels.Items, func(item *channels.Channel, index int) *models.Channel {
+				return toChannel(item)
+			}),
+			TotalResults: octopusChannels.TotalResults,
+		}, nil
+	})
+}
+
+// environmentPages iterates over the environments in the space whose names match the supplied name.
+func (o *LiveOctopusClient) environmentPages(ctx context.Context, name string) *PageIterator[*models.Environment] {
+	return NewPageIterator(ctx, DefaultPageSize, func(skip int, take int) (Page[*models.Environment], error) {
+		octopusEnvironments, err := o.client.Environments.Get(environments.EnvironmentsQuery{Name: name, Skip: skip, Take: take})
+
+		if err != nil {
+			return Page[*models.Environment]{}, err
+		}
+
+		return Page[*models.Environment]{
+			Items: lo.Map(octopusEnvironments.Items, func(item *environments.Environment, index int) *models.Environment {
+				return toEnvironment(item)
+			}),
+			TotalResults: octopusEnvironments.TotalResults,
+		}, nil
+	})
+}
+
+// releasePages iterates over the releases in a project, newest first.
+func (o *LiveOctopusClient) releasePages(ctx context.Context, projectId string) *PageIterator[*releases.Release] {
+	return newApiPageIterator[*releases.Release](ctx, o.api, []string{"projects", projectId, "releases"}, nil)
+}
+
+// deploymentPages iterates over the deployments of a release.
+func (o *LiveOctopusClient) deploymentPages(ctx context.Context, releaseId string) *PageIterator[*deployments.Deployment] {
+	return newApiPageIterator[*deployments.Deployment](ctx, o.api, []string{"releases", releaseId, "deployments"}, nil)
+}
@@ -0,0 +1,27 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+)
+
+// CheckConnection requests the space directly from Octopus, bypassing the cache, to confirm Octopus is reachable,
+// the API key is valid, and the space exists.
+func (o *LiveOctopusClient) CheckConnection(ctx context.Context) (octopusErr error) {
+	defer func() {
+		octopusErr = o.classifyError(octopusErr)
+	}()
+
+	space := struct{ Id string }{}
+	found, err := o.api.get(ctx, []string{}, nil, &space)
+
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return apperrors.New(apperrors.CodeOctopusSpaceNotFound, "the space "+o.api.spaceId+" does not exist")
+	}
+
+	return nil
+}
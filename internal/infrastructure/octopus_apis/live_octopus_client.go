@@ -5,20 +5,25 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/OctopusDeploy/go-octopusdeploy/octopusdeploy"
 	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/channels"
 	octopusApiClient "github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/client"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/core"
 	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/deployments"
 	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/feeds"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/packages"
 	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/releases"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/tracing"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
 	"github.com/allegro/bigcache/v3"
-	"github.com/avast/retry-go"
 	"github.com/samber/lo"
+	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
+	"net/http"
 	"net/url"
 	"os"
 	"regexp"
@@ -28,7 +33,8 @@ import (
 	"time"
 )
 
-const MaxInt = 2147483647
+// DefaultCacheTtl is how long environments, channels, and lifecycles are cached for.
+const DefaultCacheTtl = 5 * time.Minute
 
 var ApplicationEnvironmentVariable = regexp.MustCompile("^Metadata.ArgoCD\\.Application\\[([^\\[\\]]*?)]\\.Environment$")
 var ApplicationChannelVariable = regexp.MustCompile("^Metadata.ArgoCD\\.Application\\[([^\\[\\]]*?)]\\.Channel$")
@@ -36,15 +42,60 @@ var ApplicationImageReleaseVersionVariable = regexp.MustCompile("^Metadata.ArgoC
 var ApplicationImagePackageVersionVariable = regexp.MustCompile("^Metadata.ArgoCD\\.Application\\[([^\\[\\]]*?)]\\.ImageForPackageVersion\\[([^\\[\\]]*?)]$")
 
 // LiveOctopusClient interacts with a live Octopus API endpoint, and implements caching to reduce network calls.
+// A single client library instance is shared by all requests, and the resources it returns are mapped to the models
+// used by the rest of the proxy.
 type LiveOctopusClient struct {
-	client       *octopusdeploy.Client
-	logger       apploggers.AppLogger
-	bigCache     *bigcache.BigCache
-	applications sync.Map
+	client    *octopusApiClient.Client
+	api       *octopusApi
+	transport *ThrottlingTransport
+	logger    apploggers.AppLogger
+	bigCache  *bigcache.BigCache
+	index     *mappingIndex
+	// environmentNames maps the IDs of cached environments to their names, as environments are cached by name
+	environmentNames sync.Map
 }
 
+// OctopusConnection is the Octopus server, API key, and space the client connects to.
+type OctopusConnection struct {
+	Server  string
+	ApiKey  string
+	SpaceId string
+}
+
+// GetOctopusConnection reads the connection from the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID
+// environment variables.
+func GetOctopusConnection() OctopusConnection {
+	return OctopusConnection{
+		Server:  os.Getenv("OCTOPUS_SERVER"),
+		ApiKey:  os.Getenv("OCTOPUS_API_KEY"),
+		SpaceId: os.Getenv("OCTOPUS_SPACE_ID"),
+	}
+}
+
+// NewLiveOctopusClient creates a client connected to the Octopus server defined in the environment variables.
 func NewLiveOctopusClient() (*LiveOctopusClient, error) {
-	client, err := getClient()
+	return NewLiveOctopusClientForConnection(GetOctopusConnection())
+}
+
+// NewLiveOctopusClientForConnection creates a client connected to the supplied Octopus server. The rate limit, cache,
+// and mapping index settings are read from environment variables.
+func NewLiveOctopusClientForConnection(connection OctopusConnection) (*LiveOctopusClient, error) {
+	// All requests to Octopus share the same rate limit and circuit breaker
+	transport, err := NewDefaultThrottlingTransport()
+
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Transport: transport}
+
+	client, err := getClient(httpClient, connection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := newOctopusApi(httpClient, connection)
 
 	if err != nil {
 		return nil, err
@@ -56,266 +107,387 @@ func NewLiveOctopusClient() (*LiveOctopusClient, error) {
 		return nil, err
 	}
 
-	bCache, err := bigcache.New(context.Background(), bigcache.DefaultConfig(5*time.Minute))
+	indexRefreshInterval, err := getDurationEnv("MAPPING_INDEX_REFRESH_INTERVAL", DefaultIndexRefreshInterval)
 
-	return &LiveOctopusClient{
-		client:       client,
-		logger:       logger,
-		bigCache:     bCache,
-		applications: sync.Map{},
-	}, nil
-}
+	if err != nil {
+		return nil, err
+	}
 
-func (o *LiveOctopusClient) IsDeployed(project *octopusdeploy.Project, releaseVersion types.OctopusReleaseVersion, environment *octopusdeploy.Environment) (bool, error) {
-	var octopusReleases []*octopusdeploy.Release
-	err := retry.Do(
-		func() error {
-			var err error
-			octopusReleases, err = o.client.Projects.GetReleases(project)
-			return err
-		}, retry_config.RetryOptions...)
+	indexFullRefreshInterval, err := getDurationEnv("MAPPING_INDEX_FULL_REFRESH_INTERVAL", DefaultIndexFullRefreshInterval)
 
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	projectReleases := lo.Filter(octopusReleases, func(item *octopusdeploy.Release, index int) bool {
-		return item.Version == fmt.Sprint(releaseVersion)
-	})
+	cacheTtl, err := getDurationEnv("OCTOPUS_CACHE_TTL", DefaultCacheTtl)
 
-	if len(projectReleases) == 0 {
-		return false, nil
+	if err != nil {
+		return nil, err
 	}
 
-	var octopusDeployments *octopusdeploy.Deployments
-	err = retry.Do(
-		func() error {
-			var err error
-			octopusDeployments, err = o.client.Deployments.GetDeployments(projectReleases[0], &octopusdeploy.DeploymentQuery{
-				Skip: 0,
-				Take: 10000,
-			})
-			return err
-		}, retry_config.RetryOptions...)
+	if cacheTtl <= 0 {
+		return nil, errors.New("octoargosync-init-octoclienterror - OCTOPUS_CACHE_TTL must be greater than zero")
+	}
+
+	bCache, err := bigcache.New(context.Background(), bigcache.DefaultConfig(cacheTtl))
 
 	if err != nil {
-		return false, err
+		return nil, errors.New("octoargosync-init-octoclienterror - failed to create the Octopus cache: " + err.Error())
 	}
 
-	if len(octopusDeployments.Items) == 0 {
-		return false, nil
+	octopusClient := &LiveOctopusClient{
+		client:    client,
+		api:       api,
+		transport: transport,
+		logger:    logger,
+		bigCache:  bCache,
 	}
 
-	environmentDeployments := lo.Filter(octopusDeployments.Items, func(item *octopusdeploy.Deployment, index int) bool {
-		return item.EnvironmentID == environment.ID
-	})
+	octopusClient.index = newMappingIndex(
+		logger,
+		octopusClient.getAllProjects,
+		octopusClient.getProjectVariables,
+		indexRefreshInterval,
+		indexFullRefreshInterval)
 
-	return len(environmentDeployments) != 0, nil
+	return octopusClient, nil
 }
 
-func (o *LiveOctopusClient) GetLatestDeploymentRelease(project *octopusdeploy.Project, environment *octopusdeploy.Environment) (*octopusdeploy.Release, error) {
-	var octopusReleases []*octopusdeploy.Release
-	err := retry.Do(
-		func() error {
-			var err error
-			octopusReleases, err = o.client.Projects.GetReleases(project)
-			return err
-		}, retry_config.RetryOptions...)
+func (o *LiveOctopusClient) IsDeployed(ctx context.Context, project *models.Project, releaseVersion types.OctopusReleaseVersion, environment *models.Environment) (_ bool, octopusErr error) {
+	defer func() { octopusErr = apperrors.WithContext(o.classifyError(octopusErr), "", getProjectName(project)) }()
+
+	release, err := o.getReleaseByVersion(ctx, project.ID, releaseVersion)
 
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	if len(octopusReleases) == 0 {
-		return nil, nil
+	if release == nil {
+		return false, nil
 	}
 
-	slices.SortFunc(octopusReleases, func(a, b *octopusdeploy.Release) bool {
-		return a.Assembled.After(b.Assembled)
-	})
+	// Stop paging through the deployments of the release as soon as a deployment to the environment is found
+	deployments := o.deploymentPages(ctx, release.ID)
+	for deployments.Next() {
+		if deployments.Item().EnvironmentID == environment.ID {
+			return true, nil
+		}
+	}
+
+	return false, deployments.Err()
+}
 
-	for _, release := range octopusReleases {
-		progression, err := o.client.Deployments.GetProgression(release)
+func (o *LiveOctopusClient) GetLatestDeploymentRelease(ctx context.Context, project *models.Project, environment *models.Environment) (_ *models.Release, octopusErr error) {
+	defer func() { octopusErr = apperrors.WithContext(o.classifyError(octopusErr), "", getProjectName(project)) }()
+
+	// Deployments are returned newest first, so the first deployment of the project to the environment is the latest,
+	// no matter how many releases have been created since
+	latestDeployments := Page[*deployments.Deployment]{}
+	err := retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+		_, err := o.api.get(
+			ctx,
+			[]string{"deployments"},
+			url.Values{"projects": {project.ID}, "environments": {environment.ID}, "take": {"1"}},
+			&latestDeployments)
+		return err
+	})
 
-		if err != nil {
-			return nil, err
-		}
+	if err != nil || len(latestDeployments.Items) == 0 {
+		return nil, err
+	}
 
-		_, exists := lo.Find(progression.Environments, func(item *octopusdeploy.ReferenceDataItem) bool {
-			return item.ID == environment.ID
-		})
+	release := &releases.Release{}
+	found := false
+	err = retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+		var err error
+		found, err = o.api.get(ctx, []string{"releases", latestDeployments.Items[0].ReleaseID}, nil, release)
+		return err
+	})
 
-		if exists {
-			return release, nil
-		}
+	if err != nil || !found {
+		return nil, err
 	}
 
-	return nil, nil
+	return toRelease(release), nil
 }
 
-func (o *LiveOctopusClient) GetLatestRelease(project *octopusdeploy.Project) (*octopusdeploy.Release, error) {
-	var octopusReleases []*octopusdeploy.Release
-	err := retry.Do(
-		func() error {
-			var err error
-			octopusReleases, err = o.client.Projects.GetReleases(project)
-			return err
-		}, retry_config.RetryOptions...)
+func (o *LiveOctopusClient) GetLatestRelease(ctx context.Context, project *models.Project) (_ *models.Release, octopusErr error) {
+	defer func() { octopusErr = apperrors.WithContext(o.classifyError(octopusErr), "", getProjectName(project)) }()
+
+	// The releases of a project are returned newest first, so only the first page is needed
+	octopusReleases := &Page[*releases.Release]{}
+	err := retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+		_, err := o.api.get(
+			ctx,
+			[]string{"projects", project.ID, "releases"},
+			url.Values{"skip": {"0"}, "take": {"1"}},
+			octopusReleases)
+		return err
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if len(octopusReleases) == 0 {
+	if len(octopusReleases.Items) == 0 {
 		return nil, nil
 	}
 
-	slices.SortFunc(octopusReleases, func(a, b *octopusdeploy.Release) bool {
-		return a.Assembled.After(b.Assembled)
-	})
-
-	return octopusReleases[0], nil
+	return toRelease(octopusReleases.Items[0]), nil
 }
 
-func (o *LiveOctopusClient) GetReleaseVersions(project *octopusdeploy.Project) ([]types.OctopusReleaseVersion, error) {
-	var octopusReleases []*octopusdeploy.Release
-	err := retry.Do(
-		func() error {
-			var err error
-			octopusReleases, err = o.client.Projects.GetReleases(project)
-			return err
-		}, retry_config.RetryOptions...)
+func (o *LiveOctopusClient) GetReleaseVersions(ctx context.Context, project *models.Project) (_ []types.OctopusReleaseVersion, octopusErr error) {
+	defer func() { octopusErr = apperrors.WithContext(o.classifyError(octopusErr), "", getProjectName(project)) }()
 
-	if err != nil {
-		return nil, err
+	// Only the versions are kept, so the releases are not all held in memory
+	projectReleases := []types.OctopusReleaseVersion{}
+	releases := o.releasePages(ctx, project.ID)
+	for releases.Next() {
+		projectReleases = append(projectReleases, types.OctopusReleaseVersion(releases.Item().Version))
 	}
 
-	projectReleases := lo.Map(octopusReleases, func(item *octopusdeploy.Release, index int) types.OctopusReleaseVersion {
-		return types.OctopusReleaseVersion(item.Version)
-	})
+	if err := releases.Err(); err != nil {
+		return nil, err
+	}
 
 	return projectReleases, nil
 }
 
-func (o *LiveOctopusClient) GetProjects(updateMessage models.ApplicationUpdateMessage) ([]models.ArgoCDProjectExpanded, error) {
-	allProjects, err := o.getAllProjectAndVariables(updateMessage)
+func (o *LiveOctopusClient) GetProjects(ctx context.Context, updateMessage models.ApplicationUpdateMessage) (_ []models.ArgoCDProjectExpanded, octopusErr error) {
+	ctx, span := tracing.Start(ctx, "LiveOctopusClient.GetProjects",
+		tracing.ApplicationKey.String(updateMessage.Application),
+		tracing.NamespaceKey.String(updateMessage.Namespace))
 
-	if err != nil {
-		return nil, err
-	}
+	defer func() {
+		octopusErr = apperrors.WithContext(o.classifyError(octopusErr), updateMessage.Namespace+"/"+updateMessage.Application, "")
+		tracing.End(span, octopusErr)
+	}()
 
-	projects, err := o.getProjectsMatchingArgoCDApplication(allProjects, updateMessage.Application, updateMessage.Namespace)
+	projects, err := o.index.lookup(ctx, updateMessage.Namespace, updateMessage.Application)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return o.expandProjectReferences(projects)
+	return o.expandProjectReferences(ctx, projects)
 }
 
-func (o *LiveOctopusClient) CreateAndDeployRelease(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) error {
+// CreateAndDeployRelease returns the release it created or reused even if the deployment failed, so callers can
+// report the changes made to Octopus.
+func (o *LiveOctopusClient) CreateAndDeployRelease(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) (_ models.ReleaseResult, octopusErr error) {
+	defer func() {
+		octopusErr = apperrors.WithContext(o.classifyError(octopusErr), updateMessage.Namespace+"/"+updateMessage.Application, getProjectName(project.Project))
+	}()
+
+	// The handler supplies a logger with the notification's fields, otherwise the project fields are added here
+	logger := apploggers.FromContext(ctx, o.logger.With(
+		apploggers.Project(project.Project.Name),
+		apploggers.Environment(project.Environment.Name),
+		apploggers.Version(string(version)))).GetLogger()
 
-	err := o.validateLifecycle(project.Lifecycle, project.Environment)
+	result := models.ReleaseResult{}
+
+	err := o.validateLifecycle(ctx, project.Lifecycle, project.Environment)
 
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	release, newRelease, err := o.getRelease(project, version, project.Channel, updateMessage)
+	release, newRelease, err := o.getRelease(ctx, project, version, project.Channel, updateMessage)
 
 	if err != nil {
-		return err
+		return result, err
+	}
+
+	result.ReleaseID = release.ID
+	result.ReleaseAction = models.ReleaseActionReuse
+	if newRelease {
+		result.ReleaseAction = models.ReleaseActionCreate
 	}
 
 	if newRelease && slices.Index(project.Lifecycle.Phases[0].AutomaticDeploymentTargets, project.Environment.ID) != -1 {
-		o.logger.GetLogger().Info("Created release " + release.ID + " with version " + fmt.Sprint(version) + " for project " + project.Project.Name)
-		o.logger.GetLogger().Info("The environment " + project.Environment.Name + " is an automatic deployment target in the first phase, so Octopus will automatically deploy the release")
-		return nil
+		logger.Info("Created release", zap.String("releaseId", release.ID))
+		logger.Info("The environment is an automatic deployment target in the first phase, so Octopus will automatically deploy the release")
+		result.DeploymentAction = models.DeploymentActionAutomatic
+		return result, nil
 	}
 
-	deployment := octopusdeploy.NewDeployment(project.Environment.ID, release.ID)
-	deployment, err = o.client.Deployments.Add(deployment)
+	// The SDK does not accept a context, so check that the context is still valid before modifying Octopus
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	deployment, err := o.addDeployment(ctx, project, release)
 
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	o.logger.GetLogger().Info("Created release " + release.ID + " with version " + fmt.Sprint(version) + " and deployment " + deployment.ID +
-		" in environment " + project.Environment.Name + " for project " + project.Project.Name)
+	result.DeploymentID = deployment.ID
+	result.DeploymentAction = models.DeploymentActionCreate
 
-	return nil
+	logger.Info("Deployed the release", zap.String("releaseId", release.ID), zap.String("releaseAction", result.ReleaseAction),
+		zap.String("deploymentId", deployment.ID))
+
+	return result, nil
 }
 
-// getClient2 returns a client for the version 2 octopus_apis go library
-func getClient2() (*octopusApiClient.Client, error) {
-	if os.Getenv("OCTOPUS_SERVER") == "" {
-		return nil, errors.New("octoargosync-init-octoclienterror - OCTOPUS_SERVER must be defined")
+func (o *LiveOctopusClient) PlanRelease(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) (_ models.ProjectReleasePlan, octopusErr error) {
+	defer func() {
+		octopusErr = apperrors.WithContext(o.classifyError(octopusErr), updateMessage.Namespace+"/"+updateMessage.Application, getProjectName(project.Project))
+	}()
+
+	plan := models.ProjectReleasePlan{
+		ProjectID:   project.Project.ID,
+		Project:     project.Project.Name,
+		Environment: project.Environment.Name,
+		Channel:     project.Channel.Name,
+		Lifecycle:   project.Lifecycle.Name,
+		Version:     fmt.Sprint(version),
 	}
 
-	if os.Getenv("OCTOPUS_API_KEY") == "" {
-		return nil, errors.New("octoargosync-init-octoclienterror - OCTOPUS_API_KEY must be defined")
+	err := o.validateLifecycle(ctx, project.Lifecycle, project.Environment)
+
+	if err != nil {
+		return plan, err
 	}
 
-	octopusUrl, err := url.Parse(os.Getenv("OCTOPUS_SERVER"))
+	existingRelease, selectedPackages, err := o.planRelease(ctx, project, version, project.Channel, updateMessage)
 
 	if err != nil {
-		return nil, fmt.Errorf("octoargosync-init-octoclienterror - failed to parse OCTOPUS_SERVER as a url: %w", err)
+		return plan, err
 	}
 
-	client, err := octopusApiClient.NewClient(nil, octopusUrl, os.Getenv("OCTOPUS_API_KEY"), os.Getenv("OCTOPUS_SPACE_ID"))
+	plan.Packages = lo.Map(selectedPackages, func(item *packages.SelectedPackage, index int) models.PackagePlan {
+		return models.PackagePlan{
+			ActionName:           item.ActionName,
+			PackageReferenceName: item.PackageReferenceName,
+			Version:              item.Version,
+		}
+	})
 
-	if err != nil {
-		return nil, fmt.Errorf("octoargosync-init-octoclienterror - failed to create the Octopus API client. Check that the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID environment variables are valid: %w", err)
+	plan.DeploymentAction = models.DeploymentActionCreate
+
+	if existingRelease != nil {
+		plan.ReleaseAction = models.ReleaseActionReuse
+		plan.ExistingReleaseID = existingRelease.ID
+	} else {
+		plan.ReleaseAction = models.ReleaseActionCreate
+
+		if slices.Index(project.Lifecycle.Phases[0].AutomaticDeploymentTargets, project.Environment.ID) != -1 {
+			plan.DeploymentAction = models.DeploymentActionAutomatic
+		}
 	}
 
-	return client, nil
+	return plan, nil
+}
+
+// classifyError gives errors returned by Octopus an error code, letting callers know if they should wait for Octopus
+// to become available, and what the error means. Errors raised while requests to Octopus are paused are transient,
+// while requests Octopus rejected will fail again if they are retried.
+func (o *LiveOctopusClient) classifyError(err error) error {
+	err = o.transport.WrapError(err)
+
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := apperrors.As(err); ok {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var circuitOpenError *CircuitOpenError
+	if errors.As(err, &circuitOpenError) {
+		return apperrors.Wrap(apperrors.CodeOctopusUnavailable, "requests to Octopus are paused", err)
+	}
+
+	if statusCode, found := getStatusCode(err); found {
+		if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+			return apperrors.Wrap(apperrors.CodeOctopusUnauthorized, "Octopus rejected the API key", err)
+		}
+
+		if isPermanentStatus(statusCode) {
+			return apperrors.Wrap(apperrors.CodeOctopusRequestRejected, "Octopus rejected the request", err)
+		}
+	}
+
+	return apperrors.Wrap(apperrors.CodeOctopusRequestFailed, "the request to Octopus failed", err)
 }
 
-// getClient returns a client for the version 1 octopus_apis go library
-func getClient() (*octopusdeploy.Client, error) {
-	if os.Getenv("OCTOPUS_SERVER") == "" {
-		return nil, errors.New("octoargosync-init-octoclienterror - OCTOPUS_SERVER must be defined")
+// getStatusCode returns the HTTP status code of a response Octopus returned with an error.
+func getStatusCode(err error) (int, bool) {
+	var responseError *OctopusResponseError
+	if errors.As(err, &responseError) {
+		return responseError.StatusCode, true
 	}
 
-	if os.Getenv("OCTOPUS_API_KEY") == "" {
-		return nil, errors.New("octoargosync-init-octoclienterror - OCTOPUS_API_KEY must be defined")
+	// The client library only records the status code of some errors
+	var apiError *core.APIError
+	if errors.As(err, &apiError) && apiError.StatusCode != 0 {
+		return apiError.StatusCode, true
 	}
 
-	octopusUrl, err := url.Parse(os.Getenv("OCTOPUS_SERVER"))
+	return 0, false
+}
+
+// getProjectName returns the name of the project to include in errors, which may be reported for a nil project.
+func getProjectName(project *models.Project) string {
+	if project == nil {
+		return ""
+	}
+
+	return project.Name
+}
+
+// getClient returns a client for the version 2 octopus_apis go library
+func getClient(httpClient *http.Client, connection OctopusConnection) (*octopusApiClient.Client, error) {
+	if connection.Server == "" {
+		return nil, apperrors.New(apperrors.CodeOctopusConfigMissing, "octoargosync-init-octoclienterror - OCTOPUS_SERVER must be defined")
+	}
+
+	if connection.ApiKey == "" {
+		return nil, apperrors.New(apperrors.CodeOctopusConfigMissing, "octoargosync-init-octoclienterror - OCTOPUS_API_KEY must be defined")
+	}
+
+	octopusUrl, err := url.Parse(connection.Server)
 
 	if err != nil {
-		return nil, fmt.Errorf("octoargosync-init-octoclienterror - failed to parse OCTOPUS_SERVER as a url: %w", err)
+		return nil, apperrors.Wrap(apperrors.CodeOctopusConfigMissing, "octoargosync-init-octoclienterror - failed to parse OCTOPUS_SERVER as a url", err)
 	}
 
-	client, err := octopusdeploy.NewClient(nil, octopusUrl, os.Getenv("OCTOPUS_API_KEY"), os.Getenv("OCTOPUS_SPACE_ID"))
+	client, err := octopusApiClient.NewClient(httpClient, octopusUrl, connection.ApiKey, connection.SpaceId)
 
 	if err != nil {
-		return nil, fmt.Errorf("octoargosync-init-octoclienterror - failed to create the Octopus API client. Check that the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID environment variables are valid: %w", err)
+		return nil, apperrors.Wrap(apperrors.CodeOctopusClientFailed, "octoargosync-init-octoclienterror - failed to create the Octopus API client. Check that the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID environment variables are valid", err)
 	}
 
 	return client, nil
 }
 
 // getArgoCdChannel returns the default channel if no channel was indicated on the project, otherwise the specific channel is returned.
-func (o *LiveOctopusClient) getArgoCdChannel(project models.ArgoCDProject) (*octopusdeploy.Channel, error) {
+func (o *LiveOctopusClient) getArgoCdChannel(ctx context.Context, project models.ArgoCDProject) (*models.Channel, error) {
 	if project.ChannelName != "" {
-		return o.getChannel(project.Project, project.ChannelName)
+		return o.getChannel(ctx, project.Project, project.ChannelName)
 	}
 
-	return o.getDefaultChannel(project.Project)
+	return o.getDefaultChannel(ctx, project.Project)
 }
 
 // validateLifecycle checks for some common misconfigurations and either throws an error or prints a warning
-func (o *LiveOctopusClient) validateLifecycle(lifecycle *octopusdeploy.Lifecycle, environment *octopusdeploy.Environment) error {
+func (o *LiveOctopusClient) validateLifecycle(ctx context.Context, lifecycle *models.Lifecycle, environment *models.Environment) error {
 	if lifecycle == nil {
 		return errors.New("lifecycle must not be nil")
 	}
 
 	if len(lifecycle.Phases) == 0 {
-		return errors.New("the lifecycle " + lifecycle.Name + " has no phases, so deployment will fail")
+		return apperrors.New(apperrors.CodeOctopusLifecycleNoPhases, "the lifecycle "+lifecycle.Name+" has no phases, so deployment will fail")
 	}
 
-	allEnvironments := lo.FlatMap(lifecycle.Phases, func(item octopusdeploy.Phase, index int) []string {
+	allEnvironments := lo.FlatMap(lifecycle.Phases, func(item models.Phase, index int) []string {
 		environments := []string{}
 		environments = append(environments, item.AutomaticDeploymentTargets...)
 		environments = append(environments, item.OptionalDeploymentTargets...)
@@ -323,50 +495,72 @@ func (o *LiveOctopusClient) validateLifecycle(lifecycle *octopusdeploy.Lifecycle
 	})
 
 	if slices.Index(allEnvironments, environment.ID) == -1 {
-		return errors.New("the lifecycle " + lifecycle.Name + " does not include the environment " + environment.ID)
+		return apperrors.New(apperrors.CodeOctopusLifecycleEnvironment, "the lifecycle "+lifecycle.Name+" does not include the environment "+environment.ID)
 	}
 
 	if slices.Index(lifecycle.Phases[0].AutomaticDeploymentTargets, environment.ID) == -1 &&
 		slices.Index(lifecycle.Phases[0].OptionalDeploymentTargets, environment.ID) == -1 {
-		o.logger.GetLogger().Warn("It is recommended that the lifecycle associated with the project includes all ArgoCD environments in the first phase +" +
+		apploggers.FromContext(ctx, o.logger).GetLogger().Warn("It is recommended that the lifecycle associated with the project includes all ArgoCD environments in the first phase +" +
 			"because ArgoCD does not enforce any environment progression rules and deployments can happen to any environment in any order.")
 	}
 
 	return nil
 }
 
-// getDefaultPackages gets the default package versions for the project
-func (o *LiveOctopusClient) getDefaultPackages(project models.ArgoCDProjectExpanded, channelId string) ([]*octopusdeploy.SelectedPackage, error) {
-	octopus, err := getClient2()
+// addDeployment deploys the release to the project's environment.
+func (o *LiveOctopusClient) addDeployment(ctx context.Context, project models.ArgoCDProjectExpanded, release *models.Release) (_ *deployments.Deployment, err error) {
+	ctx, span := tracing.Start(ctx, "Octopus Deployments.Add",
+		tracing.ProjectKey.String(project.Project.Name),
+		tracing.EnvironmentKey.String(project.Environment.Name),
+		tracing.ReleaseIdKey.String(release.ID))
+	defer func() { tracing.End(span, err) }()
+
+	deployment := deployments.NewDeployment(project.Environment.ID, release.ID)
+	err = retry_config.Do(ctx, retry_config.OctopusWrite, func() error {
+		var err error
+		deployment, err = o.client.Deployments.Add(deployment)
+		return err
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	deploymentProcess, err := octopus.DeploymentProcesses.GetByID(project.Project.DeploymentProcessID)
+	span.SetAttributes(tracing.DeploymentIdKey.String(deployment.ID))
+
+	return deployment, nil
+}
+
+// getDefaultPackages gets the default package versions for the project
+func (o *LiveOctopusClient) getDefaultPackages(ctx context.Context, project models.ArgoCDProjectExpanded, channelId string) (_ []*packages.SelectedPackage, err error) {
+	ctx, span := tracing.Start(ctx, "LiveOctopusClient.getDefaultPackages",
+		tracing.ProjectKey.String(project.Project.Name))
+	defer func() { tracing.End(span, err) }()
+
+	deploymentProcess, err := o.client.DeploymentProcesses.GetByID(project.Project.DeploymentProcessID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	channel, err := octopus.Channels.GetByID(channelId)
+	channel, err := o.client.Channels.GetByID(channelId)
 
 	if err != nil {
 		return nil, err
 	}
 
-	deploymentProcessTemplate, err := octopus.DeploymentProcesses.GetTemplate(deploymentProcess, channelId, "")
+	deploymentProcessTemplate, err := o.client.DeploymentProcesses.GetTemplate(deploymentProcess, channelId, "")
 
 	if err != nil {
 		return nil, err
 	}
 
-	return o.buildPackageVersionBaseline(octopus, deploymentProcessTemplate, channel)
+	return o.buildPackageVersionBaseline(ctx, deploymentProcessTemplate, channel)
 }
 
 // getPackages extracts packages and the images that the package versions are selected from
-func (o *LiveOctopusClient) getPackages(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) ([]*octopusdeploy.SelectedPackage, error) {
-	selectedPackages := []*octopusdeploy.SelectedPackage{}
+func (o *LiveOctopusClient) getPackages(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage) ([]*packages.SelectedPackage, error) {
+	selectedPackages := []*packages.SelectedPackage{}
 
 	for _, imagePackageVersion := range project.PackageVersions {
 
@@ -381,28 +575,28 @@ func (o *LiveOctopusClient) getPackages(project models.ArgoCDProjectExpanded, up
 		})
 
 		if len(imageVersion) == 0 {
-			o.logger.GetLogger().Error("octoargosync-init-argoimagenotfound: The ArgoCD deployment does not contain an image called " + imagePackageVersion.Image + " so the default package version will be used.")
+			apploggers.FromContext(ctx, o.logger).GetLogger().Error("octoargosync-init-argoimagenotfound: The ArgoCD deployment does not contain an image called " + imagePackageVersion.Image + " so the default package version will be used.")
 			continue
 		}
 
 		split := strings.Split(imagePackageVersion.PackageReference, ":")
 
 		if len(split) == 1 {
-			selectedPackages = append(selectedPackages, &octopusdeploy.SelectedPackage{
+			selectedPackages = append(selectedPackages, &packages.SelectedPackage{
 				ActionName:           split[0],
 				PackageReferenceName: "",
 				StepName:             "",
 				Version:              imageVersion[0],
 			})
 		} else if len(split) == 2 {
-			selectedPackages = append(selectedPackages, &octopusdeploy.SelectedPackage{
+			selectedPackages = append(selectedPackages, &packages.SelectedPackage{
 				ActionName:           split[0],
 				PackageReferenceName: split[1],
 				StepName:             "",
 				Version:              imageVersion[0],
 			})
 		} else {
-			o.logger.GetLogger().Error("octoargosync-init-octopackagereferenceerror: The step package reference " + imagePackageVersion.PackageReference + " was in an unexpected format. It must be a string separated by 0 or 1 colons e.g. stepname, stepname:packagename")
+			apploggers.FromContext(ctx, o.logger).GetLogger().Error("octoargosync-init-octopackagereferenceerror: The step package reference " + imagePackageVersion.PackageReference + " was in an unexpected format. It must be a string separated by 0 or 1 colons e.g. stepname, stepname:packagename")
 		}
 	}
 
@@ -410,75 +604,105 @@ func (o *LiveOctopusClient) getPackages(project models.ArgoCDProjectExpanded, up
 }
 
 // getRelease finds the release for a given version in a project, or it creates a new release.
-func (o *LiveOctopusClient) getRelease(project models.ArgoCDProjectExpanded, version types.OctopusReleaseVersion, channel *octopusdeploy.Channel, updateMessage models.ApplicationUpdateMessage) (*octopusdeploy.Release, bool, error) {
-	var octopusReleases *octopusdeploy.Releases
-	err := retry.Do(
-		func() error {
-			var err error
-			octopusReleases, err = o.client.Releases.Get(octopusdeploy.ReleasesQuery{
-				IDs:                nil,
-				IgnoreChannelRules: false,
-				Skip:               0,
-				Take:               10000,
-			})
-			return err
-		}, retry_config.RetryOptions...)
+func (o *LiveOctopusClient) getRelease(ctx context.Context, project models.ArgoCDProjectExpanded, version types.OctopusReleaseVersion, channel *models.Channel, updateMessage models.ApplicationUpdateMessage) (_ *models.Release, _ bool, err error) {
+	ctx, span := tracing.Start(ctx, "LiveOctopusClient.getRelease",
+		tracing.ProjectKey.String(project.Project.Name),
+		tracing.VersionKey.String(string(version)))
+	defer func() { tracing.End(span, err) }()
+
+	existingRelease, finalPackages, err := o.planRelease(ctx, project, version, channel, updateMessage)
 
 	if err != nil {
 		return nil, false, err
 	}
 
-	existingReleases := lo.Filter(octopusReleases.Items, func(item *octopusdeploy.Release, index int) bool {
-		return item.ProjectID == project.Project.ID && item.Version == fmt.Sprint(version)
-	})
+	if existingRelease == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+
+		release := releases.NewRelease(channel.ID, project.Project.ID, fmt.Sprint(version))
+		release.SelectedPackages = finalPackages
+
+		err = retry_config.Do(ctx, retry_config.OctopusWrite, func() error {
+			var err error
+			release, err = o.client.Releases.Add(release)
+			return err
+		})
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		span.SetAttributes(tracing.ReleaseIdKey.String(release.ID))
+		return toRelease(release), true, nil
+	} else {
+		span.SetAttributes(tracing.ReleaseIdKey.String(existingRelease.ID))
+		return existingRelease, false, nil
+	}
+}
+
+// planRelease finds any existing release for a given version in a project, and the packages that a new release
+// would select. Nothing is written to Octopus.
+func (o *LiveOctopusClient) planRelease(ctx context.Context, project models.ArgoCDProjectExpanded, version types.OctopusReleaseVersion, channel *models.Channel, updateMessage models.ApplicationUpdateMessage) (*models.Release, []*packages.SelectedPackage, error) {
+	existingRelease, err := o.getReleaseByVersion(ctx, project.Project.ID, version)
+
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Get the package versions that are mapped by the project metadata
-	packages, err := o.getPackages(project, updateMessage)
+	mappedPackages, err := o.getPackages(ctx, project, updateMessage)
 
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
 
 	// Get the latest package versions
-	defaultPackages, err := o.getDefaultPackages(project, channel.ID)
+	defaultPackages, err := o.getDefaultPackages(ctx, project, channel.ID)
 
 	if err != nil {
-		return nil, false, err
+		return nil, nil, err
 	}
 
 	// override any default packages with those versions that are specifically configured
-	finalPackages := o.overridePackageSelections(defaultPackages, packages)
-
-	if len(existingReleases) == 0 {
-		release := &octopusdeploy.Release{
-			ChannelID:        channel.ID,
-			ProjectID:        project.Project.ID,
-			Version:          fmt.Sprint(version),
-			SelectedPackages: finalPackages,
-		}
+	finalPackages := o.overridePackageSelections(defaultPackages, mappedPackages)
 
-		release, err := o.client.Releases.Add(release)
-		return release, true, err
-	} else {
-		return existingReleases[0], false, nil
+	return existingRelease, finalPackages, nil
+}
+
+// getReleaseByVersion returns the release in a project with the supplied version, or nil if there is no such release.
+func (o *LiveOctopusClient) getReleaseByVersion(ctx context.Context, projectId string, version types.OctopusReleaseVersion) (*models.Release, error) {
+	release := &releases.Release{}
+	found := false
+	err := retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+		var err error
+		found, err = o.api.get(ctx, []string{"projects", projectId, "releases", fmt.Sprint(version)}, nil, release)
+		return err
+	})
+
+	if err != nil || !found {
+		return nil, err
 	}
+
+	return toRelease(release), nil
 }
 
 // overridePackageSelections returns package selections with overrides applied to them
-func (o *LiveOctopusClient) overridePackageSelections(defaultPackages []*octopusdeploy.SelectedPackage, packages []*octopusdeploy.SelectedPackage) []*octopusdeploy.SelectedPackage {
+func (o *LiveOctopusClient) overridePackageSelections(defaultPackages []*packages.SelectedPackage, overrides []*packages.SelectedPackage) []*packages.SelectedPackage {
 	if defaultPackages == nil {
-		defaultPackages = []*octopusdeploy.SelectedPackage{}
+		defaultPackages = []*packages.SelectedPackage{}
 	}
 
-	if packages == nil {
-		packages = []*octopusdeploy.SelectedPackage{}
+	if overrides == nil {
+		overrides = []*packages.SelectedPackage{}
 	}
 
-	return lo.Map(defaultPackages, func(item *octopusdeploy.SelectedPackage, index int) *octopusdeploy.SelectedPackage {
-		override, found := lo.Find(packages, func(overridePackage *octopusdeploy.SelectedPackage) bool {
+	return lo.Map(defaultPackages, func(item *packages.SelectedPackage, index int) *packages.SelectedPackage {
+		override, found := lo.Find(overrides, func(overridePackage *packages.SelectedPackage) bool {
 			return overridePackage.ActionName == item.ActionName &&
 				overridePackage.StepName == item.StepName &&
-				overridePackage.PackageReferenceName == item.StepName
+				overridePackage.PackageReferenceName == item.PackageReferenceName
 		})
 
 		if found {
@@ -490,22 +714,22 @@ func (o *LiveOctopusClient) overridePackageSelections(defaultPackages []*octopus
 }
 
 // expandProjectReferences maps a project to the octopus_apis resources noted in the metadata variables
-func (o *LiveOctopusClient) expandProjectReferences(projects []models.ArgoCDProject) ([]models.ArgoCDProjectExpanded, error) {
+func (o *LiveOctopusClient) expandProjectReferences(ctx context.Context, projects []models.ArgoCDProject) ([]models.ArgoCDProjectExpanded, error) {
 	expandedProjects := []models.ArgoCDProjectExpanded{}
 	for _, project := range projects {
-		environment, err := o.getEnvironment(project.EnvironmentName)
+		environment, err := o.getEnvironment(ctx, project.EnvironmentName)
 
 		if err != nil {
 			return nil, err
 		}
 
-		channel, err := o.getArgoCdChannel(project)
+		channel, err := o.getArgoCdChannel(ctx, project)
 
 		if err != nil {
 			return nil, err
 		}
 
-		lifecycle, err := o.getLifecycle(channel.LifecycleID)
+		lifecycle, err := o.getChannelLifecycle(ctx, project.Project, channel)
 
 		if err != nil {
 			return nil, err
@@ -526,9 +750,13 @@ func (o *LiveOctopusClient) expandProjectReferences(projects []models.ArgoCDProj
 
 // getProjectsMatchingArgoCDApplication scans Octopus for the project that has been linked to the Argo CD Application and namespace
 func (o *LiveOctopusClient) getProjectsMatchingArgoCDApplication(allProjects []models.OctopusProjectAndVars, application string, namespace string) ([]models.ArgoCDProject, error) {
+	return matchArgoCDApplication(allProjects, application, namespace), nil
+}
 
+// matchArgoCDApplication maps the projects whose metadata variables reference the Argo CD Application and namespace
+func matchArgoCDApplication(allProjects []models.OctopusProjectAndVars, application string, namespace string) []models.ArgoCDProject {
 	matchingProjects := lo.FilterMap(allProjects, func(project models.OctopusProjectAndVars, index int) (models.ArgoCDProject, bool) {
-		appNameEnvironments := lo.FilterMap(project.Variables.Variables, func(variable *octopusdeploy.Variable, index int) (string, bool) {
+		appNameEnvironments := lo.FilterMap(project.Variables.Variables, func(variable models.Variable, index int) (string, bool) {
 			match := ApplicationEnvironmentVariable.FindStringSubmatch(variable.Name)
 
 			if len(match) != 2 || match[1] != namespace+"/"+application {
@@ -538,7 +766,7 @@ func (o *LiveOctopusClient) getProjectsMatchingArgoCDApplication(allProjects []m
 			return variable.Value, len(strings.TrimSpace(variable.Value)) != 0
 		})
 
-		appNameChannel := lo.FilterMap(project.Variables.Variables, func(variable *octopusdeploy.Variable, index int) (string, bool) {
+		appNameChannel := lo.FilterMap(project.Variables.Variables, func(variable models.Variable, index int) (string, bool) {
 			match := ApplicationChannelVariable.FindStringSubmatch(variable.Name)
 
 			if len(match) != 2 || match[1] != namespace+"/"+application {
@@ -553,7 +781,7 @@ func (o *LiveOctopusClient) getProjectsMatchingArgoCDApplication(allProjects []m
 			channel = appNameChannel[0]
 		}
 
-		releaseVersionImages := lo.FilterMap(project.Variables.Variables, func(variable *octopusdeploy.Variable, index int) (string, bool) {
+		releaseVersionImages := lo.FilterMap(project.Variables.Variables, func(variable models.Variable, index int) (string, bool) {
 			match := ApplicationImageReleaseVersionVariable.FindStringSubmatch(variable.Name)
 
 			if len(match) != 2 || match[1] != namespace+"/"+application {
@@ -563,7 +791,7 @@ func (o *LiveOctopusClient) getProjectsMatchingArgoCDApplication(allProjects []m
 			return variable.Value, len(strings.TrimSpace(variable.Value)) != 0
 		})
 
-		packageVersionImages := lo.FilterMap(project.Variables.Variables, func(variable *octopusdeploy.Variable, index int) (models.ImagePackageVersion, bool) {
+		packageVersionImages := lo.FilterMap(project.Variables.Variables, func(variable models.Variable, index int) (models.ImagePackageVersion, bool) {
 			match := ApplicationImagePackageVersionVariable.FindStringSubmatch(variable.Name)
 
 			if len(match) != 3 || match[1] != namespace+"/"+application {
@@ -594,112 +822,51 @@ func (o *LiveOctopusClient) getProjectsMatchingArgoCDApplication(allProjects []m
 		return models.ArgoCDProject{}, false
 	})
 
-	return matchingProjects, nil
+	return matchingProjects
 }
 
-func (o *LiveOctopusClient) getProjectVariables(projectId string) (*octopusdeploy.VariableSet, error) {
-	// Load variables, and cache the results
-	variables := &octopusdeploy.VariableSet{}
-	variablesData, err := o.bigCache.Get(projectId + "-Variables")
-	if err == nil {
-		err = json.Unmarshal(variablesData, variables)
-
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		err = retry.Do(
-			func() error {
-				freshVariables, err := o.client.Variables.GetAll(projectId)
-				variables = &freshVariables
-				return err
-			}, retry_config.RetryOptions...)
-
-		if err != nil {
-			return nil, err
-		}
+// getProjectVariables loads the variables of a project. The variables are held by the mapping index rather than the
+// cache.
+func (o *LiveOctopusClient) getProjectVariables(ctx context.Context, projectId string) (_ *models.VariableSet, err error) {
+	ctx, span := tracing.Start(ctx, "LiveOctopusClient.getProjectVariables", tracing.ProjectIdKey.String(projectId))
+	defer func() { tracing.End(span, err) }()
 
-		variablesData, err = json.Marshal(variables)
+	var variableSet *models.VariableSet
+	err = retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+		octopusVariables, err := o.client.Variables.GetAll(projectId)
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		err = o.bigCache.Set(projectId+"-Variables", variablesData)
+		variableSet = toVariableSet(octopusVariables)
+		return nil
+	})
 
-		if err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	return variables, nil
+	return variableSet, nil
 }
 
-func (o *LiveOctopusClient) getAllProjectAndVariables(updateMessage models.ApplicationUpdateMessage) ([]models.OctopusProjectAndVars, error) {
-	// See if we have encountered this application before
-	_, exists := o.applications.Load(updateMessage.Namespace + "/" + updateMessage.Application)
-
-	// Load projects, and cache the results
-	octopusProjects := &octopusdeploy.Projects{}
-	projectsData, err := o.bigCache.Get("AllProjects")
-
-	// If this is a new application (i.e. we have never seen it before), skip the cache.
-	// This lets us get a refreshed project list for new applications, which will likely
-	// happen when a new ArgoCD project is created in Octopus and a new Application is created
-	// in ArgoCD with the correct triggers configured.
-	if err == nil && exists {
-		err = json.Unmarshal(projectsData, octopusProjects)
-
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		// note the new application
-		o.applications.Store(updateMessage.Namespace+"/"+updateMessage.Application, true)
-
-		err = retry.Do(
-			func() error {
-				var err error
-				octopusProjects, err = o.client.Projects.Get(octopusdeploy.ProjectsQuery{Take: MaxInt})
-				return err
-			}, retry_config.RetryOptions...)
-
-		if err != nil {
-			return nil, err
-		}
-
-		projectsData, err = json.Marshal(octopusProjects)
-
-		if err != nil {
-			return nil, err
-		}
-
-		err = o.bigCache.Set("AllProjects", projectsData)
-
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	projectAndVars := []models.OctopusProjectAndVars{}
-	for _, project := range octopusProjects.Items {
-		variables, err := o.getProjectVariables(project.ID)
-
-		if err != nil {
-			return nil, err
-		}
+// getAllProjects loads all the projects. The projects are held by the mapping index rather than the cache.
+func (o *LiveOctopusClient) getAllProjects(ctx context.Context) ([]*models.Project, error) {
+	return collectPages(o.projectPages(ctx))
+}
 
-		projectAndVars = append(projectAndVars, models.OctopusProjectAndVars{
-			Project:   project,
-			Variables: variables,
-		})
+// getChannelLifecycle returns the lifecycle of a channel. Channels that do not define a lifecycle use the project's
+// lifecycle.
+func (o *LiveOctopusClient) getChannelLifecycle(ctx context.Context, project *models.Project, channel *models.Channel) (*models.Lifecycle, error) {
+	if channel.LifecycleID == "" {
+		return o.getLifecycle(ctx, project.LifecycleID)
 	}
 
-	return projectAndVars, nil
+	return o.getLifecycle(ctx, channel.LifecycleID)
 }
 
-func (o *LiveOctopusClient) getLifecycle(lifecycleId string) (*octopusdeploy.Lifecycle, error) {
-	lifecycle := &octopusdeploy.Lifecycle{}
+func (o *LiveOctopusClient) getLifecycle(ctx context.Context, lifecycleId string) (*models.Lifecycle, error) {
+	lifecycle := &models.Lifecycle{}
 	lifecycleData, err := o.bigCache.Get(lifecycleId)
 
 	if err == nil {
@@ -711,30 +878,34 @@ func (o *LiveOctopusClient) getLifecycle(lifecycleId string) (*octopusdeploy.Lif
 
 		return lifecycle, nil
 	} else {
-		lifecycleQuery := octopusdeploy.LifecyclesQuery{
-			IDs:         []string{lifecycleId},
-			PartialName: "",
-			Skip:        0,
-			Take:        1,
+		lifecycleQuery := lifecycles.Query{
+			IDs:  []string{lifecycleId},
+			Skip: 0,
+			Take: 1,
 		}
 
-		var octopusLifecycles *octopusdeploy.Lifecycles
-		err = retry.Do(
-			func() error {
-				var err error
-				octopusLifecycles, err = o.client.Lifecycles.Get(lifecycleQuery)
+		var octopusLifecycles []*lifecycles.Lifecycle
+		err = retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+			result, err := o.client.Lifecycles.Get(lifecycleQuery)
+
+			if err != nil {
 				return err
-			}, retry_config.RetryOptions...)
+			}
+
+			octopusLifecycles = result.Items
+			return nil
+		})
 
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 
-		if len(octopusLifecycles.Items) != 1 {
-			return nil, errors.New("failed to find lifecycle with ID " + lifecycleId)
+		if len(octopusLifecycles) != 1 {
+			return nil, apperrors.New(apperrors.CodeOctopusLifecycleNotFound, "failed to find lifecycle with ID "+lifecycleId)
 		}
 
-		lifecyclesData, err := json.Marshal(octopusLifecycles.Items[0])
+		lifecycle = toLifecycle(octopusLifecycles[0])
+		lifecyclesData, err := json.Marshal(lifecycle)
 
 		if err != nil {
 			return nil, err
@@ -746,33 +917,23 @@ func (o *LiveOctopusClient) getLifecycle(lifecycleId string) (*octopusdeploy.Lif
 			return nil, err
 		}
 
-		return octopusLifecycles.Items[0], nil
+		return lifecycle, nil
 	}
 }
 
-func (o *LiveOctopusClient) getChannel(project *octopusdeploy.Project, channel string) (*octopusdeploy.Channel, error) {
+func (o *LiveOctopusClient) getChannel(ctx context.Context, project *models.Project, channel string) (*models.Channel, error) {
 	// Load variables, and cache the results
-	octopusChannels := &octopusdeploy.Channels{}
+	octopusChannels := []*models.Channel{}
 	channelData, err := o.bigCache.Get("AllChannels")
 
 	if err == nil {
-		err = json.Unmarshal(channelData, octopusChannels)
+		err = json.Unmarshal(channelData, &octopusChannels)
 
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		channelQuery := octopusdeploy.ChannelsQuery{
-			Take: MaxInt,
-			Skip: 0,
-		}
-
-		err = retry.Do(
-			func() error {
-				var err error
-				octopusChannels, err = o.client.Channels.Get(channelQuery)
-				return err
-			}, retry_config.RetryOptions...)
+		octopusChannels, err = collectPages(o.channelPages(ctx))
 
 		if err != nil {
 			return nil, err
@@ -787,24 +948,24 @@ func (o *LiveOctopusClient) getChannel(project *octopusdeploy.Project, channel s
 		err = o.bigCache.Set("AllChannels", channelsData)
 	}
 
-	channelResource := lo.Filter(octopusChannels.Items, func(item *octopusdeploy.Channel, index int) bool {
+	channelResource := lo.Filter(octopusChannels, func(item *models.Channel, index int) bool {
 		return item.Name == channel && item.ProjectID == project.ID
 	})
 
 	if len(channelResource) != 1 {
-		return nil, errors.New("could not find the channel called " + channel + " for the project " + project.Name)
+		return nil, apperrors.New(apperrors.CodeOctopusChannelNotFound, "could not find the channel called "+channel+" for the project "+project.Name)
 	}
 
 	return channelResource[0], nil
 }
 
-func (o *LiveOctopusClient) getDefaultChannel(project *octopusdeploy.Project) (*octopusdeploy.Channel, error) {
+func (o *LiveOctopusClient) getDefaultChannel(ctx context.Context, project *models.Project) (*models.Channel, error) {
 	if project == nil {
 		return nil, errors.New("project must not be nil")
 	}
 
 	// Load variables, and cache the results
-	channel := &octopusdeploy.Channel{}
+	channel := &models.Channel{}
 	channelData, err := o.bigCache.Get(project.ID + "-DefaultChannel")
 	if err == nil {
 		err = json.Unmarshal(channelData, channel)
@@ -815,32 +976,24 @@ func (o *LiveOctopusClient) getDefaultChannel(project *octopusdeploy.Project) (*
 
 		return channel, nil
 	} else {
-		channelQuery := octopusdeploy.ChannelsQuery{
-			Take: MaxInt,
-			Skip: 0,
+		// Stop paging through the channels once the default channel is found
+		var defaultChannel *models.Channel
+		channels := o.channelPages(ctx)
+		for defaultChannel == nil && channels.Next() {
+			if channels.Item().IsDefault && channels.Item().ProjectID == project.ID {
+				defaultChannel = channels.Item()
+			}
 		}
 
-		var octopusChannels *octopusdeploy.Channels
-		err = retry.Do(
-			func() error {
-				var err error
-				octopusChannels, err = o.client.Channels.Get(channelQuery)
-				return err
-			}, retry_config.RetryOptions...)
-
-		if err != nil {
+		if err := channels.Err(); err != nil {
 			return nil, err
 		}
 
-		defaultChannel := lo.Filter(octopusChannels.Items, func(item *octopusdeploy.Channel, index int) bool {
-			return item.IsDefault && item.ProjectID == project.ID
-		})
-
-		if len(defaultChannel) != 1 {
-			return nil, errors.New("could not find the default channel")
+		if defaultChannel == nil {
+			return nil, apperrors.New(apperrors.CodeOctopusDefaultChannelNotFound, "could not find the default channel")
 		}
 
-		channelData, err = json.Marshal(defaultChannel[0])
+		channelData, err = json.Marshal(defaultChannel)
 
 		if err != nil {
 			return nil, err
@@ -852,13 +1005,13 @@ func (o *LiveOctopusClient) getDefaultChannel(project *octopusdeploy.Project) (*
 			return nil, err
 		}
 
-		return defaultChannel[0], nil
+		return defaultChannel, nil
 	}
 }
 
-func (o *LiveOctopusClient) getEnvironment(environmentName string) (*octopusdeploy.Environment, error) {
+func (o *LiveOctopusClient) getEnvironment(ctx context.Context, environmentName string) (*models.Environment, error) {
 	// Load environments, and cache the results
-	environment := &octopusdeploy.Environment{}
+	environment := &models.Environment{}
 	environmentData, err := o.bigCache.Get("Environments-" + environmentName)
 	if err == nil {
 		err = json.Unmarshal(environmentData, environment)
@@ -869,28 +1022,18 @@ func (o *LiveOctopusClient) getEnvironment(environmentName string) (*octopusdepl
 
 		return environment, nil
 	} else {
-		environmentsQuery := octopusdeploy.EnvironmentsQuery{
-			Name: environmentName,
-		}
-
-		var octopusEnvironments *octopusdeploy.Environments
-		err = retry.Do(
-			func() error {
-				var err error
-				octopusEnvironments, err = o.client.Environments.Get(environmentsQuery)
-				return err
-			}, retry_config.RetryOptions...)
+		octopusEnvironments, err := collectPages(o.environmentPages(ctx, environmentName))
 
 		if err != nil {
 			return nil, err
 		}
 
-		filteredEnvironments := lo.Filter(octopusEnvironments.Items, func(e *octopusdeploy.Environment, index int) bool {
+		filteredEnvironments := lo.Filter(octopusEnvironments, func(e *models.Environment, index int) bool {
 			return e.Name == environmentName
 		})
 
 		if len(filteredEnvironments) != 1 {
-			return nil, errors.New("failed to find an environment called " + environmentName)
+			return nil, apperrors.New(apperrors.CodeOctopusEnvironmentNotFound, "failed to find an environment called "+environmentName)
 		}
 
 		environmentData, err = json.Marshal(filteredEnvironments[0])
@@ -905,16 +1048,14 @@ func (o *LiveOctopusClient) getEnvironment(environmentName string) (*octopusdepl
 			return nil, err
 		}
 
+		o.environmentNames.Store(filteredEnvironments[0].ID, environmentName)
+
 		return filteredEnvironments[0], nil
 	}
 }
 
 // buildPackageVersionBaseline has been shamelessly lifted from https://github.com/OctopusDeploy/cli
-func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClient.Client, deploymentProcessTemplate *deployments.DeploymentProcessTemplate, channel *channels.Channel) ([]*octopusdeploy.SelectedPackage, error) {
-	if octopus == nil {
-		return nil, errors.New("octopus_apis can not be nil")
-	}
-
+func (o *LiveOctopusClient) buildPackageVersionBaseline(ctx context.Context, deploymentProcessTemplate *deployments.DeploymentProcessTemplate, channel *channels.Channel) ([]*packages.SelectedPackage, error) {
 	if deploymentProcessTemplate == nil {
 		return nil, errors.New("deploymentProcessTemplate can not be nil")
 	}
@@ -923,7 +1064,7 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 		return nil, errors.New("channel can not be nil")
 	}
 
-	result := make([]*octopusdeploy.SelectedPackage, 0, len(deploymentProcessTemplate.Packages))
+	result := make([]*packages.SelectedPackage, 0, len(deploymentProcessTemplate.Packages))
 
 	// step 1: pass over all the packages in the deployment process, group them
 	// by their feed, then subgroup by packageId
@@ -936,7 +1077,7 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 		// any potential versions for it; we can't succeed in that because variable templates won't get expanded
 		// until deployment time
 		if !pkg.IsResolvable {
-			result = append(result, &octopusdeploy.SelectedPackage{
+			result = append(result, &packages.SelectedPackage{
 				ActionName:           pkg.ActionName,
 				PackageReferenceName: pkg.PackageReferenceName,
 				Version:              "",
@@ -952,7 +1093,7 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 	}
 
 	if len(feedsToQuery) == 0 {
-		return make([]*octopusdeploy.SelectedPackage, 0), nil
+		return make([]*packages.SelectedPackage, 0), nil
 	}
 
 	// step 2: load the feed resources, so we can get SearchPackageVersionsTemplate
@@ -962,12 +1103,11 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 	}
 	sort.Strings(feedIds) // we need to sort them otherwise the order is indeterminate. Server doesn't care but our unit tests fail
 	var foundFeeds *feeds.Feeds
-	err := retry.Do(
-		func() error {
-			var err error
-			foundFeeds, err = octopus.Feeds.Get(feeds.FeedsQuery{IDs: feedIds, Take: len(feedIds)})
-			return err
-		}, retry_config.RetryOptions...)
+	err := retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+		var err error
+		foundFeeds, err = o.client.Feeds.Get(feeds.FeedsQuery{IDs: feedIds, Take: len(feedIds)})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1002,13 +1142,17 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 			}
 
 			if cachedVersion, ok := cache[query]; ok {
-				result = append(result, &octopusdeploy.SelectedPackage{
+				result = append(result, &packages.SelectedPackage{
 					ActionName:           packageRef.ActionName,
 					PackageReferenceName: packageRef.PackageReferenceName,
 					Version:              cachedVersion,
 				})
 			} else { // uncached; ask the server
-				versions, err := octopus.Feeds.SearchFeedPackageVersions(feed, query)
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				versions, err := o.client.Feeds.SearchFeedPackageVersions(feed, query)
 				if err != nil {
 					return nil, err
 				}
@@ -1016,7 +1160,7 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 				switch len(versions.Items) {
 				case 0: // no package found; cache the response
 					cache[query] = ""
-					result = append(result, &octopusdeploy.SelectedPackage{
+					result = append(result, &packages.SelectedPackage{
 						ActionName:           packageRef.ActionName,
 						PackageReferenceName: packageRef.PackageReferenceName,
 						Version:              "",
@@ -1024,7 +1168,7 @@ func (o *LiveOctopusClient) buildPackageVersionBaseline(octopus *octopusApiClien
 
 				case 1:
 					cache[query] = versions.Items[0].Version
-					result = append(result, &octopusdeploy.SelectedPackage{
+					result = append(result, &packages.SelectedPackage{
 						ActionName:           packageRef.ActionName,
 						PackageReferenceName: packageRef.PackageReferenceName,
 						Version:              versions.Items[0].Version,
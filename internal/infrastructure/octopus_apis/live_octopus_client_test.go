@@ -0,0 +1,414 @@
+package octopus_apis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/core"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/fakes"
+	"golang.org/x/time/rate"
+	"testing"
+	"time"
+)
+
+// fakeOctopus is a fake Octopus server with a project mapped to the argocd/myapp application. The project deploys
+// the nginx image, and uses a lifecycle that includes the Development and Production environments.
+type fakeOctopus struct {
+	*fakes.FakeOctopusServer
+	development string
+	production  string
+	lifecycle   string
+	project     string
+	feed        string
+}
+
+func createFakeOctopus(t *testing.T) *fakeOctopus {
+	fake := &fakeOctopus{FakeOctopusServer: fakes.NewFakeOctopusServer()}
+	t.Cleanup(fake.Close)
+
+	fake.development = fake.AddEnvironment("Development")
+	fake.production = fake.AddEnvironment("Production")
+	fake.lifecycle = fake.AddLifecycle("Default", &lifecycles.Phase{
+		Name:                      "All",
+		OptionalDeploymentTargets: []string{fake.development, fake.production},
+	})
+	fake.project = fake.AddProject("Project 1", fake.lifecycle)
+	fake.feed = fake.AddFeed("Docker Hub")
+	fake.AddPackageVersions(fake.feed, "nginx", "1.25.0", "1.24.0")
+	fake.AddPackageVersions(fake.feed, "busybox", "1.36.0")
+	fake.AddDeploymentStep(fake.project, "Deploy", fake.feed, "nginx", "web")
+	fake.AddDeploymentStep(fake.project, "Migrate", fake.feed, "busybox", "")
+	fake.AddVariable(fake.project, "Metadata.ArgoCD.Application[argocd/myapp].Environment", "Development")
+	fake.AddVariable(fake.project, "Metadata.ArgoCD.Application[argocd/myapp].ImageForPackageVersion[Deploy:web]", "nginx")
+
+	return fake
+}
+
+// createFakeOctopusClient creates a live client that sends its requests to the fake Octopus server.
+func createFakeOctopusClient(t *testing.T, fake *fakeOctopus) *LiveOctopusClient {
+	t.Setenv("OCTOPUS_SERVER", fake.URL())
+	t.Setenv("OCTOPUS_API_KEY", fakes.FakeOctopusApiKey)
+	t.Setenv("OCTOPUS_SPACE_ID", fakes.FakeOctopusSpaceId)
+
+	client, err := NewLiveOctopusClient()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func getFakeProject(t *testing.T, client *LiveOctopusClient, updateMessage models.ApplicationUpdateMessage) models.ArgoCDProjectExpanded {
+	projects, err := client.GetProjects(context.Background(), updateMessage)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(projects) != 1 {
+		t.Fatalf("Expected 1 project, got %d", len(projects))
+	}
+
+	return projects[0]
+}
+
+func TestLiveClientGetProjects(t *testing.T) {
+	fake := createFakeOctopus(t)
+	client := createFakeOctopusClient(t, fake)
+
+	project := getFakeProject(t, client, models.ApplicationUpdateMessage{Namespace: "argocd", Application: "myapp"})
+
+	// The default channel has no lifecycle, so the project's lifecycle is used
+	if project.Project.ID != fake.project || project.Environment.ID != fake.development ||
+		project.Channel.Name != "Default" || project.Lifecycle.ID != fake.lifecycle {
+		t.Fatalf("Unexpected project %+v", project)
+	}
+
+	if len(project.PackageVersions) != 1 || project.PackageVersions[0].PackageReference != "Deploy:web" {
+		t.Fatalf("Unexpected package versions %+v", project.PackageVersions)
+	}
+
+	projects, err := client.GetProjects(context.Background(), models.ApplicationUpdateMessage{Namespace: "argocd", Application: "other"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(projects) != 0 {
+		t.Fatalf("Expected no projects for an unmapped application, got %+v", projects)
+	}
+}
+
+func TestLiveClientCreateAndDeployRelease(t *testing.T) {
+	fake := createFakeOctopus(t)
+	client := createFakeOctopusClient(t, fake)
+
+	updateMessage := models.ApplicationUpdateMessage{Namespace: "argocd", Application: "myapp", Images: []string{"nginx:1.26.0"}}
+	project := getFakeProject(t, client, updateMessage)
+
+	result, err := client.CreateAndDeployRelease(context.Background(), project, updateMessage, "1.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	releases := fake.Releases()
+
+	if len(releases) != 1 || releases[0].Version != "1.0.0" || len(releases[0].SelectedPackages) != 2 {
+		t.Fatalf("Expected release 1.0.0 with 2 packages, got %+v", releases)
+	}
+
+	if result.ReleaseID != releases[0].ID || result.ReleaseAction != models.ReleaseActionCreate ||
+		result.DeploymentID == "" || result.DeploymentAction != models.DeploymentActionCreate {
+		t.Fatalf("Expected the result to describe the new release and deployment, got %+v", result)
+	}
+
+	// The mapped image version overrides the latest package version, while unmapped packages use the latest version
+	versions := map[string]string{}
+	for _, selectedPackage := range releases[0].SelectedPackages {
+		versions[selectedPackage.ActionName+":"+selectedPackage.PackageReferenceName] = selectedPackage.Version
+	}
+
+	if versions["Deploy:web"] != "1.26.0" || versions["Migrate:"] != "1.36.0" {
+		t.Fatalf("Unexpected package versions %+v", versions)
+	}
+
+	deployments := fake.Deployments()
+
+	if len(deployments) != 1 || deployments[0].ReleaseID != releases[0].ID || deployments[0].EnvironmentID != fake.development {
+		t.Fatalf("Expected a deployment of the release to Development, got %+v", deployments)
+	}
+
+	deployed, err := client.IsDeployed(context.Background(), project.Project, "1.0.0", project.Environment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !deployed {
+		t.Fatal("Expected the release to be deployed")
+	}
+}
+
+func TestLiveClientReusesRelease(t *testing.T) {
+	fake := createFakeOctopus(t)
+	client := createFakeOctopusClient(t, fake)
+
+	updateMessage := models.ApplicationUpdateMessage{Namespace: "argocd", Application: "myapp", Images: []string{"nginx:1.26.0"}}
+	project := getFakeProject(t, client, updateMessage)
+
+	releaseId := fake.AddRelease(fake.project, project.Channel.ID, "1.0.0")
+	fake.AddDeployment(releaseId, fake.production)
+
+	plan, err := client.PlanRelease(context.Background(), project, updateMessage, "1.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.ReleaseAction != models.ReleaseActionReuse || plan.ExistingReleaseID != releaseId {
+		t.Fatalf("Expected the plan to reuse %s, got %+v", releaseId, plan)
+	}
+
+	result, err := client.CreateAndDeployRelease(context.Background(), project, updateMessage, "1.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if releases := fake.Releases(); len(releases) != 1 || result.ReleaseAction != models.ReleaseActionReuse {
+		t.Fatalf("Expected the existing release to be reused, got %+v and %+v", releases, result)
+	}
+
+	latestRelease, err := client.GetLatestDeploymentRelease(context.Background(), project.Project, project.Environment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latestRelease == nil || latestRelease.ID != releaseId {
+		t.Fatalf("Expected %s to be the latest release in Development, got %+v", releaseId, latestRelease)
+	}
+}
+
+func TestLiveClientValidatesLifecycle(t *testing.T) {
+	fake := createFakeOctopus(t)
+	productionLifecycle := fake.AddLifecycle("Production Only", &lifecycles.Phase{
+		Name:                      "Production",
+		OptionalDeploymentTargets: []string{fake.production},
+	})
+	fake.AddChannel(fake.project, "Hotfix", productionLifecycle)
+	fake.AddVariable(fake.project, "Metadata.ArgoCD.Application[argocd/myapp].Channel", "Hotfix")
+	client := createFakeOctopusClient(t, fake)
+
+	updateMessage := models.ApplicationUpdateMessage{Namespace: "argocd", Application: "myapp"}
+	project := getFakeProject(t, client, updateMessage)
+
+	_, err := client.CreateAndDeployRelease(context.Background(), project, updateMessage, "1.0.0")
+
+	if apperrors.Classify(err).Code != apperrors.CodeOctopusLifecycleEnvironment {
+		t.Fatalf("Expected a lifecycle error, got %v", err)
+	}
+
+	if len(fake.Releases()) != 0 || len(fake.Deployments()) != 0 {
+		t.Fatal("Expected no releases or deployments to be created")
+	}
+}
+
+func TestLiveClientAutomaticDeployment(t *testing.T) {
+	fake := createFakeOctopus(t)
+	automaticLifecycle := fake.AddLifecycle("Automatic", &lifecycles.Phase{
+		Name:                       "Development",
+		AutomaticDeploymentTargets: []string{fake.development},
+	})
+	fake.AddChannel(fake.project, "Automatic", automaticLifecycle)
+	fake.AddVariable(fake.project, "Metadata.ArgoCD.Application[argocd/myapp].Channel", "Automatic")
+	client := createFakeOctopusClient(t, fake)
+
+	updateMessage := models.ApplicationUpdateMessage{Namespace: "argocd", Application: "myapp"}
+	project := getFakeProject(t, client, updateMessage)
+
+	result, err := client.CreateAndDeployRelease(context.Background(), project, updateMessage, "1.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Octopus deploys releases to automatic deployment targets, so the proxy only creates the release
+	if result.DeploymentAction != models.DeploymentActionAutomatic {
+		t.Fatalf("Expected an automatic deployment, got %+v", result)
+	}
+
+	if len(fake.Releases()) != 1 || len(fake.Deployments()) != 0 {
+		t.Fatalf("Expected a release and no deployments, got %d releases and %d deployments", len(fake.Releases()), len(fake.Deployments()))
+	}
+}
+
+func TestLiveClientCheckConnection(t *testing.T) {
+	fake := createFakeOctopus(t)
+	client := createFakeOctopusClient(t, fake)
+
+	if err := client.CheckConnection(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The API key and space are validated when the client is created, so simulate a key revoked and a space
+	// deleted after startup
+	client.api.spaceId = "Spaces-999"
+
+	if err := client.CheckConnection(context.Background()); apperrors.Classify(err).Code != apperrors.CodeOctopusSpaceNotFound {
+		t.Fatalf("Expected a missing space error, got %v", err)
+	}
+
+	client.api.apiKey = "API-REVOKED"
+
+	if err := client.CheckConnection(context.Background()); apperrors.Classify(err).Code != apperrors.CodeOctopusUnauthorized {
+		t.Fatalf("Expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestLiveClientGetLatestDeploymentRelease(t *testing.T) {
+	fake := createFakeOctopus(t)
+	client := createFakeOctopusClient(t, fake)
+	channel := fake.DefaultChannelID(fake.project)
+
+	fake.AddDeployment(fake.AddRelease(fake.project, channel, "1.0.0"), fake.production)
+	fake.AddDeployment(fake.AddRelease(fake.project, channel, "1.0.1"), fake.development)
+
+	// The latest deployment is found however many releases have been created since
+	for i := 0; i < 150; i++ {
+		fake.AddDeployment(fake.AddRelease(fake.project, channel, fmt.Sprintf("2.0.%d", i)), fake.development)
+	}
+
+	project := &models.Project{ID: fake.project, Name: "Project 1"}
+	release, err := client.GetLatestDeploymentRelease(context.Background(), project, &models.Environment{ID: fake.production})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release == nil || release.Version != "1.0.0" {
+		t.Fatalf("Expected release 1.0.0, got %+v", release)
+	}
+
+	release, err = client.GetLatestDeploymentRelease(context.Background(), project, &models.Environment{ID: fake.development})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release == nil || release.Version != "2.0.149" {
+		t.Fatalf("Expected release 2.0.149, got %+v", release)
+	}
+}
+
+func TestLiveClientRejectsInvalidCacheTtl(t *testing.T) {
+	fake := createFakeOctopus(t)
+	t.Setenv("OCTOPUS_SERVER", fake.URL())
+	t.Setenv("OCTOPUS_API_KEY", fakes.FakeOctopusApiKey)
+	t.Setenv("OCTOPUS_SPACE_ID", fakes.FakeOctopusSpaceId)
+
+	for _, ttl := range []string{"0s", "-1m"} {
+		t.Setenv("OCTOPUS_CACHE_TTL", ttl)
+
+		if _, err := NewLiveOctopusClient(); err == nil {
+			t.Fatalf("Expected the cache TTL %s to be rejected", ttl)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	client := &LiveOctopusClient{
+		transport: NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), NewCircuitBreaker(5, time.Minute)),
+	}
+
+	tests := []struct {
+		err  error
+		code apperrors.Code
+	}{
+		{&OctopusResponseError{StatusCode: 401}, apperrors.CodeOctopusUnauthorized},
+		{&core.APIError{StatusCode: 403}, apperrors.CodeOctopusUnauthorized},
+		{&OctopusResponseError{StatusCode: 400}, apperrors.CodeOctopusRequestRejected},
+		{&core.APIError{StatusCode: 404}, apperrors.CodeOctopusRequestRejected},
+		{&OctopusResponseError{StatusCode: 429}, apperrors.CodeOctopusRequestFailed},
+		{&OctopusResponseError{StatusCode: 500}, apperrors.CodeOctopusRequestFailed},
+		{errors.New("connection refused"), apperrors.CodeOctopusRequestFailed},
+		{apperrors.New(apperrors.CodeOctopusStepNotFound, "missing step"), apperrors.CodeOctopusStepNotFound},
+	}
+
+	for _, test := range tests {
+		if code := apperrors.Classify(client.classifyError(test.err)).Code; code != test.code {
+			t.Fatalf("Expected %v to be classified as %s, got %s", test.err, test.code, code)
+		}
+	}
+}
+
+func TestClassifyErrorWhileCircuitIsOpen(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Minute)
+	client := &LiveOctopusClient{
+		transport: NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), breaker),
+	}
+	breaker.Failure()
+
+	tests := []struct {
+		err  error
+		code apperrors.Code
+	}{
+		{&OctopusResponseError{StatusCode: 401}, apperrors.CodeOctopusUnauthorized},
+		{&core.APIError{StatusCode: 404}, apperrors.CodeOctopusRequestRejected},
+		{&OctopusResponseError{StatusCode: 500}, apperrors.CodeOctopusUnavailable},
+		{errors.New("connection refused"), apperrors.CodeOctopusUnavailable},
+	}
+
+	for _, test := range tests {
+		if code := apperrors.Classify(client.classifyError(test.err)).Code; code != test.code {
+			t.Fatalf("Expected %v to be classified as %s, got %s", test.err, test.code, code)
+		}
+	}
+}
+
+func TestLiveClientCheckPermissions(t *testing.T) {
+	fake := createFakeOctopus(t)
+	client := createFakeOctopusClient(t, fake)
+
+	missing, err := client.CheckPermissions(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(missing) != 0 {
+		t.Fatalf("Expected every permission to be granted, %v were missing", missing)
+	}
+
+	// Without a space ID the permissions are checked in the default space
+	client.api.spaceId = ""
+
+	if missing, err := client.CheckPermissions(context.Background()); err != nil || len(missing) != 0 {
+		t.Fatalf("Expected every permission to be granted in the default space, got %v and %v", missing, err)
+	}
+
+	client.api.spaceId = fakes.FakeOctopusSpaceId
+	fake.RevokePermission("ReleaseCreate")
+
+	missing, err = client.CheckPermissions(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(missing) != 1 || missing[0] != "ReleaseCreate" {
+		t.Fatalf("Expected ReleaseCreate to be missing, got %v", missing)
+	}
+
+	// Permissions granted in other spaces do not allow releases to be created in the proxy's space
+	client.api.spaceId = "Spaces-999"
+
+	if missing, err := client.CheckPermissions(context.Background()); err != nil || len(missing) != len(RequiredPermissions) {
+		t.Fatalf("Expected every permission to be missing in another space, got %v and %v", missing, err)
+	}
+}
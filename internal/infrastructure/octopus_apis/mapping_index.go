@@ -0,0 +1,308 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/tracing"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIndexRefreshInterval is how often the index looks for new and deleted projects, and reloads the variables of
+// projects that have been invalidated.
+const DefaultIndexRefreshInterval = time.Minute
+
+// DefaultIndexFullRefreshInterval is how often the index reloads the variables of every project, which picks up
+// changes that were not reported by a webhook.
+const DefaultIndexFullRefreshInterval = 5 * time.Minute
+
+// indexRefreshTimeout limits how long a background refresh can run for.
+const indexRefreshTimeout = 5 * time.Minute
+
+// mappingIndex maintains an in-memory index from namespace/application to the projects whose metadata variables map
+// them, so a notification does not require a scan of every project in Octopus. The index is loaded on first use and
+// then refreshed in the background.
+type mappingIndex struct {
+	logger              apploggers.AppLogger
+	listProjects        func(ctx context.Context) ([]*models.Project, error)
+	getVariables        func(ctx context.Context, projectId string) (*models.VariableSet, error)
+	refreshInterval     time.Duration
+	fullRefreshInterval time.Duration
+
+	mutex           sync.RWMutex
+	loaded          bool
+	projects        map[string]models.OctopusProjectAndVars
+	applications    map[string][]models.ArgoCDProject
+	invalidProjects map[string]bool
+	lastRefresh     time.Time
+	lastFullRefresh time.Time
+
+	// refreshing serialises the refreshes, as they make many requests to Octopus
+	refreshing sync.Mutex
+}
+
+func newMappingIndex(
+	logger apploggers.AppLogger,
+	listProjects func(ctx context.Context) ([]*models.Project, error),
+	getVariables func(ctx context.Context, projectId string) (*models.VariableSet, error),
+	refreshInterval time.Duration,
+	fullRefreshInterval time.Duration) *mappingIndex {
+	return &mappingIndex{
+		logger:              logger,
+		listProjects:        listProjects,
+		getVariables:        getVariables,
+		refreshInterval:     refreshInterval,
+		fullRefreshInterval: fullRefreshInterval,
+		projects:            map[string]models.OctopusProjectAndVars{},
+		applications:        map[string][]models.ArgoCDProject{},
+		invalidProjects:     map[string]bool{},
+	}
+}
+
+// lookup returns the projects mapped to an application. Applications that are not in the index have no projects,
+// and do not trigger a scan of Octopus.
+func (i *mappingIndex) lookup(ctx context.Context, namespace string, application string) ([]models.ArgoCDProject, error) {
+	if err := i.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	i.mutex.RLock()
+	projects := i.applications[namespace+"/"+application]
+	i.mutex.RUnlock()
+
+	i.refreshIfStale()
+
+	return projects, nil
+}
+
+// snapshot returns every project in the index with its variables, sorted by name.
+func (i *mappingIndex) snapshot(ctx context.Context) ([]models.OctopusProjectAndVars, error) {
+	if err := i.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+
+	i.mutex.RLock()
+	projects := make([]models.OctopusProjectAndVars, 0, len(i.projects))
+	for _, project := range i.projects {
+		projects = append(projects, project)
+	}
+	i.mutex.RUnlock()
+
+	sort.Slice(projects, func(a, b int) bool {
+		if projects[a].Project.Name != projects[b].Project.Name {
+			return projects[a].Project.Name < projects[b].Project.Name
+		}
+		return projects[a].Project.ID < projects[b].Project.ID
+	})
+
+	i.refreshIfStale()
+
+	return projects, nil
+}
+
+// invalidateProject reloads the variables of a project in the background.
+func (i *mappingIndex) invalidateProject(projectId string) {
+	i.mutex.Lock()
+	i.invalidProjects[projectId] = true
+	i.mutex.Unlock()
+
+	i.refreshInBackground()
+}
+
+// invalidateAll reloads the variables of every project in the background.
+func (i *mappingIndex) invalidateAll() {
+	i.mutex.Lock()
+	i.lastFullRefresh = time.Time{}
+	i.mutex.Unlock()
+
+	i.refreshInBackground()
+}
+
+// ensureLoaded loads the index the first time it is used, blocking until the projects have been scanned.
+func (i *mappingIndex) ensureLoaded(ctx context.Context) error {
+	if i.isLoaded() {
+		return nil
+	}
+
+	i.refreshing.Lock()
+	defer i.refreshing.Unlock()
+
+	if i.isLoaded() {
+		return nil
+	}
+
+	return i.refresh(ctx)
+}
+
+func (i *mappingIndex) isLoaded() bool {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.loaded
+}
+
+// stats returns the number of indexed projects, and when the index was last refreshed.
+func (i *mappingIndex) stats() (loaded bool, projects int, lastRefresh time.Time, lastFullRefresh time.Time) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	return i.loaded, len(i.projects), i.lastRefresh, i.lastFullRefresh
+}
+
+// refreshIfStale starts a background refresh when the refresh interval has passed.
+func (i *mappingIndex) refreshIfStale() {
+	i.mutex.RLock()
+	stale := i.refreshInterval > 0 && time.Since(i.lastRefresh) >= i.refreshInterval
+	i.mutex.RUnlock()
+
+	if stale {
+		i.refreshInBackground()
+	}
+}
+
+// refreshInBackground refreshes the index unless a refresh is already running. Projects invalidated while the
+// refresh runs are picked up by another refresh once it completes.
+func (i *mappingIndex) refreshInBackground() {
+	if !i.refreshing.TryLock() {
+		return
+	}
+
+	go func() {
+		defer i.refreshing.Unlock()
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), indexRefreshTimeout)
+			err := i.refresh(ctx)
+			cancel()
+
+			if err != nil {
+				i.logger.GetLogger().Warn("octoargosync-index-refresherror: Failed to refresh the mapping index: "+err.Error(), zap.Error(err))
+				return
+			}
+
+			i.mutex.RLock()
+			pending := len(i.invalidProjects) != 0 || i.lastFullRefresh.IsZero()
+			i.mutex.RUnlock()
+
+			if !pending {
+				return
+			}
+		}
+	}()
+}
+
+// refresh lists the projects and reloads the variables of new and invalidated projects, or every project when a full
+// refresh is due. The caller must hold the refreshing lock.
+func (i *mappingIndex) refresh(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "mappingIndex.refresh")
+	defer func() { tracing.End(span, err) }()
+
+	i.mutex.Lock()
+	invalidProjects := i.invalidProjects
+	i.invalidProjects = map[string]bool{}
+	full := !i.loaded || i.lastFullRefresh.IsZero() ||
+		(i.fullRefreshInterval > 0 && time.Since(i.lastFullRefresh) >= i.fullRefreshInterval)
+	existingProjects := i.projects
+	i.mutex.Unlock()
+
+	projects, err := i.refreshProjects(ctx, existingProjects, invalidProjects, full)
+
+	if err != nil {
+		// Try the invalidated projects again with the next refresh
+		i.mutex.Lock()
+		for projectId := range invalidProjects {
+			i.invalidProjects[projectId] = true
+		}
+		i.mutex.Unlock()
+
+		return err
+	}
+
+	applications := indexApplications(projects)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.projects = projects
+	i.applications = applications
+	i.loaded = true
+	i.lastRefresh = time.Now()
+	if full {
+		i.lastFullRefresh = i.lastRefresh
+	}
+
+	return nil
+}
+
+func (i *mappingIndex) refreshProjects(ctx context.Context, existingProjects map[string]models.OctopusProjectAndVars, invalidProjects map[string]bool, full bool) (map[string]models.OctopusProjectAndVars, error) {
+	octopusProjects, err := i.listProjects(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projects := map[string]models.OctopusProjectAndVars{}
+	for _, project := range octopusProjects {
+		existing, found := existingProjects[project.ID]
+
+		if found && !full && !invalidProjects[project.ID] {
+			projects[project.ID] = models.OctopusProjectAndVars{
+				Project:   project,
+				Variables: existing.Variables,
+			}
+			continue
+		}
+
+		variables, err := i.getVariables(ctx, project.ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		projects[project.ID] = models.OctopusProjectAndVars{
+			Project:   project,
+			Variables: variables,
+		}
+	}
+
+	return projects, nil
+}
+
+// indexApplications maps each application referenced by an environment metadata variable to the projects it
+// creates releases in.
+func indexApplications(projects map[string]models.OctopusProjectAndVars) map[string][]models.ArgoCDProject {
+	applications := map[string][]models.ArgoCDProject{}
+	for _, project := range projects {
+		if project.Variables == nil {
+			continue
+		}
+
+		projectApplications := map[string]bool{}
+		for _, variable := range project.Variables.Variables {
+			match := ApplicationEnvironmentVariable.FindStringSubmatch(variable.Name)
+
+			if len(match) == 2 && strings.Contains(match[1], "/") {
+				projectApplications[match[1]] = true
+			}
+		}
+
+		for application := range projectApplications {
+			namespace, name, _ := strings.Cut(application, "/")
+			applications[application] = append(
+				applications[application],
+				matchArgoCDApplication([]models.OctopusProjectAndVars{project}, name, namespace)...)
+		}
+	}
+
+	for _, applicationProjects := range applications {
+		sort.Slice(applicationProjects, func(a, b int) bool {
+			return applicationProjects[a].Project.Name < applicationProjects[b].Project.Name
+		})
+	}
+
+	return applications
+}
@@ -0,0 +1,177 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/apploggers"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProjectSource serves projects and variables from memory, counting the requests made by the index.
+type fakeProjectSource struct {
+	mutex           sync.Mutex
+	projects        []*models.Project
+	variables       map[string]string
+	projectRequests int
+	variableLoads   map[string]int
+}
+
+func newFakeProjectSource() *fakeProjectSource {
+	return &fakeProjectSource{
+		variables:     map[string]string{},
+		variableLoads: map[string]int{},
+	}
+}
+
+func (f *fakeProjectSource) addProject(id string, name string, application string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.projects = append(f.projects, &models.Project{ID: id, Name: name})
+	f.variables[id] = application
+}
+
+func (f *fakeProjectSource) setApplication(id string, application string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.variables[id] = application
+}
+
+func (f *fakeProjectSource) listProjects(ctx context.Context) ([]*models.Project, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.projectRequests++
+	return append([]*models.Project{}, f.projects...), nil
+}
+
+func (f *fakeProjectSource) getVariables(ctx context.Context, projectId string) (*models.VariableSet, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.variableLoads[projectId]++
+	return &models.VariableSet{
+		Variables: []models.Variable{
+			{Name: "Metadata.ArgoCD.Application[" + f.variables[projectId] + "].Environment", Value: "Development"},
+		},
+	}, nil
+}
+
+func (f *fakeProjectSource) getVariableLoads(projectId string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.variableLoads[projectId]
+}
+
+func createTestIndex(t *testing.T, source *fakeProjectSource) *mappingIndex {
+	logger, err := apploggers.NewDevProdLogger()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Long intervals mean the tests control when the index is refreshed
+	return newMappingIndex(logger, source.listProjects, source.getVariables, time.Hour, time.Hour)
+}
+
+// waitForRefresh blocks until any background refresh has completed.
+func waitForRefresh(index *mappingIndex) {
+	index.refreshing.Lock()
+	index.refreshing.Unlock()
+}
+
+func TestMappingIndexLookup(t *testing.T) {
+	source := newFakeProjectSource()
+	source.addProject("Projects-1", "Project 1", "argocd/app1")
+	source.addProject("Projects-2", "Project 2", "argocd/app1")
+	source.addProject("Projects-3", "Project 3", "argocd/app2")
+
+	index := createTestIndex(t, source)
+
+	projects, err := index.lookup(context.Background(), "argocd", "app1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(projects) != 2 || projects[0].Project.Name != "Project 1" || projects[1].Project.Name != "Project 2" {
+		t.Fatalf("Expected Project 1 and Project 2, got %+v", projects)
+	}
+
+	// Unseen applications and repeated lookups are served from the index
+	projects, err = index.lookup(context.Background(), "argocd", "unknown")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(projects) != 0 {
+		t.Fatalf("Expected no projects, got %+v", projects)
+	}
+
+	_, err = index.lookup(context.Background(), "argocd", "app2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source.projectRequests != 1 || source.getVariableLoads("Projects-1") != 1 {
+		t.Fatalf("Expected the projects to be scanned once, got %d project requests", source.projectRequests)
+	}
+}
+
+func TestMappingIndexInvalidateProject(t *testing.T) {
+	source := newFakeProjectSource()
+	source.addProject("Projects-1", "Project 1", "argocd/app1")
+	source.addProject("Projects-2", "Project 2", "argocd/app2")
+
+	index := createTestIndex(t, source)
+
+	if _, err := index.lookup(context.Background(), "argocd", "app1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mapping changes are only seen once the project is invalidated
+	source.setApplication("Projects-1", "argocd/app3")
+	source.addProject("Projects-3", "Project 3", "argocd/app3")
+
+	index.invalidateProject("Projects-1")
+	waitForRefresh(index)
+
+	projects, err := index.lookup(context.Background(), "argocd", "app3")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(projects) != 2 {
+		t.Fatalf("Expected the changed and new projects to be mapped, got %+v", projects)
+	}
+
+	if source.getVariableLoads("Projects-1") != 2 || source.getVariableLoads("Projects-2") != 1 || source.getVariableLoads("Projects-3") != 1 {
+		t.Fatal("Expected only the invalidated and new projects to be reloaded")
+	}
+}
+
+func TestMappingIndexInvalidateAll(t *testing.T) {
+	source := newFakeProjectSource()
+	source.addProject("Projects-1", "Project 1", "argocd/app1")
+	source.addProject("Projects-2", "Project 2", "argocd/app2")
+
+	index := createTestIndex(t, source)
+
+	if _, err := index.snapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	index.invalidateAll()
+	waitForRefresh(index)
+
+	if source.getVariableLoads("Projects-1") != 2 || source.getVariableLoads("Projects-2") != 2 {
+		t.Fatal("Expected every project to be reloaded")
+	}
+}
@@ -0,0 +1,356 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/deployments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/packages"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"github.com/samber/lo"
+	"golang.org/x/exp/slices"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GetMappings returns every ArgoCD application that has been mapped to an Octopus project.
+func (o *LiveOctopusClient) GetMappings(ctx context.Context) (_ []models.ApplicationMapping, octopusErr error) {
+	defer func() { octopusErr = o.classifyError(octopusErr) }()
+
+	allProjects, err := o.index.snapshot(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mappings, err := o.getApplicationMappings(allProjects)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range mappings {
+		project, _ := lo.Find(allProjects, func(item models.OctopusProjectAndVars) bool {
+			return item.Project.ID == mappings[i].ProjectID
+		})
+
+		err = o.resolveMapping(ctx, &mappings[i], project.Project)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mappings, nil
+}
+
+// ValidateMappings checks that the environments, channels, lifecycles, and package references in the metadata
+// variables of every project exist, and returns the problems that would prevent releases from being created.
+func (o *LiveOctopusClient) ValidateMappings(ctx context.Context) (_ []models.MappingProblem, octopusErr error) {
+	defer func() { octopusErr = o.classifyError(octopusErr) }()
+
+	allProjects, err := o.index.snapshot(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []models.MappingProblem{}
+	for _, project := range allProjects {
+		projectProblems, err := o.validateProjectMappings(ctx, project)
+
+		if err != nil {
+			return nil, err
+		}
+
+		problems = append(problems, projectProblems...)
+	}
+
+	return problems, nil
+}
+
+// getApplicationMappings finds all the applications referenced by the environment metadata variables, and maps them
+// to the projects they create releases in.
+func (o *LiveOctopusClient) getApplicationMappings(allProjects []models.OctopusProjectAndVars) ([]models.ApplicationMapping, error) {
+	applications := []string{}
+	for _, project := range allProjects {
+		for _, variable := range project.Variables.Variables {
+			match := ApplicationEnvironmentVariable.FindStringSubmatch(variable.Name)
+
+			if len(match) == 2 && strings.Contains(match[1], "/") {
+				applications = append(applications, match[1])
+			}
+		}
+	}
+
+	applications = lo.Uniq(applications)
+	sort.Strings(applications)
+
+	mappings := []models.ApplicationMapping{}
+	for _, application := range applications {
+		namespace, name, _ := strings.Cut(application, "/")
+
+		projects, err := o.getProjectsMatchingArgoCDApplication(allProjects, name, namespace)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, project := range projects {
+			mappings = append(mappings, models.ApplicationMapping{
+				Namespace:           namespace,
+				Application:         name,
+				ProjectID:           project.Project.ID,
+				Project:             project.Project.Name,
+				Environment:         project.EnvironmentName,
+				Channel:             project.ChannelName,
+				ReleaseVersionImage: project.ReleaseVersionImage,
+				PackageVersions:     project.PackageVersions,
+			})
+		}
+	}
+
+	return mappings, nil
+}
+
+// resolveMapping finds the channel and lifecycle used by a mapping. Misconfigured mappings are left unresolved, as
+// they are reported by ValidateMappings.
+func (o *LiveOctopusClient) resolveMapping(ctx context.Context, mapping *models.ApplicationMapping, project *models.Project) error {
+	var channel *models.Channel
+	var err error
+	if mapping.Channel != "" {
+		channel, err = o.getChannel(ctx, project, mapping.Channel)
+	} else {
+		channel, err = o.getDefaultChannel(ctx, project)
+	}
+
+	if err != nil {
+		return ignorePermanentError(err)
+	}
+
+	mapping.Channel = channel.Name
+
+	lifecycle, err := o.getChannelLifecycle(ctx, project, channel)
+
+	if err != nil {
+		return ignorePermanentError(err)
+	}
+
+	mapping.Lifecycle = lifecycle.Name
+
+	return nil
+}
+
+// ignorePermanentError returns nil for errors caused by misconfiguration.
+func ignorePermanentError(err error) error {
+	if apperrors.Classify(err).Permanent() {
+		return nil
+	}
+
+	return err
+}
+
+// validateProjectMappings checks the metadata variables of a single project. Errors that indicate a misconfigured
+// variable are returned as problems, while any other error, like Octopus being unavailable, is returned as an error.
+func (o *LiveOctopusClient) validateProjectMappings(ctx context.Context, project models.OctopusProjectAndVars) ([]models.MappingProblem, error) {
+	problems := []models.MappingProblem{}
+
+	addProblem := func(application string, variable models.Variable, err error) error {
+		appError := apperrors.Classify(err)
+
+		if !appError.Permanent() {
+			return err
+		}
+
+		namespace, name, _ := strings.Cut(application, "/")
+		problems = append(problems, models.MappingProblem{
+			Namespace:   namespace,
+			Application: name,
+			ProjectID:   project.Project.ID,
+			Project:     project.Project.Name,
+			Variable:    variable.Name,
+			Code:        string(appError.Code),
+			Message:     appError.Message,
+		})
+
+		return nil
+	}
+
+	environments := map[string]models.Variable{}
+	channels := map[string]models.Variable{}
+	otherVariables := map[string][]models.Variable{}
+	packageReferences := map[string][]models.Variable{}
+
+	for _, variable := range project.Variables.Variables {
+		if match := ApplicationEnvironmentVariable.FindStringSubmatch(variable.Name); len(match) == 2 {
+			environments[match[1]] = variable
+		} else if match := ApplicationChannelVariable.FindStringSubmatch(variable.Name); len(match) == 2 {
+			channels[match[1]] = variable
+		} else if match := ApplicationImageReleaseVersionVariable.FindStringSubmatch(variable.Name); len(match) == 2 {
+			otherVariables[match[1]] = append(otherVariables[match[1]], variable)
+		} else if match := ApplicationImagePackageVersionVariable.FindStringSubmatch(variable.Name); len(match) == 3 {
+			otherVariables[match[1]] = append(otherVariables[match[1]], variable)
+			packageReferences[match[2]] = append(packageReferences[match[2]], variable)
+		} else {
+			continue
+		}
+
+		if application := getMetadataApplication(variable.Name); !strings.Contains(application, "/") {
+			err := addProblem(application, variable, apperrors.New(apperrors.CodeMappingApplicationInvalid,
+				"the application "+application+" must be in the format namespace/application"))
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, channel := range channels {
+		otherVariables[getMetadataApplication(channel.Name)] = append(otherVariables[getMetadataApplication(channel.Name)], channel)
+	}
+
+	// Variables for applications without an environment are ignored
+	for application, variables := range otherVariables {
+		if _, found := environments[application]; found {
+			continue
+		}
+
+		for _, variable := range variables {
+			err := addProblem(application, variable, apperrors.New(apperrors.CodeMappingEnvironmentMissing,
+				"the application "+application+" has no environment variable, so this variable is ignored"))
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for application, environmentVariable := range environments {
+		environment, err := o.getEnvironment(ctx, environmentVariable.Value)
+
+		if err != nil {
+			if err := addProblem(application, environmentVariable, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var channel *models.Channel
+		channelVariable, hasChannel := channels[application]
+		if hasChannel && strings.TrimSpace(channelVariable.Value) != "" {
+			channel, err = o.getChannel(ctx, project.Project, channelVariable.Value)
+		} else {
+			channelVariable = environmentVariable
+			channel, err = o.getDefaultChannel(ctx, project.Project)
+		}
+
+		if err != nil {
+			if err := addProblem(application, channelVariable, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		lifecycle, err := o.getChannelLifecycle(ctx, project.Project, channel)
+
+		if err == nil {
+			err = o.validateLifecycle(ctx, lifecycle, environment)
+		}
+
+		if err != nil {
+			if err := addProblem(application, channelVariable, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Projects with a version controlled deployment process have no deployment process ID, so their steps are not checked
+	if len(packageReferences) != 0 && project.Project.DeploymentProcessID != "" {
+		var deploymentProcess *deployments.DeploymentProcess
+		err := retry_config.Do(ctx, retry_config.OctopusRead, func() error {
+			var err error
+			deploymentProcess, err = o.client.DeploymentProcesses.GetByID(project.Project.DeploymentProcessID)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Sort the references so the problems are reported in a consistent order
+		references := lo.Keys(packageReferences)
+		sort.Strings(references)
+
+		for _, reference := range references {
+			err := validatePackageReference(deploymentProcess, reference)
+
+			if err == nil {
+				continue
+			}
+
+			for _, variable := range packageReferences[reference] {
+				if err := addProblem(getMetadataApplication(variable.Name), variable, err); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Variable != problems[j].Variable {
+			return problems[i].Variable < problems[j].Variable
+		}
+
+		return problems[i].Code < problems[j].Code
+	})
+
+	return problems, nil
+}
+
+// validatePackageReference checks that a package reference in the format step or step:package matches a step and
+// package in the deployment process.
+func validatePackageReference(deploymentProcess *deployments.DeploymentProcess, reference string) error {
+	split := strings.Split(reference, ":")
+
+	if len(split) > 2 {
+		return apperrors.New(apperrors.CodeOctopusPackageReferenceInvalid, "the package reference "+reference+
+			" must be a string separated by 0 or 1 colons e.g. stepname, stepname:packagename")
+	}
+
+	actions := lo.FlatMap(deploymentProcess.Steps, func(item *deployments.DeploymentStep, index int) []*deployments.DeploymentAction {
+		return item.Actions
+	})
+
+	action, found := lo.Find(actions, func(item *deployments.DeploymentAction) bool {
+		return item.Name == split[0]
+	})
+
+	if !found {
+		return apperrors.New(apperrors.CodeOctopusStepNotFound, "the deployment process has no step called "+split[0])
+	}
+
+	if len(split) == 2 {
+		packageNames := lo.Map(action.Packages, func(item *packages.PackageReference, index int) string {
+			return item.Name
+		})
+
+		if slices.Index(packageNames, split[1]) == -1 {
+			return apperrors.New(apperrors.CodeOctopusPackageNotFound, "the step "+split[0]+" has no package called "+split[1])
+		}
+	}
+
+	return nil
+}
+
+// getMetadataApplication returns the application referenced by a metadata variable name.
+func getMetadataApplication(variableName string) string {
+	for _, expression := range []*regexp.Regexp{ApplicationEnvironmentVariable, ApplicationChannelVariable,
+		ApplicationImageReleaseVersionVariable, ApplicationImagePackageVersionVariable} {
+		if match := expression.FindStringSubmatch(variableName); len(match) >= 2 {
+			return match[1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,84 @@
+package octopus_apis
+
+import (
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/deployments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/packages"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"testing"
+)
+
+func TestGetApplicationMappings(t *testing.T) {
+	project := &models.Project{ID: "Projects-1", Name: "Project 1"}
+
+	allProjects := []models.OctopusProjectAndVars{
+		{
+			Project: project,
+			Variables: &models.VariableSet{
+				Variables: []models.Variable{
+					{Name: "Metadata.ArgoCD.Application[argocd/myapp].Environment", Value: "Development"},
+					{Name: "Metadata.ArgoCD.Application[argocd/myapp].Channel", Value: "Mainline"},
+					{Name: "Metadata.ArgoCD.Application[argocd/myapp].ImageForPackageVersion[Deploy:web]", Value: "nginx"},
+					// Applications without an environment are not mapped
+					{Name: "Metadata.ArgoCD.Application[argocd/other].Channel", Value: "Mainline"},
+				},
+			},
+		},
+	}
+
+	client := &LiveOctopusClient{}
+	mappings, err := client.getApplicationMappings(allProjects)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mappings) != 1 {
+		t.Fatalf("Expected 1 mapping, got %d", len(mappings))
+	}
+
+	mapping := mappings[0]
+	if mapping.Namespace != "argocd" || mapping.Application != "myapp" || mapping.Project != "Project 1" ||
+		mapping.Environment != "Development" || mapping.Channel != "Mainline" || len(mapping.PackageVersions) != 1 {
+		t.Fatalf("Unexpected mapping %+v", mapping)
+	}
+}
+
+func TestValidatePackageReference(t *testing.T) {
+	deploymentProcess := &deployments.DeploymentProcess{
+		Steps: []*deployments.DeploymentStep{
+			{
+				Name: "Deploy",
+				Actions: []*deployments.DeploymentAction{
+					{
+						Name:     "Deploy",
+						Packages: []*packages.PackageReference{{Name: ""}, {Name: "web"}},
+					},
+				},
+			},
+		},
+	}
+
+	tests := map[string]apperrors.Code{
+		"Deploy":        "",
+		"Deploy:web":    "",
+		"Deploy:api":    apperrors.CodeOctopusPackageNotFound,
+		"Deplyo":        apperrors.CodeOctopusStepNotFound,
+		"Deploy:web:v2": apperrors.CodeOctopusPackageReferenceInvalid,
+	}
+
+	for reference, code := range tests {
+		err := validatePackageReference(deploymentProcess, reference)
+
+		if code == "" {
+			if err != nil {
+				t.Fatalf("Expected %s to be valid, got %v", reference, err)
+			}
+			continue
+		}
+
+		if appError, ok := apperrors.As(err); !ok || appError.Code != code {
+			t.Fatalf("Expected %s to fail with %s, got %v", reference, code, err)
+		}
+	}
+}
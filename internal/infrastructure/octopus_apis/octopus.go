@@ -1,22 +1,40 @@
 package octopus_apis
 
 import (
-	"github.com/OctopusDeploy/go-octopusdeploy/octopusdeploy"
+	"context"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
 	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/types"
 )
 
+// OctopusClient defines the operations performed against Octopus. All functions accept a context, which is used to
+// cancel any retry loops and in flight requests when the context is cancelled or its deadline is exceeded.
 type OctopusClient interface {
 	// GetProjects returns the details of projects that match the incoming message
-	GetProjects(updateMessage models.ApplicationUpdateMessage) ([]models.ArgoCDProjectExpanded, error)
-	// CreateAndDeployRelease will ensure the release is deployed to the correct environment, creating a new release if necessary
-	CreateAndDeployRelease(project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) error
+	GetProjects(ctx context.Context, updateMessage models.ApplicationUpdateMessage) ([]models.ArgoCDProjectExpanded, error)
+	// CreateAndDeployRelease will ensure the release is deployed to the correct environment, creating a new release if
+	// necessary, and returns the release and deployment
+	CreateAndDeployRelease(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) (models.ReleaseResult, error)
+	// PlanRelease describes the release CreateAndDeployRelease would create or reuse, without writing anything to Octopus
+	PlanRelease(ctx context.Context, project models.ArgoCDProjectExpanded, updateMessage models.ApplicationUpdateMessage, version types.OctopusReleaseVersion) (models.ProjectReleasePlan, error)
+	// GetMappings returns every ArgoCD application mapped to an Octopus project
+	GetMappings(ctx context.Context) ([]models.ApplicationMapping, error)
+	// ValidateMappings returns the problems with the metadata variables that map ArgoCD applications to Octopus projects
+	ValidateMappings(ctx context.Context) ([]models.MappingProblem, error)
+	// Invalidate evicts the cached resources referenced by the document IDs of an Octopus event, returning the IDs and
+	// cache keys that were evicted
+	Invalidate(documentIds []string) []string
+	// CheckConnection confirms Octopus is reachable, the API key is valid, and the space exists
+	CheckConnection(ctx context.Context) error
+	// CheckPermissions returns the required permissions the API key has not been granted in the space
+	CheckPermissions(ctx context.Context) ([]string, error)
+	// GetStats returns the state of the cache, the mapping index, and the circuit breaker
+	GetStats() models.OctopusClientStats
 	// GetReleaseVersions returns the releases associated with a project
-	GetReleaseVersions(project *octopusdeploy.Project) ([]types.OctopusReleaseVersion, error)
+	GetReleaseVersions(ctx context.Context, project *models.Project) ([]types.OctopusReleaseVersion, error)
 	// IsDeployed returns true if the release is deployed to the specified environment
-	IsDeployed(project *octopusdeploy.Project, releaseVersion types.OctopusReleaseVersion, environment *octopusdeploy.Environment) (bool, error)
+	IsDeployed(ctx context.Context, project *models.Project, releaseVersion types.OctopusReleaseVersion, environment *models.Environment) (bool, error)
 	// GetLatestRelease returns the latest release for a project
-	GetLatestRelease(project *octopusdeploy.Project) (*octopusdeploy.Release, error)
+	GetLatestRelease(ctx context.Context, project *models.Project) (*models.Release, error)
 	// GetLatestDeploymentRelease returns the latest release thar has been deployed to a project's environment
-	GetLatestDeploymentRelease(project *octopusdeploy.Project, environment *octopusdeploy.Environment) (*octopusdeploy.Release, error)
+	GetLatestDeploymentRelease(ctx context.Context, project *models.Project, environment *models.Environment) (*models.Release, error)
 }
@@ -0,0 +1,128 @@
+package octopus_apis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OctopusResponseError is returned when Octopus responds to a request with an unexpected status code.
+type OctopusResponseError struct {
+	StatusCode int
+	Path       string
+	Message    string
+}
+
+func (e *OctopusResponseError) Error() string {
+	message := "request to " + e.Path + " failed with status " + fmt.Sprint(e.StatusCode)
+
+	if e.Message != "" {
+		return message + ": " + e.Message
+	}
+
+	return message
+}
+
+// Permanent returns true for client errors that will not be resolved by retrying the request.
+func (e *OctopusResponseError) Permanent() bool {
+	return isPermanentStatus(e.StatusCode)
+}
+
+// isPermanentStatus returns true for client error status codes that will not be resolved by retrying the request.
+func isPermanentStatus(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+}
+
+// octopusApi sends requests to the Octopus REST API for queries the client library does not support, like project
+// scoped release lookups. Requests share the throttling transport, and are cancelled with the context.
+type octopusApi struct {
+	httpClient *http.Client
+	serverUrl  *url.URL
+	apiKey     string
+	spaceId    string
+}
+
+// newOctopusApi creates an octopusApi from the OCTOPUS_SERVER, OCTOPUS_API_KEY, and OCTOPUS_SPACE_ID environment
+// variables, which have been validated by getClient.
+func newOctopusApi(httpClient *http.Client, connection OctopusConnection) (*octopusApi, error) {
+	serverUrl, err := url.Parse(connection.Server)
+
+	if err != nil {
+		return nil, apperrors.Wrap(apperrors.CodeOctopusConfigMissing, "octoargosync-init-octoclienterror - failed to parse OCTOPUS_SERVER as a url", err)
+	}
+
+	return &octopusApi{
+		httpClient: httpClient,
+		serverUrl:  serverUrl,
+		apiKey:     connection.ApiKey,
+		spaceId:    connection.SpaceId,
+	}, nil
+}
+
+// get requests a resource in the space, decoding the response into result. It returns false if the resource does
+// not exist.
+func (a *octopusApi) get(ctx context.Context, path []string, query url.Values, result any) (bool, error) {
+	if a.spaceId == "" {
+		return a.getSystem(ctx, path, query, result)
+	}
+
+	return a.getResource(ctx, append([]string{"api", a.spaceId}, path...), path, query, result)
+}
+
+// getSystem requests a resource outside the space, like the current user. It returns false if the resource does not
+// exist.
+func (a *octopusApi) getSystem(ctx context.Context, path []string, query url.Values, result any) (bool, error) {
+	return a.getResource(ctx, append([]string{"api"}, path...), path, query, result)
+}
+
+func (a *octopusApi) getResource(ctx context.Context, elements []string, path []string, query url.Values, result any) (bool, error) {
+	requestUrl := a.serverUrl.JoinPath(elements...)
+	requestUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl.String(), nil)
+
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("X-Octopus-ApiKey", a.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Octopus describes the error in the ErrorMessage field
+		octopusError := struct{ ErrorMessage string }{}
+		_ = json.Unmarshal(body, &octopusError)
+
+		return false, &OctopusResponseError{
+			StatusCode: resp.StatusCode,
+			Path:       "/" + strings.Join(path, "/"),
+			Message:    octopusError.ErrorMessage,
+		}
+	}
+
+	return true, json.Unmarshal(body, result)
+}
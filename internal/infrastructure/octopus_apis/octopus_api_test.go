@@ -0,0 +1,182 @@
+package octopus_apis
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/deployments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/releases"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// createTestApiClient creates a client that sends its requests to the supplied handler.
+func createTestApiClient(t *testing.T, handler http.Handler) *LiveOctopusClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	serverUrl, err := url.Parse(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), NewCircuitBreaker(5, time.Minute))
+
+	return &LiveOctopusClient{
+		transport: transport,
+		api: &octopusApi{
+			httpClient: &http.Client{Transport: transport},
+			serverUrl:  serverUrl,
+			apiKey:     "API-TEST",
+			spaceId:    "Spaces-1",
+		},
+	}
+}
+
+func writeTestJson(t *testing.T, w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetReleaseByVersion(t *testing.T) {
+	client := createTestApiClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Octopus-ApiKey") != "API-TEST" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/api/Spaces-1/projects/Projects-1/releases/1.0.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		release := releases.Release{Version: "1.0.0", ProjectID: "Projects-1"}
+		release.ID = "Releases-1"
+		writeTestJson(t, w, release)
+	}))
+
+	release, err := client.getReleaseByVersion(context.Background(), "Projects-1", "1.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release == nil || release.ID != "Releases-1" {
+		t.Fatalf("Expected Releases-1, got %+v", release)
+	}
+
+	release, err = client.getReleaseByVersion(context.Background(), "Projects-1", "2.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release != nil {
+		t.Fatalf("Expected no release, got %+v", release)
+	}
+}
+
+func TestIsDeployedPagesDeployments(t *testing.T) {
+	requests := 0
+	client := createTestApiClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/Spaces-1/projects/Projects-1/releases/1.0.0":
+			release := releases.Release{Version: "1.0.0"}
+			release.ID = "Releases-1"
+			writeTestJson(t, w, release)
+		case "/api/Spaces-1/releases/Releases-1/deployments":
+			requests++
+
+			// The deployment to the environment is on the second page
+			page := Page[*deployments.Deployment]{TotalResults: 2}
+			if r.URL.Query().Get("skip") == "0" {
+				page.Items = []*deployments.Deployment{{EnvironmentID: "Environments-1"}}
+			} else {
+				page.Items = []*deployments.Deployment{{EnvironmentID: "Environments-2"}}
+			}
+			writeTestJson(t, w, page)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	project := &models.Project{ID: "Projects-1", Name: "Project 1"}
+	environment := &models.Environment{ID: "Environments-2", Name: "Test"}
+
+	deployed, err := client.IsDeployed(context.Background(), project, "1.0.0", environment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !deployed || requests != 2 {
+		t.Fatalf("Expected the release to be found deployed after 2 requests, got %v after %d", deployed, requests)
+	}
+
+	deployed, err = client.IsDeployed(context.Background(), project, "2.0.0", environment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deployed {
+		t.Fatal("Expected a missing release to not be deployed")
+	}
+}
+
+func TestGetLatestDeploymentRelease(t *testing.T) {
+	client := createTestApiClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/Spaces-1/deployments":
+			query := r.URL.Query()
+			if query.Get("projects") != "Projects-1" || query.Get("environments") != "Environments-2" || query.Get("take") != "1" {
+				t.Errorf("Unexpected deployments query %s", r.URL.RawQuery)
+			}
+
+			writeTestJson(t, w, Page[*deployments.Deployment]{
+				Items:        []*deployments.Deployment{{ReleaseID: "Releases-2"}},
+				TotalResults: 3,
+			})
+		case "/api/Spaces-1/releases/Releases-2":
+			release := releases.NewRelease("Channels-1", "Projects-1", "2.0.0")
+			release.ID = "Releases-2"
+			writeTestJson(t, w, release)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	project := &models.Project{ID: "Projects-1", Name: "Project 1"}
+	environment := &models.Environment{ID: "Environments-2", Name: "Test"}
+
+	release, err := client.GetLatestDeploymentRelease(context.Background(), project, environment)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if release == nil || release.Version != "2.0.0" {
+		t.Fatalf("Expected release 2.0.0, got %+v", release)
+	}
+}
+
+func TestOctopusResponseErrorIsPermanent(t *testing.T) {
+	client := createTestApiClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeTestJson(t, w, map[string]string{"ErrorMessage": "Invalid API key"})
+	}))
+
+	_, err := client.getReleaseByVersion(context.Background(), "Projects-1", "1.0.0")
+
+	responseError, ok := err.(*OctopusResponseError)
+	if !ok || !responseError.Permanent() || responseError.Message != "Invalid API key" {
+		t.Fatalf("Expected a permanent response error, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package octopus_apis
+
+import (
+	"context"
+	"fmt"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"net/url"
+)
+
+// DefaultPageSize is the number of items requested in each page of an Octopus collection.
+const DefaultPageSize = 100
+
+// Page is one page of the items in an Octopus collection.
+type Page[T any] struct {
+	Items        []T
+	TotalResults int
+}
+
+// PageIterator iterates over the items in an Octopus collection, requesting one page at a time so memory usage is
+// bounded by the page size. The collection is paged by the number of items actually returned, so results are
+// complete even when the server caps the page size. Each page is retried with the OctopusRead policy.
+type PageIterator[T any] struct {
+	ctx      context.Context
+	getPage  func(skip int, take int) (Page[T], error)
+	pageSize int
+	items    []T
+	index    int
+	skip     int
+	done     bool
+	item     T
+	err      error
+}
+
+// NewPageIterator creates an iterator that calls getPage with the number of items to skip and take.
+func NewPageIterator[T any](ctx context.Context, pageSize int, getPage func(skip int, take int) (Page[T], error)) *PageIterator[T] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	return &PageIterator[T]{
+		ctx:      ctx,
+		getPage:  getPage,
+		pageSize: pageSize,
+	}
+}
+
+// Next advances to the next item, returning false when there are no more items or a page could not be loaded.
+func (p *PageIterator[T]) Next() bool {
+	for p.err == nil && p.index >= len(p.items) {
+		if p.done {
+			return false
+		}
+
+		p.err = p.loadPage()
+	}
+
+	if p.err != nil {
+		return false
+	}
+
+	p.item = p.items[p.index]
+	p.index++
+
+	return true
+}
+
+// Item returns the current item.
+func (p *PageIterator[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *PageIterator[T]) Err() error {
+	return p.err
+}
+
+func (p *PageIterator[T]) loadPage() error {
+	var page Page[T]
+	err := retry_config.Do(p.ctx, retry_config.OctopusRead, func() error {
+		var err error
+		page, err = p.getPage(p.skip, p.pageSize)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	p.items = page.Items
+	p.index = 0
+	p.skip += len(page.Items)
+	p.done = len(page.Items) == 0 || p.skip >= page.TotalResults
+
+	return nil
+}
+
+// collectPages returns all the remaining items from an iterator.
+func collectPages[T any](iterator *PageIterator[T]) ([]T, error) {
+	items := []T{}
+	for iterator.Next() {
+		items = append(items, iterator.Item())
+	}
+
+	return items, iterator.Err()
+}
+
+// newApiPageIterator creates an iterator over a collection returned by the Octopus REST API.
+func newApiPageIterator[T any](ctx context.Context, api *octopusApi, path []string, query url.Values) *PageIterator[T] {
+	return NewPageIterator(ctx, DefaultPageSize, func(skip int, take int) (Page[T], error) {
+		pageQuery := url.Values{}
+		for key, values := range query {
+			pageQuery[key] = values
+		}
+		pageQuery.Set("skip", fmt.Sprint(skip))
+		pageQuery.Set("take", fmt.Sprint(take))
+
+		page := Page[T]{}
+		_, err := api.get(ctx, path, pageQuery, &page)
+		return page, err
+	})
+}
@@ -0,0 +1,91 @@
+package octopus_apis
+
+import (
+	"context"
+	"errors"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/infrastructure/retry_config"
+	"testing"
+)
+
+// cappedPages returns the numbers up to total, returning no more than maxPageSize items in a page like an Octopus
+// server that caps the page size.
+func cappedPages(total int, maxPageSize int, requests *int) func(skip int, take int) (Page[int], error) {
+	return func(skip int, take int) (Page[int], error) {
+		*requests++
+
+		if take > maxPageSize {
+			take = maxPageSize
+		}
+
+		items := []int{}
+		for i := skip; i < skip+take && i < total; i++ {
+			items = append(items, i)
+		}
+
+		return Page[int]{Items: items, TotalResults: total}, nil
+	}
+}
+
+func TestPageIteratorReturnsAllItems(t *testing.T) {
+	requests := 0
+	items, err := collectPages(NewPageIterator(context.Background(), 10, cappedPages(25, 4, &requests)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 25 || items[24] != 24 {
+		t.Fatalf("Expected 25 items, got %v", items)
+	}
+
+	if requests != 7 {
+		t.Fatalf("Expected 7 requests, got %d", requests)
+	}
+}
+
+func TestPageIteratorStopsEarly(t *testing.T) {
+	requests := 0
+	iterator := NewPageIterator(context.Background(), 5, cappedPages(100, 5, &requests))
+
+	for iterator.Next() {
+		if iterator.Item() == 7 {
+			break
+		}
+	}
+
+	if requests != 2 {
+		t.Fatalf("Expected only the pages up to the item to be requested, got %d requests", requests)
+	}
+}
+
+func TestPageIteratorEmptyCollection(t *testing.T) {
+	requests := 0
+	items, err := collectPages(NewPageIterator(context.Background(), 5, cappedPages(0, 5, &requests)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 0 || requests != 1 {
+		t.Fatalf("Expected no items from 1 request, got %v from %d requests", items, requests)
+	}
+}
+
+func TestPageIteratorError(t *testing.T) {
+	iterator := NewPageIterator(context.Background(), 5, func(skip int, take int) (Page[int], error) {
+		if skip != 0 {
+			return Page[int]{}, retry_config.Permanent(errors.New("page failed"))
+		}
+
+		return Page[int]{Items: []int{1, 2, 3, 4, 5}, TotalResults: 10}, nil
+	})
+
+	count := 0
+	for iterator.Next() {
+		count++
+	}
+
+	if count != 5 || iterator.Err() == nil {
+		t.Fatalf("Expected the first page and then an error, got %d items and %v", count, iterator.Err())
+	}
+}
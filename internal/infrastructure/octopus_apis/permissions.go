@@ -0,0 +1,104 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/apperrors"
+	"github.com/samber/lo"
+)
+
+// RequiredPermissions are the space permissions the API key needs to read the mappings and create releases and
+// deployments.
+var RequiredPermissions = []string{
+	"ProjectView",
+	"ProcessView",
+	"VariableView",
+	"EnvironmentView",
+	"LifecycleView",
+	"FeedView",
+	"ReleaseView",
+	"ReleaseCreate",
+	"DeploymentView",
+	"DeploymentCreate",
+}
+
+// userPermissions is the subset of the Octopus UserPermissionSetResource read by the proxy.
+type userPermissions struct {
+	SpacePermissions map[string][]permissionRestriction
+}
+
+// space is the subset of the Octopus SpaceResource read by the proxy.
+type space struct {
+	Id        string
+	IsDefault bool
+}
+
+// permissionRestriction is the space a permission is granted in.
+type permissionRestriction struct {
+	SpaceId string
+}
+
+// CheckPermissions returns the required permissions that the API key's user has not been granted in the space.
+// Permissions restricted to some projects or environments are treated as granted. When OCTOPUS_SPACE_ID is not set,
+// the permissions are checked in the default space.
+func (o *LiveOctopusClient) CheckPermissions(ctx context.Context) (_ []string, octopusErr error) {
+	defer func() {
+		octopusErr = o.classifyError(octopusErr)
+	}()
+
+	user := struct{ Id string }{}
+	found, err := o.api.getSystem(ctx, []string{"users", "me"}, nil, &user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, apperrors.New(apperrors.CodeOctopusUnauthorized, "Octopus did not return the user for the API key")
+	}
+
+	permissions := userPermissions{}
+	found, err = o.api.getSystem(ctx, []string{"users", user.Id, "permissions"}, nil, &permissions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, apperrors.New(apperrors.CodeOctopusUnauthorized, "Octopus did not return the permissions of "+user.Id)
+	}
+
+	spaceId := o.api.spaceId
+	if spaceId == "" {
+		spaceId, err = o.getDefaultSpaceId(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return lo.Filter(RequiredPermissions, func(item string, index int) bool {
+		return !lo.ContainsBy(permissions.SpacePermissions[item], func(restriction permissionRestriction) bool {
+			return restriction.SpaceId == spaceId
+		})
+	}), nil
+}
+
+// getDefaultSpaceId returns the ID of the space used when OCTOPUS_SPACE_ID is not set.
+func (o *LiveOctopusClient) getDefaultSpaceId(ctx context.Context) (string, error) {
+	spaces := []space{}
+	_, err := o.api.getSystem(ctx, []string{"spaces", "all"}, nil, &spaces)
+
+	if err != nil {
+		return "", err
+	}
+
+	defaultSpace, found := lo.Find(spaces, func(item space) bool {
+		return item.IsDefault
+	})
+
+	if !found {
+		return "", apperrors.New(apperrors.CodeOctopusSpaceNotFound, "Octopus does not have a default space")
+	}
+
+	return defaultSpace.Id, nil
+}
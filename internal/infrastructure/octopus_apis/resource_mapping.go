@@ -0,0 +1,81 @@
+package octopus_apis
+
+import (
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/channels"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/environments"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/projects"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/releases"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/variables"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/samber/lo"
+)
+
+// The functions below map the resources returned by the Octopus client library to the models used by the rest of
+// the proxy. Only this package depends on the client library.
+
+func toProject(project *projects.Project) *models.Project {
+	return &models.Project{
+		ID:                  project.GetID(),
+		Name:                project.Name,
+		LifecycleID:         project.LifecycleID,
+		DeploymentProcessID: project.DeploymentProcessID,
+	}
+}
+
+func toEnvironment(environment *environments.Environment) *models.Environment {
+	return &models.Environment{
+		ID:   environment.GetID(),
+		Name: environment.Name,
+	}
+}
+
+func toChannel(channel *channels.Channel) *models.Channel {
+	return &models.Channel{
+		ID:          channel.GetID(),
+		Name:        channel.Name,
+		ProjectID:   channel.ProjectID,
+		LifecycleID: channel.LifecycleID,
+		IsDefault:   channel.IsDefault,
+	}
+}
+
+func toLifecycle(lifecycle *lifecycles.Lifecycle) *models.Lifecycle {
+	return &models.Lifecycle{
+		ID:   lifecycle.GetID(),
+		Name: lifecycle.Name,
+		Phases: lo.FilterMap(lifecycle.Phases, func(item *lifecycles.Phase, index int) (models.Phase, bool) {
+			if item == nil {
+				return models.Phase{}, false
+			}
+
+			return models.Phase{
+				Name:                       item.Name,
+				AutomaticDeploymentTargets: item.AutomaticDeploymentTargets,
+				OptionalDeploymentTargets:  item.OptionalDeploymentTargets,
+			}, true
+		}),
+	}
+}
+
+func toRelease(release *releases.Release) *models.Release {
+	return &models.Release{
+		ID:        release.GetID(),
+		ProjectID: release.ProjectID,
+		ChannelID: release.ChannelID,
+		Version:   release.Version,
+		Assembled: release.Assembled,
+	}
+}
+
+func toVariableSet(variableSet variables.VariableSet) *models.VariableSet {
+	return &models.VariableSet{
+		Variables: lo.FilterMap(variableSet.Variables, func(item *variables.Variable, index int) (models.Variable, bool) {
+			if item == nil {
+				return models.Variable{}, false
+			}
+
+			return models.Variable{Name: item.Name, Value: item.Value}, true
+		}),
+	}
+}
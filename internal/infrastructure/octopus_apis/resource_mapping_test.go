@@ -0,0 +1,82 @@
+package octopus_apis
+
+import (
+	"context"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/lifecycles"
+	"github.com/OctopusDeploy/go-octopusdeploy/v2/pkg/variables"
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+	"github.com/allegro/bigcache/v3"
+	"testing"
+	"time"
+)
+
+func TestToLifecycle(t *testing.T) {
+	lifecycle := lifecycles.NewLifecycle("Default")
+	lifecycle.ID = "Lifecycles-1"
+	lifecycle.Phases = []*lifecycles.Phase{
+		{Name: "Development", AutomaticDeploymentTargets: []string{"Environments-1"}},
+		nil,
+		{Name: "Production", OptionalDeploymentTargets: []string{"Environments-2"}},
+	}
+
+	mapped := toLifecycle(lifecycle)
+
+	if mapped.ID != "Lifecycles-1" || mapped.Name != "Default" || len(mapped.Phases) != 2 ||
+		mapped.Phases[0].AutomaticDeploymentTargets[0] != "Environments-1" ||
+		mapped.Phases[1].OptionalDeploymentTargets[0] != "Environments-2" {
+		t.Fatalf("Unexpected lifecycle %+v", mapped)
+	}
+}
+
+func TestToVariableSet(t *testing.T) {
+	variableSet := variables.VariableSet{
+		Variables: []*variables.Variable{
+			{Name: "Metadata.ArgoCD.Application[argocd/myapp].Environment", Value: "Development"},
+			nil,
+		},
+	}
+
+	mapped := toVariableSet(variableSet)
+
+	if len(mapped.Variables) != 1 || mapped.Variables[0].Value != "Development" {
+		t.Fatalf("Unexpected variables %+v", mapped.Variables)
+	}
+}
+
+func TestGetChannelLifecycleUsesProjectLifecycle(t *testing.T) {
+	bCache, err := bigcache.New(context.Background(), bigcache.DefaultConfig(time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, name := range map[string]string{"Lifecycles-1": "Project Lifecycle", "Lifecycles-2": "Channel Lifecycle"} {
+		if err := bCache.Set(id, []byte(`{"ID":"`+id+`","Name":"`+name+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := &LiveOctopusClient{bigCache: bCache}
+	project := &models.Project{ID: "Projects-1", LifecycleID: "Lifecycles-1"}
+
+	// Channels without a lifecycle inherit the project's lifecycle
+	lifecycle, err := client.getChannelLifecycle(context.Background(), project, &models.Channel{Name: "Default"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lifecycle.Name != "Project Lifecycle" {
+		t.Fatalf("Expected the project lifecycle, got %+v", lifecycle)
+	}
+
+	lifecycle, err = client.getChannelLifecycle(context.Background(), project, &models.Channel{Name: "Hotfix", LifecycleID: "Lifecycles-2"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lifecycle.Name != "Channel Lifecycle" {
+		t.Fatalf("Expected the channel lifecycle, got %+v", lifecycle)
+	}
+}
@@ -0,0 +1,28 @@
+package octopus_apis
+
+import (
+	"github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy/internal/domain/models"
+)
+
+// GetStats reports the cache hit rate, the size of the mapping index, and whether requests to Octopus are paused.
+func (o *LiveOctopusClient) GetStats() models.OctopusClientStats {
+	cacheStats := o.bigCache.Stats()
+	loaded, projects, lastRefresh, lastFullRefresh := o.index.stats()
+
+	stats := models.OctopusClientStats{
+		CacheEntries:         o.bigCache.Len(),
+		CacheHits:            cacheStats.Hits,
+		CacheMisses:          cacheStats.Misses,
+		IndexLoaded:          loaded,
+		IndexedProjects:      projects,
+		IndexLastRefresh:     lastRefresh,
+		IndexLastFullRefresh: lastFullRefresh,
+	}
+
+	if until, open := o.transport.CircuitOpenUntil(); open {
+		stats.CircuitOpen = true
+		stats.CircuitOpenUntil = &until
+	}
+
+	return stats
+}
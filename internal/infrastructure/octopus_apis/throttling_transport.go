@@ -0,0 +1,205 @@
+package octopus_apis
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/time/rate"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultRateLimit is the default number of requests per second sent to Octopus.
+const DefaultRateLimit = 10
+
+// DefaultRateBurst is the default number of requests that can be sent to Octopus in a burst.
+const DefaultRateBurst = 20
+
+// DefaultCircuitBreakerThreshold is the default number of consecutive failures that open the circuit breaker.
+const DefaultCircuitBreakerThreshold = 5
+
+// DefaultCircuitBreakerCooldown is the default time the circuit breaker stays open.
+const DefaultCircuitBreakerCooldown = time.Minute
+
+// ThrottlingTransport is a http.RoundTripper that applies a token bucket rate limit to requests sent to Octopus,
+// and stops sending requests when Octopus is unavailable or responds with a 429 or 503 status code. This prevents
+// a large number of ArgoCD syncs from overwhelming Octopus, and ensures all requests back off while Octopus is in
+// a maintenance window.
+type ThrottlingTransport struct {
+	transport http.RoundTripper
+	limiter   *rate.Limiter
+	breaker   *CircuitBreaker
+}
+
+func NewThrottlingTransport(transport http.RoundTripper, limiter *rate.Limiter, breaker *CircuitBreaker) *ThrottlingTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &ThrottlingTransport{
+		transport: transport,
+		limiter:   limiter,
+		breaker:   breaker,
+	}
+}
+
+// NewDefaultThrottlingTransport creates a ThrottlingTransport configured with the OCTOPUS_RATE_LIMIT,
+// OCTOPUS_RATE_BURST, OCTOPUS_CIRCUIT_BREAKER_THRESHOLD, and OCTOPUS_CIRCUIT_BREAKER_COOLDOWN environment variables.
+func NewDefaultThrottlingTransport() (*ThrottlingTransport, error) {
+	rateLimit, err := getFloatEnv("OCTOPUS_RATE_LIMIT", DefaultRateLimit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rateBurst, err := getIntEnv("OCTOPUS_RATE_BURST", DefaultRateBurst)
+
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := getIntEnv("OCTOPUS_CIRCUIT_BREAKER_THRESHOLD", DefaultCircuitBreakerThreshold)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cooldown := DefaultCircuitBreakerCooldown
+	if os.Getenv("OCTOPUS_CIRCUIT_BREAKER_COOLDOWN") != "" {
+		cooldown, err = time.ParseDuration(os.Getenv("OCTOPUS_CIRCUIT_BREAKER_COOLDOWN"))
+
+		if err != nil {
+			return nil, errors.New("octoargosync-init-octoclienterror - OCTOPUS_CIRCUIT_BREAKER_COOLDOWN must be a duration like 1m: " + err.Error())
+		}
+	}
+
+	return NewThrottlingTransport(
+		nil,
+		rate.NewLimiter(rate.Limit(rateLimit), rateBurst),
+		NewCircuitBreaker(threshold, cooldown)), nil
+}
+
+func (t *ThrottlingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		// The request was never sent, so it does not count as a failure
+		t.breaker.Release()
+		return nil, err
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+
+	if err != nil {
+		// A cancelled request says nothing about the health of Octopus
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			t.breaker.Release()
+		} else {
+			t.breaker.Failure()
+		}
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		t.breaker.Trip(parseRetryAfter(resp.Header.Get("Retry-After")))
+	case resp.StatusCode >= 500:
+		t.breaker.Failure()
+	default:
+		t.breaker.Success()
+	}
+
+	return resp, nil
+}
+
+// WrapError returns a CircuitOpenError wrapping the supplied error if requests to Octopus are currently paused.
+// The Octopus client libraries do not preserve the errors returned by the transport, so this allows callers to
+// know how long they should wait before trying again. Errors for responses Octopus rejected, like a 401 or 404, are
+// returned unchanged, as retrying them once the circuit closes will not change the result.
+func (t *ThrottlingTransport) WrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var circuitOpenError *CircuitOpenError
+	if errors.As(err, &circuitOpenError) {
+		return err
+	}
+
+	if statusCode, found := getStatusCode(err); found && isPermanentStatus(statusCode) {
+		return err
+	}
+
+	if until, open := t.breaker.OpenUntil(); open {
+		return &CircuitOpenError{Until: until, Err: err}
+	}
+
+	return err
+}
+
+// CircuitOpenUntil returns the time requests to Octopus are paused until, and false if requests are allowed.
+func (t *ThrottlingTransport) CircuitOpenUntil() (time.Time, bool) {
+	return t.breaker.OpenUntil()
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or a HTTP date.
+func parseRetryAfter(retryAfter string) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+func getFloatEnv(name string, defaultValue float64) (float64, error) {
+	if os.Getenv(name) == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+
+	if err != nil || value <= 0 {
+		return 0, errors.New("octoargosync-init-octoclienterror - " + name + " must be a positive number")
+	}
+
+	return value, nil
+}
+
+func getIntEnv(name string, defaultValue int) (int, error) {
+	if os.Getenv(name) == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(os.Getenv(name))
+
+	if err != nil || value < 1 {
+		return 0, errors.New("octoargosync-init-octoclienterror - " + name + " must be a positive integer")
+	}
+
+	return value, nil
+}
+
+func getDurationEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	if os.Getenv(name) == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(os.Getenv(name))
+
+	if err != nil || value < 0 {
+		return 0, errors.New("octoargosync-init-octoclienterror - " + name + " must be a positive duration like 1m")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,189 @@
+package octopus_apis
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterOpensCircuit(t *testing.T) {
+	requests := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), NewCircuitBreaker(5, time.Second)),
+	}
+
+	resp, err := client.Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	_, err = client.Get(server.URL)
+
+	var circuitOpenError *CircuitOpenError
+	if !errors.As(err, &circuitOpenError) {
+		t.Fatal("must not send requests after Octopus responds with a 503")
+	}
+
+	if circuitOpenError.RetryAfter() < time.Minute {
+		t.Fatal("must honour the Retry-After header")
+	}
+
+	if requests.Load() != 1 {
+		t.Fatal("must have sent one request")
+	}
+}
+
+func TestCircuitClosesAfterCooldown(t *testing.T) {
+	fail := atomic.Bool{}
+	fail.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	transport := NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), NewCircuitBreaker(2, 100*time.Millisecond))
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+	}
+
+	var circuitOpenError *CircuitOpenError
+	if !errors.As(transport.WrapError(errors.New("failed")), &circuitOpenError) {
+		t.Fatal("must open the circuit after consecutive failures")
+	}
+
+	fail.Store(false)
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err := client.Get(server.URL)
+
+	if err != nil {
+		t.Fatal("must allow requests after the cooldown")
+	}
+
+	resp.Body.Close()
+
+	if errors.As(transport.WrapError(errors.New("failed")), &circuitOpenError) {
+		t.Fatal("must close the circuit after a successful request")
+	}
+}
+
+func TestPermanentErrorsAreNotWrappedWhileCircuitIsOpen(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Minute)
+	transport := NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), breaker)
+	breaker.Failure()
+
+	var circuitOpenError *CircuitOpenError
+	for _, statusCode := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound} {
+		err := transport.WrapError(&OctopusResponseError{StatusCode: statusCode, Path: "api/projects"})
+
+		if errors.As(err, &circuitOpenError) {
+			t.Fatalf("must not wrap a %d response while the circuit is open", statusCode)
+		}
+	}
+
+	for _, statusCode := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		err := transport.WrapError(&OctopusResponseError{StatusCode: statusCode, Path: "api/projects"})
+
+		if !errors.As(err, &circuitOpenError) {
+			t.Fatalf("must wrap a %d response while the circuit is open", statusCode)
+		}
+	}
+
+	if !errors.As(transport.WrapError(errors.New("connection refused")), &circuitOpenError) {
+		t.Fatal("must wrap transport errors while the circuit is open")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: NewThrottlingTransport(nil, rate.NewLimiter(rate.Every(100*time.Millisecond), 1), NewCircuitBreaker(5, time.Second)),
+	}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(server.URL)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+	}
+
+	if time.Since(start) < 300*time.Millisecond {
+		t.Fatal("must limit the rate of requests")
+	}
+}
+
+func TestCancelledRequestsDoNotOpenCircuit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	transport := NewThrottlingTransport(nil, rate.NewLimiter(rate.Inf, 1), NewCircuitBreaker(1, time.Minute))
+	client := &http.Client{Transport: transport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Expected the request to time out")
+	}
+
+	if _, open := transport.CircuitOpenUntil(); open {
+		t.Fatal("must not count a cancelled request as a failure")
+	}
+}
+
+func TestRateLimitMustBePositive(t *testing.T) {
+	for _, name := range []string{"OCTOPUS_RATE_LIMIT", "OCTOPUS_RATE_BURST"} {
+		for _, value := range []string{"0", "-1"} {
+			t.Setenv(name, value)
+
+			if _, err := NewDefaultThrottlingTransport(); err == nil {
+				t.Fatalf("Expected %s=%s to be rejected", name, value)
+			}
+		}
+
+		t.Setenv(name, "")
+	}
+}
@@ -0,0 +1,223 @@
+package retry_config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FixedBackoff waits the same delay between each attempt
+	FixedBackoff = "fixed"
+	// ExponentialBackoff doubles the delay after each attempt
+	ExponentialBackoff = "exponential"
+	// ScheduleBackoff waits for the delays listed in the schedule
+	ScheduleBackoff = "schedule"
+)
+
+// Policy defines how an operation is retried.
+type Policy struct {
+	// Attempts is the maximum number of times the operation is attempted
+	Attempts uint
+	// Backoff is one of fixed, exponential, or schedule
+	Backoff string
+	// Delay is the delay between attempts for the fixed backoff, and the initial delay for the exponential backoff
+	Delay Duration
+	// MaxDelay caps the delay between attempts
+	MaxDelay Duration
+	// Jitter is the maximum random duration added to each delay
+	Jitter Duration
+	// MaxElapsed stops retrying once this much time has passed since the first attempt
+	MaxElapsed Duration
+	// Schedule lists the delays after each failed attempt for the schedule backoff
+	Schedule []Duration
+	// NonRetryableErrors is a regular expression matching error messages that must fail fast
+	NonRetryableErrors string
+	// nonRetryable is the compiled NonRetryableErrors expression
+	nonRetryable *regexp.Regexp
+}
+
+// Duration is a time.Duration that is serialized to JSON as a string like "5m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+
+	if err != nil {
+		return errors.New("durations must be a string like \"5m\"")
+	}
+
+	duration, err := time.ParseDuration(value)
+
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// LoadPolicies loads the retry policies from the JSON file defined in the RETRY_CONFIG_FILE environment variable,
+// and then applies any environment variables like RETRY_OCTOPUS_READ_ATTEMPTS. The file maps the operation class
+// (ArgoRead, OctopusRead, OctopusWrite, or Handler) to a policy, and any settings that are not defined keep their
+// default values.
+func LoadPolicies() error {
+	loadedPolicies := DefaultPolicies()
+
+	if os.Getenv("RETRY_CONFIG_FILE") != "" {
+		configData, err := os.ReadFile(os.Getenv("RETRY_CONFIG_FILE"))
+
+		if err != nil {
+			return errors.New("octoargosync-init-retryconfigerror - failed to read RETRY_CONFIG_FILE: " + err.Error())
+		}
+
+		configPolicies := map[OperationClass]json.RawMessage{}
+		err = json.Unmarshal(configData, &configPolicies)
+
+		if err != nil {
+			return errors.New("octoargosync-init-retryconfigerror - failed to parse RETRY_CONFIG_FILE: " + err.Error())
+		}
+
+		for class, configPolicy := range configPolicies {
+			policy, exists := loadedPolicies[class]
+
+			if !exists {
+				return errors.New("octoargosync-init-retryconfigerror - RETRY_CONFIG_FILE defines the unknown operation class " + string(class))
+			}
+
+			// Unmarshalling over the default policy means the file only needs to define the settings it changes
+			err = json.Unmarshal(configPolicy, &policy)
+
+			if err != nil {
+				return errors.New("octoargosync-init-retryconfigerror - failed to parse the " + string(class) + " policy in RETRY_CONFIG_FILE: " + err.Error())
+			}
+
+			loadedPolicies[class] = policy
+		}
+	}
+
+	for _, class := range OperationClasses {
+		policy, err := applyEnvironment(class, loadedPolicies[class])
+
+		if err != nil {
+			return errors.New("octoargosync-init-retryconfigerror - " + err.Error())
+		}
+
+		policy, err = policy.compile()
+
+		if err != nil {
+			return errors.New("octoargosync-init-retryconfigerror - the retry policy for " + string(class) + " is invalid: " + err.Error())
+		}
+
+		loadedPolicies[class] = policy
+	}
+
+	SetPolicies(loadedPolicies)
+
+	return nil
+}
+
+// compile validates the policy and returns a copy with the NonRetryableErrors expression compiled, so it is not
+// compiled again for every failed attempt.
+func (p Policy) compile() (Policy, error) {
+	if p.Backoff != "" && p.Backoff != FixedBackoff && p.Backoff != ExponentialBackoff && p.Backoff != ScheduleBackoff {
+		return p, errors.New("the backoff must be one of fixed, exponential, or schedule")
+	}
+
+	if p.Backoff == ScheduleBackoff && len(p.Schedule) == 0 {
+		return p, errors.New("the schedule backoff requires a schedule")
+	}
+
+	p.nonRetryable = nil
+	if p.NonRetryableErrors != "" {
+		nonRetryable, err := regexp.Compile(p.NonRetryableErrors)
+
+		if err != nil {
+			return p, errors.New("the non-retryable errors must be a regular expression: " + err.Error())
+		}
+
+		p.nonRetryable = nonRetryable
+	}
+
+	return p, nil
+}
+
+// applyEnvironment overrides the policy settings with environment variables whose names are built from the
+// operation class, like RETRY_OCTOPUS_READ_ATTEMPTS or RETRY_HANDLER_SCHEDULE.
+func applyEnvironment(class OperationClass, policy Policy) (Policy, error) {
+	prefix := "RETRY_" + environmentName(class) + "_"
+
+	if value := os.Getenv(prefix + "ATTEMPTS"); value != "" {
+		attempts, err := strconv.ParseUint(value, 10, 32)
+
+		if err != nil {
+			return policy, errors.New(prefix + "ATTEMPTS must be a positive number")
+		}
+
+		policy.Attempts = uint(attempts)
+	}
+
+	if value := os.Getenv(prefix + "BACKOFF"); value != "" {
+		policy.Backoff = strings.ToLower(value)
+	}
+
+	durations := map[string]*Duration{
+		"DELAY":       &policy.Delay,
+		"MAX_DELAY":   &policy.MaxDelay,
+		"JITTER":      &policy.Jitter,
+		"MAX_ELAPSED": &policy.MaxElapsed,
+	}
+
+	for name, duration := range durations {
+		if value := os.Getenv(prefix + name); value != "" {
+			parsed, err := time.ParseDuration(value)
+
+			if err != nil {
+				return policy, errors.New(prefix + name + " must be a duration like 5m")
+			}
+
+			*duration = Duration(parsed)
+		}
+	}
+
+	if value := os.Getenv(prefix + "SCHEDULE"); value != "" {
+		policy.Schedule = []Duration{}
+		for _, item := range strings.Split(value, ",") {
+			parsed, err := time.ParseDuration(strings.TrimSpace(item))
+
+			if err != nil {
+				return policy, errors.New(prefix + "SCHEDULE must be a comma separated list of durations like 1m,5m,10m")
+			}
+
+			policy.Schedule = append(policy.Schedule, Duration(parsed))
+		}
+	}
+
+	if value := os.Getenv(prefix + "NON_RETRYABLE_ERRORS"); value != "" {
+		policy.NonRetryableErrors = value
+	}
+
+	return policy, nil
+}
+
+// environmentName converts an operation class like OctopusRead to OCTOPUS_READ.
+func environmentName(class OperationClass) string {
+	name := ""
+	for i, r := range string(class) {
+		if i != 0 && r >= 'A' && r <= 'Z' {
+			name += "_"
+		}
+		name += string(r)
+	}
+
+	return strings.ToUpper(name)
+}
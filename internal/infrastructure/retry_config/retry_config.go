@@ -1,44 +1,320 @@
 package retry_config
 
 import (
+	"context"
+	"errors"
 	"github.com/avast/retry-go"
+	"regexp"
+	"sync/atomic"
 	"time"
 )
 
-var RetryOptions = []retry.Option{
-	retry.Delay(3 * time.Second),
-	retry.Attempts(2),
+// OperationClass groups operations that share a retry policy.
+type OperationClass string
+
+const (
+	// ArgoRead is the class of operations that read from ArgoCD
+	ArgoRead OperationClass = "ArgoRead"
+	// OctopusRead is the class of operations that read from Octopus
+	OctopusRead OperationClass = "OctopusRead"
+	// OctopusWrite is the class of operations that create resources in Octopus
+	OctopusWrite OperationClass = "OctopusWrite"
+	// Handler is the class of the operation that creates and deploys a release in response to an ArgoCD notification
+	Handler OperationClass = "Handler"
+)
+
+// OperationClasses lists all the operation classes.
+var OperationClasses = []OperationClass{ArgoRead, OctopusRead, OctopusWrite, Handler}
+
+// RetryAfterError is implemented by errors that indicate how long to wait before an operation can be retried,
+// for example when Octopus is in a maintenance window.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
 }
 
-// HandlerRetryOptions represents a retry loop that will attempt to create a release for up to two hours, which
-// is the standard maintenance window for cloud hosted Octopus instances.
-var HandlerRetryOptions = []retry.Option{
-	retry.Attempts(6),
-	retry.DelayType(func(n uint, _ error, config *retry.Config) time.Duration {
-		if n == 0 {
-			return 0
-		}
+// PermanentError wraps an error that will not be resolved by retrying the operation, like a misconfigured
+// environment name, so the operation fails fast.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
 
-		if n == 1 {
-			return 1 * time.Minute
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks an error as one that must not be retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentError{Err: err}
+}
+
+// ClassifiedError is implemented by errors that know whether retrying the operation can resolve them.
+type ClassifiedError interface {
+	error
+	Permanent() bool
+}
+
+// IsPermanent returns true if the error, or any error it wraps, was marked as permanent.
+func IsPermanent(err error) bool {
+	return findError(err, func(err error) bool {
+		var permanentError *PermanentError
+		if errors.As(err, &permanentError) {
+			return true
 		}
 
-		if n == 2 {
-			return 5 * time.Minute
+		var classifiedError ClassifiedError
+		return errors.As(err, &classifiedError) && classifiedError.Permanent()
+	})
+}
+
+// policies holds the retry policy for each operation class.
+var policies atomic.Pointer[map[OperationClass]Policy]
+
+func init() {
+	defaultPolicies := DefaultPolicies()
+	policies.Store(&defaultPolicies)
+}
+
+// DefaultPolicies returns the retry policies used when no configuration is supplied.
+func DefaultPolicies() map[OperationClass]Policy {
+	return map[OperationClass]Policy{
+		ArgoRead: {
+			Attempts: 2,
+			Backoff:  FixedBackoff,
+			Delay:    Duration(3 * time.Second),
+		},
+		OctopusRead: {
+			Attempts: 2,
+			Backoff:  FixedBackoff,
+			Delay:    Duration(3 * time.Second),
+		},
+		// Writes are not retried by default, as a failed response does not mean the resource was not created. The
+		// next handler attempt finds any release that was created and reuses it.
+		OctopusWrite: {
+			Attempts: 1,
+			Backoff:  FixedBackoff,
+		},
+		// The handler will attempt to create a release for up to two hours, which is the standard maintenance
+		// window for cloud hosted Octopus instances. Each entry in the schedule is the delay after a failed attempt.
+		Handler: {
+			Attempts: 7,
+			Backoff:  ScheduleBackoff,
+			Schedule: []Duration{
+				Duration(1 * time.Minute),
+				Duration(5 * time.Minute),
+				Duration(10 * time.Minute),
+				Duration(15 * time.Minute),
+				Duration(30 * time.Minute),
+				Duration(60 * time.Minute),
+			},
+		},
+	}
+}
+
+// GetPolicy returns the retry policy for an operation class.
+func GetPolicy(class OperationClass) Policy {
+	return (*policies.Load())[class]
+}
+
+// SetPolicies replaces the retry policies. Any operation class missing from the supplied map uses the default policy.
+// SetPolicies panics if a NonRetryableErrors expression is invalid, so policies that are not defined in code must be
+// loaded with LoadPolicies, which rejects them at startup.
+func SetPolicies(newPolicies map[OperationClass]Policy) {
+	merged := DefaultPolicies()
+	for class, policy := range newPolicies {
+		if policy.NonRetryableErrors != "" && policy.nonRetryable == nil {
+			policy.nonRetryable = regexp.MustCompile(policy.NonRetryableErrors)
 		}
 
-		if n == 3 {
-			return 10 * time.Minute
+		merged[class] = policy
+	}
+
+	policies.Store(&merged)
+}
+
+// Do calls the function until it succeeds, or the retry policy for the operation class says to stop.
+func Do(ctx context.Context, class OperationClass, retryableFunc retry.RetryableFunc) error {
+	return retry.Do(retryableFunc, GetPolicy(class).options(ctx, class)...)
+}
+
+// Retrier decides when an operation is attempted again without blocking between attempts. It is used by long
+// running retries, like the Handler policy, which must not hold a worker while waiting for the next attempt.
+type Retrier struct {
+	class   OperationClass
+	policy  Policy
+	config  *retry.Config
+	start   time.Time
+	attempt uint
+}
+
+// NewRetrier creates a Retrier using the retry policy for the operation class.
+func NewRetrier(class OperationClass) *Retrier {
+	policy := GetPolicy(class)
+	config := &retry.Config{}
+	for _, option := range policy.options(context.Background(), class) {
+		option(config)
+	}
+
+	return &Retrier{
+		class:  class,
+		policy: policy,
+		config: config,
+		start:  time.Now(),
+	}
+}
+
+// Attempts returns the number of failed attempts that have been recorded.
+func (r *Retrier) Attempts() int {
+	return int(r.attempt)
+}
+
+// Next records a failed attempt, returning how long to wait before the operation is attempted again, or false if
+// the policy says to stop. The delays are the same as the delays used by Do.
+func (r *Retrier) Next(err error) (time.Duration, bool) {
+	r.attempt++
+
+	if r.attempt >= r.policy.getAttempts() || !r.policy.retryIf(err, r.class, r.start) {
+		return 0, false
+	}
+
+	delay := r.policy.delayType(r.attempt-1, err, r.config)
+	if r.policy.MaxDelay != 0 && delay > time.Duration(r.policy.MaxDelay) {
+		delay = time.Duration(r.policy.MaxDelay)
+	}
+
+	return delay, true
+}
+
+func (p Policy) options(ctx context.Context, class OperationClass) []retry.Option {
+	start := time.Now()
+
+	return []retry.Option{
+		retry.Context(ctx),
+		retry.Attempts(p.getAttempts()),
+		retry.Delay(time.Duration(p.Delay)),
+		retry.MaxDelay(time.Duration(p.MaxDelay)),
+		retry.MaxJitter(time.Duration(p.Jitter)),
+		retry.LastErrorOnly(true),
+		retry.DelayType(p.delayType),
+		retry.RetryIf(func(err error) bool {
+			return p.retryIf(err, class, start)
+		}),
+	}
+}
+
+// delayType returns the delay after the failed attempt n, waiting at least as long as the error asks us to wait.
+func (p Policy) delayType(n uint, err error, config *retry.Config) time.Duration {
+	delay := p.delay(n, err, config)
+
+	if retryAfter, found := GetRetryAfter(err); found && retryAfter > delay {
+		return retryAfter
+	}
+
+	return delay
+}
+
+// retryIf returns true if the operation that started at the supplied time can be retried after the error.
+func (p Policy) retryIf(err error, class OperationClass, start time.Time) bool {
+	if IsPermanent(err) {
+		return false
+	}
+
+	if p.nonRetryable != nil && p.nonRetryable.MatchString(err.Error()) {
+		return false
+	}
+
+	if p.MaxElapsed != 0 && time.Since(start) >= time.Duration(p.MaxElapsed) {
+		return false
+	}
+
+	// There is no point quickly retrying an operation when we have been asked to wait, so only the
+	// long running handler retries these errors
+	if _, found := GetRetryAfter(err); found && class != Handler {
+		return false
+	}
+
+	return true
+}
+
+func (p Policy) getAttempts() uint {
+	if p.Attempts == 0 {
+		return 1
+	}
+
+	return p.Attempts
+}
+
+func (p Policy) delay(n uint, err error, config *retry.Config) time.Duration {
+	var delay time.Duration
+
+	switch p.Backoff {
+	case ExponentialBackoff:
+		delay = retry.BackOffDelay(n, err, config)
+	case ScheduleBackoff:
+		if len(p.Schedule) != 0 {
+			// The delay after the first failed attempt is the first entry in the schedule, and the last entry is
+			// used for any attempts beyond the end of the schedule
+			index := int(n)
+			if index >= len(p.Schedule) {
+				index = len(p.Schedule) - 1
+			}
+			delay = time.Duration(p.Schedule[index])
 		}
+	default:
+		delay = retry.FixedDelay(n, err, config)
+	}
 
-		if n == 4 {
-			return 15 * time.Minute
+	if p.Jitter != 0 {
+		delay += retry.RandomDelay(n, err, config)
+	}
+
+	return delay
+}
+
+// GetRetryAfter finds a RetryAfterError in the error chain, including the errors collected by retry.Do, and returns
+// how long to wait before retrying.
+func GetRetryAfter(err error) (time.Duration, bool) {
+	var retryAfter time.Duration
+	found := findError(err, func(err error) bool {
+		var retryAfterError RetryAfterError
+		if errors.As(err, &retryAfterError) {
+			retryAfter = retryAfterError.RetryAfter()
+			return true
 		}
 
-		if n == 5 {
-			return 30 * time.Minute
+		return false
+	})
+
+	return retryAfter, found
+}
+
+// findError applies the matcher to the error, and to the errors held by any retry.Error, as retry.Error does not
+// implement Unwrap.
+func findError(err error, matcher func(err error) bool) bool {
+	if err == nil {
+		return false
+	}
+
+	if matcher(err) {
+		return true
+	}
+
+	var retryError retry.Error
+	if errors.As(err, &retryError) {
+		for _, wrappedErr := range retryError.WrappedErrors() {
+			if findError(wrappedErr, matcher) {
+				return true
+			}
 		}
+	}
 
-		return 60 * time.Minute
-	}),
+	return false
 }
@@ -0,0 +1,217 @@
+package retry_config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleBackoff(t *testing.T) {
+	policy := Policy{
+		Attempts: 4,
+		Backoff:  ScheduleBackoff,
+		Schedule: []Duration{0, Duration(time.Minute), Duration(5 * time.Minute)},
+	}
+
+	expected := []time.Duration{0, time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for n, delay := range expected {
+		if actual := policy.delay(uint(n), nil, nil); actual != delay {
+			t.Fatalf("Expected delay %v for attempt %d, got %v", delay, n, actual)
+		}
+	}
+}
+
+func TestDefaultHandlerPolicyUsesSchedule(t *testing.T) {
+	retrier := NewRetrier(Handler)
+
+	var total time.Duration
+	for {
+		delay, retry := retrier.Next(errors.New("connection refused"))
+
+		if !retry {
+			break
+		}
+
+		total += delay
+	}
+
+	// Every entry in the schedule is used, covering the two hour maintenance window
+	if total < 2*time.Hour {
+		t.Fatalf("Expected the handler to retry for at least two hours, got %v", total)
+	}
+}
+
+func TestOctopusWritesAreNotRetried(t *testing.T) {
+	attempts := 0
+	_ = Do(context.Background(), OctopusWrite, func() error {
+		attempts++
+		return errors.New("context deadline exceeded")
+	})
+
+	// A write that timed out may still have created the deployment, so retrying it could create a duplicate
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestRetrier(t *testing.T) {
+	SetPolicies(map[OperationClass]Policy{Handler: {
+		Attempts: 3,
+		Backoff:  ScheduleBackoff,
+		Schedule: []Duration{Duration(time.Minute), Duration(5 * time.Minute)},
+	}})
+	defer SetPolicies(nil)
+
+	retrier := NewRetrier(Handler)
+
+	for _, expected := range []time.Duration{time.Minute, 5 * time.Minute} {
+		delay, retry := retrier.Next(errors.New("connection refused"))
+
+		if !retry || delay != expected {
+			t.Fatalf("Expected a retry after %v, got %v", expected, delay)
+		}
+	}
+
+	if _, retry := retrier.Next(errors.New("connection refused")); retry {
+		t.Fatal("must not retry after the last attempt")
+	}
+
+	if retrier.Attempts() != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", retrier.Attempts())
+	}
+
+	if _, retry := NewRetrier(Handler).Next(Permanent(errors.New("invalid"))); retry {
+		t.Fatal("must not retry a permanent error")
+	}
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	SetPolicies(map[OperationClass]Policy{OctopusRead: {Attempts: 5}})
+	defer SetPolicies(nil)
+
+	attempts := 0
+	err := Do(context.Background(), OctopusRead, func() error {
+		attempts++
+		return Permanent(errors.New("failed to find an environment called Developmnt"))
+	})
+
+	if err == nil || !IsPermanent(err) {
+		t.Fatalf("Expected a permanent error, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestNonRetryableErrors(t *testing.T) {
+	SetPolicies(map[OperationClass]Policy{OctopusRead: {Attempts: 5, NonRetryableErrors: "not found"}})
+	defer SetPolicies(nil)
+
+	attempts := 0
+	_ = Do(context.Background(), OctopusRead, func() error {
+		attempts++
+		return errors.New("project not found")
+	})
+
+	if attempts != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", attempts)
+	}
+
+	attempts = 0
+	_ = Do(context.Background(), OctopusRead, func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	if attempts != 5 {
+		t.Fatalf("Expected 5 attempts, got %d", attempts)
+	}
+}
+
+func TestMaxElapsed(t *testing.T) {
+	SetPolicies(map[OperationClass]Policy{OctopusRead: {
+		Attempts:   100,
+		Delay:      Duration(10 * time.Millisecond),
+		MaxElapsed: Duration(50 * time.Millisecond),
+	}})
+	defer SetPolicies(nil)
+
+	attempts := 0
+	_ = Do(context.Background(), OctopusRead, func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	if attempts >= 100 {
+		t.Fatalf("Expected the retries to stop after the max elapsed time, got %d attempts", attempts)
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "retry.json")
+	err := os.WriteFile(configFile, []byte(`{"OctopusRead": {"Attempts": 5, "Backoff": "exponential", "Delay": "1s"}}`), 0600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RETRY_CONFIG_FILE", configFile)
+	t.Setenv("RETRY_OCTOPUS_READ_ATTEMPTS", "7")
+	t.Setenv("RETRY_HANDLER_SCHEDULE", "0s,2m")
+	defer SetPolicies(nil)
+
+	err = LoadPolicies()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	octopusRead := GetPolicy(OctopusRead)
+	if octopusRead.Attempts != 7 || octopusRead.Backoff != ExponentialBackoff || octopusRead.Delay != Duration(time.Second) {
+		t.Fatalf("Unexpected OctopusRead policy %+v", octopusRead)
+	}
+
+	handler := GetPolicy(Handler)
+	if len(handler.Schedule) != 2 || handler.Schedule[1] != Duration(2*time.Minute) || handler.Attempts != 7 {
+		t.Fatalf("Unexpected Handler policy %+v", handler)
+	}
+
+	if GetPolicy(ArgoRead).Attempts != 2 {
+		t.Fatal("Expected the ArgoRead policy to keep its default values")
+	}
+}
+
+func TestLoadPoliciesRejectsInvalidConfig(t *testing.T) {
+	t.Setenv("RETRY_OCTOPUS_WRITE_BACKOFF", "sometimes")
+	defer SetPolicies(nil)
+
+	if err := LoadPolicies(); err == nil {
+		t.Fatal("Expected an invalid backoff to be rejected")
+	}
+}
+
+func TestLoadPoliciesRejectsInvalidNonRetryableErrors(t *testing.T) {
+	t.Setenv("RETRY_OCTOPUS_READ_NON_RETRYABLE_ERRORS", "not found(")
+	defer SetPolicies(nil)
+
+	if err := LoadPolicies(); err == nil {
+		t.Fatal("Expected an invalid non-retryable errors expression to be rejected")
+	}
+}
+
+func TestLoadPoliciesCompilesNonRetryableErrors(t *testing.T) {
+	t.Setenv("RETRY_OCTOPUS_READ_NON_RETRYABLE_ERRORS", "not found")
+	defer SetPolicies(nil)
+
+	if err := LoadPolicies(); err != nil {
+		t.Fatal(err)
+	}
+
+	if GetPolicy(OctopusRead).retryIf(errors.New("project not found"), OctopusRead, time.Now()) {
+		t.Fatal("Expected the loaded expression to stop the retries")
+	}
+}
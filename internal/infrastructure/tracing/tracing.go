@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"strings"
+)
+
+// TracerName is the name of the tracer that creates the proxy's spans.
+const TracerName = "github.com/OctopusSolutionsEngineering/OctopusArgoCDProxy"
+
+// DefaultServiceName is the service name reported with the spans, unless OTEL_SERVICE_NAME is defined.
+const DefaultServiceName = "octoargosync"
+
+// The attributes added to the spans.
+const (
+	ApplicationKey  = attribute.Key("argocd.application")
+	NamespaceKey    = attribute.Key("argocd.namespace")
+	ProjectKey      = attribute.Key("octopus.project")
+	ProjectIdKey    = attribute.Key("octopus.project.id")
+	EnvironmentKey  = attribute.Key("octopus.environment")
+	VersionKey      = attribute.Key("octopus.release.version")
+	ReleaseIdKey    = attribute.Key("octopus.release.id")
+	DeploymentIdKey = attribute.Key("octopus.deployment.id")
+)
+
+// Init configures the global tracer provider from the OTEL_TRACES_EXPORTER environment variable, which can be otlp,
+// stdout, or none. Tracing is disabled by default. The otlp exporter sends spans over gRPC, and is configured with the
+// standard OTEL_EXPORTER_OTLP_* environment variables. The W3C trace context is always read from incoming requests.
+//
+// The returned function flushes any pending spans, and must be called before the proxy exits.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")) {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracegrpc.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, errors.New("octoargosync-init-tracingerror - OTEL_TRACES_EXPORTER must be one of otlp, stdout, or none")
+	}
+
+	if err != nil {
+		return nil, errors.New("octoargosync-init-tracingerror - failed to create the trace exporter: " + err.Error())
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name
+	serviceResource, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", DefaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+
+	if err != nil {
+		return nil, errors.New("octoargosync-init-tracingerror - failed to create the trace resource: " + err.Error())
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of any span in the context.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends the span, recording the error if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// WithSpanContext returns a copy of the context that uses the span in the parent context as the parent of new spans.
+// It is used to trace background jobs that outlive the request that queued them.
+func WithSpanContext(ctx context.Context, parent context.Context) context.Context {
+	return trace.ContextWithSpanContext(ctx, trace.SpanContextFromContext(parent))
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useSpanRecorder records the spans created by the test.
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return recorder
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.POST("/api/octopusrelease", func(c *gin.Context) {
+		_, span := Start(c.Request.Context(), "child")
+		End(span, nil)
+		c.Status(http.StatusAccepted)
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/octopusrelease", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	child, server := spans[0], spans[1]
+
+	if server.Name() != "HTTP POST /api/octopusrelease" || server.Parent().SpanID().String() != "00f067aa0ba902b7" ||
+		server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected the server span to continue the incoming trace, got %s with parent %s", server.Name(), server.Parent().SpanID())
+	}
+
+	if child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("Expected the child span to be a child of the server span")
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	_, span := Start(context.Background(), "failing")
+	End(span, errors.New("octopus is unavailable"))
+
+	spans := recorder.Ended()
+
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error || len(spans[0].Events()) != 1 {
+		t.Fatalf("Expected an error span, got %+v", spans)
+	}
+}
+
+func TestWithSpanContext(t *testing.T) {
+	useSpanRecorder(t)
+
+	requestCtx, span := Start(context.Background(), "request")
+	span.End()
+
+	// Background jobs keep their own cancellation, but are traced as part of the request
+	jobCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, child := Start(WithSpanContext(jobCtx, requestCtx), "job")
+	defer child.End()
+
+	if child.SpanContext().TraceID() != span.SpanContext().TraceID() {
+		t.Fatal("Expected the job to be part of the request's trace")
+	}
+}
+
+func TestInitRejectsUnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+
+	if _, err := Init(context.Background()); err == nil {
+		t.Fatal("Expected an error for an unknown exporter")
+	}
+}
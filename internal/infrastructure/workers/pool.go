@@ -0,0 +1,184 @@
+package workers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// DefaultPoolSize is the default number of jobs that are processed concurrently.
+const DefaultPoolSize = 10
+
+// DefaultQueueSize is the default number of jobs that can be queued before new jobs are rejected.
+const DefaultQueueSize = 100
+
+// ErrQueueFull is returned when a job is submitted to a pool whose queue is full.
+var ErrQueueFull = errors.New("the work queue is full")
+
+// ErrPoolClosed is returned when a job is submitted to a pool that has been closed.
+var ErrPoolClosed = errors.New("the work pool is closed")
+
+// Job is a unit of work processed by the pool.
+type Job func()
+
+// Pool processes jobs with a fixed number of workers. Each job is submitted with a key, and jobs sharing a key are
+// processed one at a time in the order they were submitted. Jobs with different keys are processed concurrently.
+type Pool struct {
+	mutex    sync.Mutex
+	queues   map[string][]Job
+	ready    chan string
+	pending  int
+	capacity int
+	closed   bool
+	jobs     sync.WaitGroup
+	workers  sync.WaitGroup
+}
+
+// NewPool creates a pool with the supplied number of workers and a queue holding up to capacity jobs.
+func NewPool(size int, capacity int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	pool := &Pool{
+		queues: map[string][]Job{},
+		// Each key is only in the ready channel once, and there can not be more keys than queued jobs,
+		// so sending to the channel never blocks
+		ready:    make(chan string, capacity),
+		capacity: capacity,
+	}
+
+	pool.workers.Add(size)
+	for i := 0; i < size; i++ {
+		go pool.work()
+	}
+
+	return pool
+}
+
+// NewDefaultPool creates a pool configured by the WORKER_POOL_SIZE and WORKER_QUEUE_SIZE environment variables.
+func NewDefaultPool() (*Pool, error) {
+	size, err := getIntEnv("WORKER_POOL_SIZE", DefaultPoolSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	capacity, err := getIntEnv("WORKER_QUEUE_SIZE", DefaultQueueSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPool(size, capacity), nil
+}
+
+// Submit queues a job to be run after any other jobs with the same key. ErrQueueFull is returned if there
+// is no room left in the queue.
+func (p *Pool) Submit(key string, job Job) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	if p.pending >= p.capacity {
+		return ErrQueueFull
+	}
+
+	p.pending++
+	p.jobs.Add(1)
+
+	queue := p.queues[key]
+	p.queues[key] = append(queue, job)
+
+	// If the key had no other jobs, it is ready to be picked up by a worker. Otherwise, the worker processing
+	// the current job for the key will pick up the next one.
+	if len(queue) == 0 {
+		p.ready <- key
+	}
+
+	return nil
+}
+
+// Pending returns the number of jobs that are queued or running.
+func (p *Pool) Pending() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.pending
+}
+
+// Full returns true if there is no room left in the queue.
+func (p *Pool) Full() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.pending >= p.capacity
+}
+
+// Capacity returns the maximum number of jobs that can be queued or running.
+func (p *Pool) Capacity() int {
+	return p.capacity
+}
+
+// Wait blocks until all submitted jobs have completed.
+func (p *Pool) Wait() {
+	p.jobs.Wait()
+}
+
+// Close rejects any new jobs, waits for the submitted jobs to complete, and stops the workers.
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	p.jobs.Wait()
+	close(p.ready)
+	p.workers.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+
+	for key := range p.ready {
+		p.mutex.Lock()
+		job := p.queues[key][0]
+		p.mutex.Unlock()
+
+		job()
+
+		p.mutex.Lock()
+		p.pending--
+		queue := p.queues[key][1:]
+		if len(queue) == 0 {
+			delete(p.queues, key)
+		} else {
+			p.queues[key] = queue
+			p.ready <- key
+		}
+		p.mutex.Unlock()
+
+		p.jobs.Done()
+	}
+}
+
+func getIntEnv(name string, defaultValue int) (int, error) {
+	if os.Getenv(name) == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(os.Getenv(name))
+
+	if err != nil || value < 1 {
+		return 0, errors.New("octoargosync-init-workererror - " + name + " must be a positive number")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,79 @@
+package workers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestJobsWithSameKeyAreSerialised(t *testing.T) {
+	pool := NewPool(5, 100)
+	defer pool.Close()
+
+	mutex := sync.Mutex{}
+	running := 0
+	order := []int{}
+
+	for i := 0; i < 20; i++ {
+		i := i
+		err := pool.Submit("Projects-1", func() {
+			mutex.Lock()
+			running++
+			if running > 1 {
+				t.Error("jobs with the same key must not run concurrently")
+			}
+			order = append(order, i)
+			mutex.Unlock()
+
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool.Wait()
+
+	for i, value := range order {
+		if i != value {
+			t.Fatal("jobs with the same key must run in the order they were submitted")
+		}
+	}
+}
+
+func TestFullQueueRejectsJobs(t *testing.T) {
+	pool := NewPool(1, 2)
+	defer pool.Close()
+
+	block := make(chan bool)
+
+	for i := 0; i < 2; i++ {
+		err := pool.Submit("Projects-1", func() { <-block })
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := pool.Submit("Projects-2", func() {})
+
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatal("must reject jobs when the queue is full")
+	}
+
+	close(block)
+	pool.Wait()
+
+	if pool.Pending() != 0 {
+		t.Fatal("must have no pending jobs")
+	}
+
+	err = pool.Submit("Projects-2", func() {})
+
+	if err != nil {
+		t.Fatal("must accept jobs once the queue has room")
+	}
+}